@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/eventstore"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/simba"
+	"github.com/golang/protobuf/proto"
+)
+
+// settle gives a new consumer group time to resolve the newest offsets of its claims
+const settle = 2 * time.Second
+
+// broker carries the product updates from the generator to the views
+type broker interface {
+	publish(UUID string, u *pb.ProductUpdate) error
+	// consume starts a view and returns once it receives new messages
+	consume(name string, view func(msgs []*sarama.ConsumerMessage) error) error
+	close() error
+}
+
+// embedded keeps the updates in the embedded event store without syncing to disk,
+// the products are spread over a few streams and subscriptions hash the streams onto their partitions,
+// so the ordered views work on them in parallel like on kafka partitions
+type embedded struct {
+	store      *eventstore.Store
+	dir        string
+	temporary  bool
+	topic      string
+	partitions uint32
+	consumers  []*simba.Consumer
+}
+
+func newEmbedded(dir, topic string, partitions int) (*embedded, error) {
+	temporary := dir == ""
+	if temporary {
+		var err error
+		dir, err = ioutil.TempDir("", "bench-eventstore")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create event store directory: %s", err)
+		}
+	}
+
+	opts := eventstore.DefaultOptions()
+	opts.Sync = eventstore.SyncNever
+	store, err := eventstore.Open(dir, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event store: %s", err)
+	}
+
+	return &embedded{
+		store:      store,
+		dir:        dir,
+		temporary:  temporary,
+		topic:      topic,
+		partitions: uint32(partitions),
+	}, nil
+}
+
+func (e *embedded) publish(UUID string, u *pb.ProductUpdate) error {
+	bytes, err := proto.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("failed to marshal product update: %s", err)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(UUID))
+	stream := fmt.Sprintf("%s-%d", e.topic, h.Sum32()%e.partitions)
+	_, err = e.store.Append(stream, eventstore.AnyVersion, eventstore.Record{Key: []byte(UUID), Value: bytes})
+	return err
+}
+
+func (e *embedded) consume(name string, view func(msgs []*sarama.ConsumerMessage) error) error {
+	c := simba.NewBatchConsumer(e.store.Subscribe(e.store.Position()+1), view)
+	e.consumers = append(e.consumers, c)
+	go func() {
+		err := c.Start()
+		if err != nil {
+			log.Panicf("failed to consume event store: %s", err)
+		}
+	}()
+	return nil
+}
+
+func (e *embedded) close() error {
+	for _, c := range e.consumers {
+		c.Stop()
+	}
+	err := e.store.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close event store: %s", err)
+	}
+	if e.temporary {
+		return os.RemoveAll(e.dir)
+	}
+	return nil
+}
+
+// kafka publishes asynchronously and consumes with a new consumer group per view and run
+type kafka struct {
+	brokers  []string
+	topic    string
+	run      string
+	producer sarama.AsyncProducer
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	groups   []sarama.ConsumerGroup
+}
+
+func newKafka(brokers []string, topic, run string) (*kafka, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Producer.Return.Errors = true
+	producer, err := sarama.NewAsyncProducer(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup kafka producer: %s", err)
+	}
+	go func() {
+		for err := range producer.Errors() {
+			log.Panicf("failed to publish product update: %s", err)
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &kafka{
+		brokers:  brokers,
+		topic:    topic,
+		run:      run,
+		producer: producer,
+		ctx:      ctx,
+		cancel:   cancel,
+	}, nil
+}
+
+func (k *kafka) publish(UUID string, u *pb.ProductUpdate) error {
+	bytes, err := proto.Marshal(u)
+	if err != nil {
+		return fmt.Errorf("failed to marshal product update: %s", err)
+	}
+	k.producer.Input() <- &sarama.ProducerMessage{
+		Topic:     k.topic,
+		Key:       sarama.StringEncoder(UUID),
+		Value:     sarama.ByteEncoder(bytes),
+		Timestamp: time.Now(),
+	}
+	return nil
+}
+
+func (k *kafka) consume(name string, view func(msgs []*sarama.ConsumerMessage) error) error {
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	group, err := sarama.NewConsumerGroup(k.brokers, fmt.Sprintf("inventory-bench-%s-%s", name, k.run), config)
+	if err != nil {
+		return fmt.Errorf("failed to setup kafka consumer group: %s", err)
+	}
+	k.groups = append(k.groups, group)
+
+	c := simba.NewBatchConsumer(nil, view)
+	assigned := make(chan struct{})
+	var once sync.Once
+	c.OnAssigned(func(partitions map[string][]int32) error {
+		once.Do(func() { close(assigned) })
+		return nil
+	})
+
+	k.wg.Add(1)
+	go func() {
+		defer k.wg.Done()
+		err := c.Run(k.ctx, group, []string{k.topic})
+		if err != nil {
+			log.Panicf("failed to consume: %s", err)
+		}
+	}()
+
+	select {
+	case <-assigned:
+		time.Sleep(settle)
+		return nil
+	case <-time.After(time.Minute):
+		return fmt.Errorf("consumer group of %s got no partitions assigned", name)
+	}
+}
+
+func (k *kafka) close() error {
+	err := k.producer.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close kafka producer: %s", err)
+	}
+	k.cancel()
+	k.wg.Wait()
+	for _, group := range k.groups {
+		err := group.Close()
+		if err != nil {
+			return fmt.Errorf("failed to close kafka consumer group: %s", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/damoon/eventstore-example/pkg/fake"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/damoon/eventstore-example/pkg/store"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	seed       = kingpin.Flag("seed", "Seed of the generated products").Default("0").Int64()
+	products   = kingpin.Flag("products", "Size of the generated catalogue").Default("10000").Int()
+	categories = kingpin.Flag("categories", "Size of the pool of categories").Default("500").Int()
+	zipf       = kingpin.Flag("zipf", "Exponent above 1 to skew the categories, 0 picks them uniformly").Default("1.2").Float64()
+
+	pipelineCmd   = kingpin.Command("pipeline", "Publish product updates at a target rate and measure the products and categories views")
+	brokerKind    = pipelineCmd.Flag("broker", "Broker between generator and views").Default("embedded").Enum("embedded", "kafka")
+	brokerList    = pipelineCmd.Flag("brokerList", "List of brokers to connect").Default("localhost:9092").Strings()
+	topic         = pipelineCmd.Flag("topic", "Topic name, or the stream prefix of the embedded event store").Default("bench-products").String()
+	partitions    = pipelineCmd.Flag("partitions", "Streams of the embedded event store").Default("8").Int()
+	dir           = pipelineCmd.Flag("dir", "Directory of the embedded event store, a temporary one if empty").Default("").String()
+	rate          = pipelineCmd.Flag("rate", "Product updates per second").Default("1000").Int()
+	duration      = pipelineCmd.Flag("duration", "Time to publish updates").Default("30s").Duration()
+	drain         = pipelineCmd.Flag("drain", "Time to wait for the views to catch up").Default("30s").Duration()
+	storeKind     = pipelineCmd.Flag("store", "Store of the views").Default("redis").Enum(store.Kinds...)
+	redisAddress  = pipelineCmd.Flag("redisAddress", "Redis Host, the sentinels or cluster nodes").Default("redis:6379").Strings()
+	redisPassword = pipelineCmd.Flag("redisPassword", "Redis Password").Default("").String()
+	redisDatabase = pipelineCmd.Flag("redisDatabase", "Redis Database").Default("0").Int()
+	redisMaster   = pipelineCmd.Flag("redisMaster", "Name of the master monitored by the redis sentinels").Default("").String()
+	redisCluster  = pipelineCmd.Flag("redisCluster", "Connect to a redis cluster").Default("false").Bool()
+	postgres      = pipelineCmd.Flag("postgres", "Postgres connection string").Default("postgres://localhost/inventory?sslmode=disable").String()
+	boltPath      = pipelineCmd.Flag("boltPath", "Path of the bolt database file").Default("/tmp/inventory-bench.db").String()
+)
+
+func main() {
+	cmd := kingpin.Parse()
+
+	g, err := fake.New(*seed, fake.Options{
+		Categories: *categories,
+		Zipf:       *zipf,
+		Prices:     "lognormal",
+		MeanPrice:  fake.DefaultOptions.MeanPrice,
+		TextSize:   fake.DefaultOptions.TextSize,
+	})
+	if err != nil {
+		log.Panicf("failed to setup generator: %s", err)
+	}
+
+	// every run writes to its own tenant, the views skip updates of earlier runs
+	run := fmt.Sprintf("%d", time.Now().Unix())
+	t := "bench-" + run
+
+	switch cmd {
+	case pipelineCmd.FullCommand():
+		redisOptions := redisclient.Options{
+			Addresses:  *redisAddress,
+			MasterName: *redisMaster,
+			Cluster:    *redisCluster,
+			Password:   *redisPassword,
+			Database:   *redisDatabase,
+		}
+		st, err := store.New(store.Options{
+			Kind:     *storeKind,
+			Redis:    redisOptions,
+			Postgres: *postgres,
+			Bolt:     *boltPath,
+		})
+		if err != nil {
+			log.Panicf("failed to setup store: %s", err)
+		}
+		defer st.Close()
+
+		r, err := redisStats(redisOptions)
+		if err != nil {
+			log.Panicf("failed to setup redis client: %s", err)
+		}
+
+		var b broker
+		switch *brokerKind {
+		case "embedded":
+			b, err = newEmbedded(*dir, *topic, *partitions)
+		case "kafka":
+			b, err = newKafka(*brokerList, *topic, run)
+		}
+		if err != nil {
+			log.Panicf("failed to setup broker: %s", err)
+		}
+
+		log.Printf("writing views of tenant %s", t)
+		err = pipeline(b, newStream(g, t, *products), st, r)
+		if err != nil {
+			log.Panicf("failed to benchmark pipeline: %s", err)
+		}
+
+		err = b.close()
+		if err != nil {
+			log.Panicf("failed to close broker: %s", err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/damoon/eventstore-example/pkg/store"
+	"github.com/damoon/eventstore-example/pkg/tenant"
+	"github.com/go-redis/redis"
+)
+
+// pipeline publishes the stream at the target rate and measures how fast the products and categories views keep up
+func pipeline(b broker, s *stream, st store.Store, r redis.UniversalClient) error {
+	filter := tenant.Filter{s.tenant: true}
+	start := time.Now()
+	products := newRecorder("products", start)
+	categories := newRecorder("categories", start)
+
+	err := b.consume("products", func(msgs []*sarama.ConsumerMessage) error {
+		writes, err := store.ProductWrites(msgs, filter)
+		if err != nil {
+			return err
+		}
+		err = st.WriteProducts("inventory-bench-products", writes, store.NextOffsets(msgs))
+		if err != nil {
+			return err
+		}
+		products.record(msgs)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = b.consume("categories", func(msgs []*sarama.ConsumerMessage) error {
+		moves, err := store.CategoryChanges(msgs, filter)
+		if err != nil {
+			return err
+		}
+		err = st.WriteCategories("inventory-bench-categories", moves, store.NextOffsets(msgs))
+		if err != nil {
+			return err
+		}
+		categories.record(msgs)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	opsBefore := int64(0)
+	if r != nil {
+		opsBefore, err = commandsProcessed(r)
+		if err != nil {
+			return err
+		}
+	}
+
+	start = time.Now()
+	products.begin(start)
+	categories.begin(start)
+
+	sent, err := produce(b, s, *rate, *duration)
+	if err != nil {
+		return err
+	}
+	produced := time.Since(start)
+	log.Printf("published %d updates in %s, %.0f msg/s", sent, produced.Round(time.Millisecond), float64(sent)/produced.Seconds())
+
+	deadline := time.Now().Add(*drain)
+	for products.count() < sent || categories.count() < sent {
+		if time.Now().After(deadline) {
+			log.Printf("views did not catch up within %s", *drain)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	elapsed := time.Since(start)
+
+	products.report()
+	categories.report()
+
+	if r != nil {
+		opsAfter, err := commandsProcessed(r)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("redis: %.0f ops/s\n", float64(opsAfter-opsBefore)/elapsed.Seconds())
+	}
+
+	return nil
+}
+
+// produce publishes as many updates as the rate allows at every tick
+func produce(b broker, s *stream, rate int, d time.Duration) (int, error) {
+	tick := time.NewTicker(10 * time.Millisecond)
+	defer tick.Stop()
+
+	start := time.Now()
+	sent := 0
+	for now := range tick.C {
+		elapsed := now.Sub(start)
+		if elapsed > d {
+			break
+		}
+		due := int(elapsed.Seconds() * float64(rate))
+		for ; sent < due; sent++ {
+			UUID, u := s.next()
+			err := b.publish(UUID, u)
+			if err != nil {
+				return sent, fmt.Errorf("failed to publish product update: %s", err)
+			}
+		}
+	}
+	return sent, nil
+}
+
+func redisStats(o redisclient.Options) (redis.UniversalClient, error) {
+	if *storeKind != "redis" {
+		return nil, nil
+	}
+	return redisclient.New(o)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/go-redis/redis"
+)
+
+// recorder collects the end-to-end latencies of the messages of one view,
+// from the timestamp of the message until the view wrote it
+type recorder struct {
+	name      string
+	start     time.Time
+	mux       sync.Mutex
+	latencies []time.Duration
+	last      time.Time
+}
+
+func newRecorder(name string, start time.Time) *recorder {
+	return &recorder{name: name, start: start}
+}
+
+// begin resets the start of the measurement
+func (r *recorder) begin(start time.Time) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.start = start
+}
+
+// record skips messages of earlier runs
+func (r *recorder) record(msgs []*sarama.ConsumerMessage) {
+	now := time.Now()
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for _, msg := range msgs {
+		if msg.Timestamp.Before(r.start) {
+			continue
+		}
+		r.latencies = append(r.latencies, now.Sub(msg.Timestamp))
+		r.last = now
+	}
+}
+
+func (r *recorder) count() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return len(r.latencies)
+}
+
+func (r *recorder) report() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if len(r.latencies) == 0 {
+		fmt.Printf("%s: no messages\n", r.name)
+		return
+	}
+
+	sorted := make([]time.Duration, len(r.latencies))
+	copy(sorted, r.latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	elapsed := r.last.Sub(r.start).Seconds()
+	fmt.Printf("%s: %d messages, %.0f msg/s, latency p50 %s p90 %s p99 %s max %s\n",
+		r.name, len(sorted), float64(len(sorted))/elapsed,
+		percentile(sorted, 50), percentile(sorted, 90), percentile(sorted, 99), sorted[len(sorted)-1].Round(time.Microsecond))
+}
+
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := len(sorted) * p / 100
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i].Round(time.Microsecond)
+}
+
+// commandsProcessed sums total_commands_processed of all redis masters
+func commandsProcessed(r redis.UniversalClient) (int64, error) {
+	var mux sync.Mutex
+	total := int64(0)
+	err := redisclient.ForEachMaster(r, func(c *redis.Client) error {
+		info, err := c.Info("stats").Result()
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(info, "\n") {
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "total_commands_processed:") {
+				continue
+			}
+			n, err := strconv.ParseInt(strings.TrimPrefix(line, "total_commands_processed:"), 10, 64)
+			if err != nil {
+				return err
+			}
+			mux.Lock()
+			total += n
+			mux.Unlock()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to read redis stats: %s", err)
+	}
+	return total, nil
+}
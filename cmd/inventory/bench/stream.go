@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/damoon/eventstore-example/pkg/fake"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// stream generates product updates of a catalogue, it inserts products until the catalogue is full
+// and then mostly reprices and moves products with some deletes
+type stream struct {
+	g        *fake.Generator
+	tenant   string
+	size     int
+	products map[string]*pb.Product
+	uuids    []string
+}
+
+func newStream(g *fake.Generator, t string, size int) *stream {
+	return &stream{
+		g:        g,
+		tenant:   t,
+		size:     size,
+		products: map[string]*pb.Product{},
+	}
+}
+
+// next returns the uuid and the update of a product
+func (s *stream) next() (string, *pb.ProductUpdate) {
+	if len(s.uuids) < s.size {
+		p := s.g.Product("EUR")
+		s.products[p.Uuid] = p
+		s.uuids = append(s.uuids, p.Uuid)
+		return p.Uuid, &pb.ProductUpdate{New: p, Tenant: s.tenant}
+	}
+
+	i := s.g.Intn(len(s.uuids))
+	UUID := s.uuids[i]
+	old := s.products[UUID]
+
+	switch n := s.g.Intn(100); {
+	case n < 10:
+		s.uuids[i] = s.uuids[len(s.uuids)-1]
+		s.uuids = s.uuids[:len(s.uuids)-1]
+		delete(s.products, UUID)
+		return UUID, &pb.ProductUpdate{Old: old, Tenant: s.tenant}
+
+	case n < 30:
+		p := proto.Clone(old).(*pb.Product)
+		p.Category = s.g.Category()
+		s.products[UUID] = p
+		return UUID, &pb.ProductUpdate{Old: old, New: p, Tenant: s.tenant}
+
+	default:
+		p := proto.Clone(old).(*pb.Product)
+		p.Price = &pb.Money{Units: s.g.Units(), Currency: old.Price.Currency}
+		s.products[UUID] = p
+		return UUID, &pb.ProductUpdate{Old: old, New: p, Tenant: s.tenant}
+	}
+}
+
+// catalogue returns a copy of the current products
+func (s *stream) catalogue() map[string]*pb.Product {
+	c := make(map[string]*pb.Product, len(s.products))
+	for UUID, p := range s.products {
+		c[UUID] = p
+	}
+	return c
+}
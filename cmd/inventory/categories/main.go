@@ -1,106 +1,142 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 
 	"github.com/Shopify/sarama"
-	cluster "github.com/bsm/sarama-cluster"
-	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
 	"github.com/damoon/eventstore-example/pkg/simba"
-	"github.com/go-redis/redis"
-	"github.com/gogo/protobuf/proto"
+	"github.com/damoon/eventstore-example/pkg/snapshot"
+	"github.com/damoon/eventstore-example/pkg/store"
+	"github.com/damoon/eventstore-example/pkg/tenant"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
 	brokerList    = kingpin.Flag("brokerList", "List of brokers to connect").Default("localhost:9092").Strings()
 	topic         = kingpin.Flag("topic", "Topic name").Default("products").String()
-	redisAddress  = kingpin.Flag("redisAddress", "Redis Host").Default("redis:6379").String()
+	redisAddress  = kingpin.Flag("redisAddress", "Redis Host, the sentinels or cluster nodes").Default("redis:6379").Strings()
 	redisPassword = kingpin.Flag("redisPassword", "Redis Password").Default("").String()
 	redisDatabase = kingpin.Flag("redisDatabase", "Redis Database").Default("0").Int()
+	redisMaster   = kingpin.Flag("redisMaster", "Name of the master monitored by the redis sentinels").Default("").String()
+	redisCluster  = kingpin.Flag("redisCluster", "Connect to a redis cluster").Default("false").Bool()
+	group         = kingpin.Flag("group", "Consumer group").Default("inventory-categories-v2").String()
+	tenants       = kingpin.Flag("tenant", "Tenants to serve, all if none are given").Strings()
+	metrics       = kingpin.Flag("metricsAddress", "Address to publish per tenant metrics at /debug/vars").Default("").String()
+	storeKind     = kingpin.Flag("store", "Store of the view").Default("redis").Enum(store.Kinds...)
+	postgres      = kingpin.Flag("postgres", "Postgres connection string").Default("postgres://localhost/inventory?sslmode=disable").String()
+	maxInFlight   = kingpin.Flag("maxInFlight", "Messages in flight before consumption pauses").Default("10000").Int()
+	maxBytes      = kingpin.Flag("maxInFlightBytes", "Bytes in flight before consumption pauses").Default("67108864").Int64()
+	boltPath      = kingpin.Flag("boltPath", "Path of the bolt database file").Default("/var/lib/inventory/categories.db").String()
 	verbose       = kingpin.Flag("verbose", "Verbosity").Default("false").Bool()
 )
 
+var filter tenant.Filter
+
 func main() {
 	kingpin.Parse()
 
-	config := cluster.NewConfig()
+	var err error
+	filter, err = tenant.NewFilter(*tenants)
+	if err != nil {
+		log.Panicf("failed to parse flags: %s", err)
+	}
+	tenant.ServeMetrics(*metrics)
+
+	s, err := store.New(store.Options{
+		Kind: *storeKind,
+		Redis: redisclient.Options{
+			Addresses:  *redisAddress,
+			MasterName: *redisMaster,
+			Cluster:    *redisCluster,
+			Password:   *redisPassword,
+			Database:   *redisDatabase,
+		},
+		Postgres: *postgres,
+		Bolt:     *boltPath,
+	})
+	if err != nil {
+		log.Panicf("failed to setup store: %s", err)
+	}
+	defer s.Close()
+
+	err = resumeFromStore(s)
+	if err != nil {
+		log.Panicf("failed to resume from offsets of the store: %s", err)
+	}
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
 	config.Consumer.Return.Errors = true
 	config.Consumer.Offsets.Initial = sarama.OffsetOldest
-	config.Group.Return.Notifications = true
 	topics := []string{*topic}
-	consumer, err := cluster.NewConsumer(*brokerList, "inventory-categories-v1", topics, config)
+	consumer, err := sarama.NewConsumerGroup(*brokerList, *group, config)
 	if err != nil {
-		log.Panicf("failed to setup kafka consumer: %s", err)
+		log.Panicf("failed to setup kafka consumer group: %s", err)
 	}
 	defer func() {
 		if err := consumer.Close(); err != nil {
-			log.Panicf("failed to close kafka consumer: %s", err)
+			log.Panicf("failed to close kafka consumer group: %s", err)
 		}
 	}()
 
-	r := redis.NewClient(&redis.Options{
-		Addr:     *redisAddress,
-		Password: *redisPassword,
-		DB:       *redisDatabase,
-	})
-	v := func(msg *sarama.ConsumerMessage) error {
-		return view(r, msg)
+	v := func(msgs []*sarama.ConsumerMessage) error {
+		return view(s, msgs)
 	}
-	simba := simba.NewConsumer(consumer, v)
-	simba.Start()
-
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt)
-	<-signals
-	log.Print("interrupt is detected")
-	simba.Stop()
-}
+	limits := simba.Limits{Messages: *maxInFlight, Bytes: *maxBytes}
+	simba := simba.NewBatchConsumer(nil, v)
+	simba.SetLimits(limits)
 
-func view(redis *redis.Client, msg *sarama.ConsumerMessage) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		<-signals
+		log.Print("interrupt is detected")
+		cancel()
+	}()
 
-	p := pb.ProductUpdate{}
-	err := proto.Unmarshal(msg.Value, &p)
+	err = simba.Run(ctx, consumer, topics)
 	if err != nil {
-		return fmt.Errorf("failed to unmarshal kafka massaga: %s", err)
+		log.Panicf("failed to consume: %s", err)
 	}
+}
 
-	UUID := string(msg.Key)
-
-	if p.New == nil {
-		err := redis.SRem(p.Old.Category, UUID).Err()
-		if err != nil {
-			return fmt.Errorf("failed to remove %s from category %s: %s", UUID, p.Old.Category, err)
-		}
-		return nil
+// resumeFromStore resets the consumer group to the offsets written with the view,
+// the offsets committed to kafka lag behind them after a crash
+func resumeFromStore(s store.Store) error {
+	offsets, err := s.Offsets(*group)
+	if err != nil {
+		return err
 	}
-
-	if p.Old == nil {
-		err := redis.SAdd(p.New.Category, UUID).Err()
-		if err != nil {
-			return fmt.Errorf("failed to add %s to category %s: %s", UUID, p.New.Category, err)
-		}
+	if len(offsets) == 0 {
 		return nil
 	}
 
-	if p.Old.Category == p.New.Category {
-		if *verbose {
-			log.Printf("category for %s did not change", UUID)
-		}
-		return nil
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	client, err := sarama.NewClient(*brokerList, config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to kafka: %s", err)
 	}
+	defer client.Close()
 
-	err = redis.SRem(p.Old.Category, UUID).Err()
+	return snapshot.CommitOffsets(client, *group, offsets)
+}
+
+// view applies the net category change of every product of the batch at once
+func view(s store.Store, msgs []*sarama.ConsumerMessage) error {
+	moves, err := store.CategoryChanges(msgs, filter)
 	if err != nil {
-		return fmt.Errorf("failed to remove %s from category %s: %s", UUID, p.Old.Category, err)
+		return err
 	}
-	err = redis.SAdd(p.New.Category, UUID).Err()
-	if err != nil {
-		return fmt.Errorf("failed to add %s to category %s: %s", UUID, p.New.Category, err)
+	if *verbose {
+		log.Printf("%d of %d updates changed categories", len(moves), len(msgs))
 	}
-
-	return nil
+	return s.WriteCategories(*group, moves, store.NextOffsets(msgs))
 }
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/damoon/eventstore-example/pkg/simba"
+	"github.com/damoon/eventstore-example/pkg/tenant"
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const activityKeyPrefix = "category-activity:"
+
+var (
+	brokerList        = kingpin.Flag("brokerList", "List of brokers to connect").Default("localhost:9092").Strings()
+	topic             = kingpin.Flag("topic", "Topic name").Default("products").String()
+	group             = kingpin.Flag("group", "Consumer group").Default("inventory-category-activity-v1").String()
+	stateDir          = kingpin.Flag("stateDir", "Directory of the local state stores").Default("/var/lib/inventory/state").String()
+	replicationFactor = kingpin.Flag("replicationFactor", "Replication factor of the changelog and output topics").Default("1").Int16()
+	windows           = kingpin.Flag("windows", "Kind of windows").Default("tumbling").Enum("tumbling", "hopping", "session")
+	size              = kingpin.Flag("size", "Size of tumbling and hopping windows").Default("1h").Duration()
+	advance           = kingpin.Flag("advance", "Advance of hopping windows").Default("15m").Duration()
+	gap               = kingpin.Flag("gap", "Inactivity gap that closes a session window").Default("30m").Duration()
+	grace             = kingpin.Flag("grace", "Time late changes are accepted after a window ended").Default("10m").Duration()
+	outputTopic       = kingpin.Flag("outputTopic", "Write the counts to this topic instead of redis").Default("").String()
+	redisAddress      = kingpin.Flag("redisAddress", "Redis Host, the sentinels or cluster nodes").Default("redis:6379").Strings()
+	redisPassword     = kingpin.Flag("redisPassword", "Redis Password").Default("").String()
+	redisDatabase     = kingpin.Flag("redisDatabase", "Redis Database").Default("0").Int()
+	redisMaster       = kingpin.Flag("redisMaster", "Name of the master monitored by the redis sentinels").Default("").String()
+	redisCluster      = kingpin.Flag("redisCluster", "Connect to a redis cluster").Default("false").Bool()
+)
+
+// category-activity counts the catalogue changes per category and window of event time
+func main() {
+	kingpin.Parse()
+
+	emit, closeEmitter, err := emitter()
+	if err != nil {
+		log.Panicf("failed to setup output: %s", err)
+	}
+	defer closeEmitter()
+
+	stores, err := simba.NewStores(*brokerList, *stateDir, *group, *replicationFactor)
+	if err != nil {
+		log.Panicf("failed to setup state stores: %s", err)
+	}
+	defer stores.Close()
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	topics := []string{*topic}
+	consumer, err := sarama.NewConsumerGroup(*brokerList, *group, config)
+	if err != nil {
+		log.Panicf("failed to setup kafka consumer group: %s", err)
+	}
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			log.Panicf("failed to close kafka consumer group: %s", err)
+		}
+	}()
+
+	simba, err := simba.NewWindowConsumer(stores, *topic, simba.Aggregation{
+		Name:      "category-activity",
+		Windows:   selectWindows(),
+		Grace:     *grace,
+		Key:       category,
+		Aggregate: count,
+		Merge:     sum,
+		Emit:      emit,
+	})
+	if err != nil {
+		log.Panicf("failed to setup aggregation: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		<-signals
+		log.Print("interrupt is detected")
+		cancel()
+	}()
+
+	err = simba.Run(ctx, consumer, topics)
+	if err != nil {
+		log.Panicf("failed to consume: %s", err)
+	}
+}
+
+func selectWindows() simba.Windows {
+	switch *windows {
+	case "hopping":
+		return simba.HoppingWindows(*size, *advance)
+	case "session":
+		return simba.SessionWindows(*gap)
+	}
+	return simba.TumblingWindows(*size)
+}
+
+// category groups a product change by the tenant and category, deletes count for the old category
+func category(msg *sarama.ConsumerMessage) (string, error) {
+	p := pb.ProductUpdate{}
+	err := proto.Unmarshal(msg.Value, &p)
+	if err != nil {
+		return "", fmt.Errorf("failed to unmarshal kafka massaga: %s", err)
+	}
+	switch {
+	case p.New != nil:
+		return tenant.Key(p.Tenant, p.New.Category), nil
+	case p.Old != nil:
+		return tenant.Key(p.Tenant, p.Old.Category), nil
+	}
+	return "", nil
+}
+
+func count(aggregate []byte, msg *sarama.ConsumerMessage) ([]byte, error) {
+	return encode(decode(aggregate) + 1), nil
+}
+
+func sum(a, b []byte) ([]byte, error) {
+	return encode(decode(a) + decode(b)), nil
+}
+
+func encode(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b
+}
+
+func decode(b []byte) uint64 {
+	if len(b) < 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// emitter writes the counts to the output topic or to a redis hash per category with a field per window start
+func emitter() (func(key string, window simba.Window, aggregate []byte) error, func(), error) {
+	if *outputTopic != "" {
+		config := sarama.NewConfig()
+		config.Version = sarama.V1_1_0_0
+		config.Producer.RequiredAcks = sarama.WaitForAll
+		config.Producer.Return.Successes = true
+		producer, err := sarama.NewSyncProducer(*brokerList, config)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to setup kafka producer: %s", err)
+		}
+		closeProducer := func() {
+			if err := producer.Close(); err != nil {
+				log.Panicf("failed to close kafka producer: %s", err)
+			}
+		}
+		return simba.TopicEmitter(producer, *outputTopic), closeProducer, nil
+	}
+
+	r, err := redisclient.New(redisclient.Options{
+		Addresses:  *redisAddress,
+		MasterName: *redisMaster,
+		Cluster:    *redisCluster,
+		Password:   *redisPassword,
+		Database:   *redisDatabase,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to setup redis client: %s", err)
+	}
+	emit := func(key string, window simba.Window, aggregate []byte) error {
+		return store(r, key, window, decode(aggregate))
+	}
+	return emit, func() { r.Close() }, nil
+}
+
+func store(r redis.UniversalClient, key string, window simba.Window, changes uint64) error {
+	field := window.Start.UTC().Format(time.RFC3339)
+	if *windows == "session" {
+		field += "/" + window.End.UTC().Format(time.RFC3339)
+	}
+	err := r.HSet(activityKeyPrefix+key, field, changes).Err()
+	if err != nil {
+		return fmt.Errorf("failed to write activity of %s to redis: %s", key, err)
+	}
+	return nil
+}
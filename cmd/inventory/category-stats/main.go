@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/damoon/eventstore-example/pkg/simba"
+	"github.com/damoon/eventstore-example/pkg/tenant"
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	redisAddress  = kingpin.Flag("redisAddress", "Redis Host, the sentinels or cluster nodes").Default("redis:6379").Strings()
+	redisPassword = kingpin.Flag("redisPassword", "Redis Password").Default("").String()
+	redisDatabase = kingpin.Flag("redisDatabase", "Redis Database").Default("0").Int()
+	redisMaster   = kingpin.Flag("redisMaster", "Name of the master monitored by the redis sentinels").Default("").String()
+	redisCluster  = kingpin.Flag("redisCluster", "Connect to a redis cluster").Default("false").Bool()
+
+	viewCmd     = kingpin.Command("view", "Keep statistics per category from kafka in redis")
+	brokerList  = viewCmd.Flag("brokerList", "List of brokers to connect").Default("localhost:9092").Strings()
+	topic       = viewCmd.Flag("topic", "Topic name").Default("products").String()
+	group       = viewCmd.Flag("group", "Consumer group").Default("inventory-category-stats-v1").String()
+	tenants     = viewCmd.Flag("tenant", "Tenants to serve, all if none are given").Strings()
+	maxInFlight = viewCmd.Flag("maxInFlight", "Messages in flight before consumption pauses").Default("10000").Int()
+	maxBytes    = viewCmd.Flag("maxInFlightBytes", "Bytes in flight before consumption pauses").Default("67108864").Int64()
+	legacy      = viewCmd.Flag("legacyCurrency", "ISO 4217 currency of the float prices of products published before prices carried a currency, reading such a product fails without it").Default("").String()
+
+	topCmd    = kingpin.Command("top", "List the top categories")
+	by        = topCmd.Flag("by", "Order by product count or by the last change").Default("count").Enum("count", "changed")
+	limit     = topCmd.Flag("limit", "Number of categories to list").Default("20").Int64()
+	topTenant = topCmd.Flag("tenant", "Tenant of the categories, empty for the default catalogue").Default("").String()
+)
+
+// the keys of a category share its hash tag, so the scripts update them atomically.
+// The rankings across categories have a tag per tenant and follow the revision of every category
+const statsGroup = "category-stats"
+
+// addScript adds a product with its price to a category, adding it again only updates the price.
+// It returns the product count, the revision of the count and the last change of the category
+var addScript = redis.NewScript(`
+local stats, members, prices = KEYS[1], KEYS[2], KEYS[3]
+local uuid, units, currency, changed = ARGV[1], ARGV[2], ARGV[3], tonumber(ARGV[4])
+if redis.call("SADD", members, uuid) == 1 then
+  redis.call("HINCRBY", stats, "count", 1)
+  redis.call("HINCRBY", stats, "revision", 1)
+end
+local old = redis.call("ZSCORE", prices, uuid)
+if old then
+  redis.call("HINCRBY", stats, "sum:" .. currency, string.format("%d", tonumber(units) - tonumber(old)))
+else
+  redis.call("HINCRBY", stats, "count:" .. currency, 1)
+  redis.call("HINCRBY", stats, "sum:" .. currency, units)
+end
+redis.call("ZADD", prices, units, uuid)
+if tonumber(redis.call("HGET", stats, "lastChanged") or 0) < changed then
+  redis.call("HSET", stats, "lastChanged", changed)
+end
+return redis.call("HMGET", stats, "count", "revision", "lastChanged")
+`)
+
+// removeScript removes a product and its price from a category, removing it twice has no effect.
+// It returns the same fields as addScript
+var removeScript = redis.NewScript(`
+local stats, members, prices = KEYS[1], KEYS[2], KEYS[3]
+local uuid, currency, changed = ARGV[1], ARGV[2], tonumber(ARGV[3])
+if redis.call("SREM", members, uuid) == 1 then
+  redis.call("HINCRBY", stats, "count", -1)
+  redis.call("HINCRBY", stats, "revision", 1)
+end
+local old = redis.call("ZSCORE", prices, uuid)
+if old then
+  redis.call("ZREM", prices, uuid)
+  redis.call("HINCRBY", stats, "count:" .. currency, -1)
+  redis.call("HINCRBY", stats, "sum:" .. currency, string.format("%d", -tonumber(old)))
+end
+if tonumber(redis.call("HGET", stats, "lastChanged") or 0) < changed then
+  redis.call("HSET", stats, "lastChanged", changed)
+end
+return redis.call("HMGET", stats, "count", "revision", "lastChanged")
+`)
+
+// rankScript ranks a category by the stats a script returned, stats of an older revision
+// than the ranked one come from a concurrent consumer and only move the last change forward
+var rankScript = redis.NewScript(`
+local ranking, recent, revisions = KEYS[1], KEYS[2], KEYS[3]
+local category, count, revision, changed = ARGV[1], tonumber(ARGV[2]), tonumber(ARGV[3]), tonumber(ARGV[4])
+if tonumber(redis.call("HGET", revisions, category) or 0) <= revision then
+  redis.call("HSET", revisions, category, revision)
+  if count > 0 then
+    redis.call("ZADD", ranking, count, category)
+  else
+    redis.call("ZREM", ranking, category)
+  end
+end
+if tonumber(redis.call("ZSCORE", recent, category) or 0) < changed then
+  redis.call("ZADD", recent, changed, category)
+end
+return 1
+`)
+
+var filter tenant.Filter
+
+func main() {
+	cmd := kingpin.Parse()
+	pb.LegacyCurrency = *legacy
+
+	r, err := redisclient.New(redisclient.Options{
+		Addresses:  *redisAddress,
+		MasterName: *redisMaster,
+		Cluster:    *redisCluster,
+		Password:   *redisPassword,
+		Database:   *redisDatabase,
+	})
+	if err != nil {
+		log.Panicf("failed to setup redis client: %s", err)
+	}
+
+	switch cmd {
+	case viewCmd.FullCommand():
+		filter, err = tenant.NewFilter(*tenants)
+		if err != nil {
+			log.Panicf("failed to parse flags: %s", err)
+		}
+		consume(r)
+	case topCmd.FullCommand():
+		err := top(r, *topTenant, *by, *limit)
+		if err != nil {
+			log.Panicf("failed to list top categories: %s", err)
+		}
+	}
+}
+
+func consume(r redis.UniversalClient) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	topics := []string{*topic}
+	consumer, err := sarama.NewConsumerGroup(*brokerList, *group, config)
+	if err != nil {
+		log.Panicf("failed to setup kafka consumer group: %s", err)
+	}
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			log.Panicf("failed to close kafka consumer group: %s", err)
+		}
+	}()
+
+	v := func(msgs []*sarama.ConsumerMessage) error {
+		return view(r, msgs)
+	}
+	limits := simba.Limits{Messages: *maxInFlight, Bytes: *maxBytes}
+	simba := simba.NewBatchConsumer(nil, v)
+	simba.SetLimits(limits)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		<-signals
+		log.Print("interrupt is detected")
+		cancel()
+	}()
+
+	err = simba.Run(ctx, consumer, topics)
+	if err != nil {
+		log.Panicf("failed to consume: %s", err)
+	}
+}
+
+func categoryTag(category string) string {
+	return statsGroup + ":" + category
+}
+
+func statsKey(t, category string) string {
+	return tenant.TaggedKey(t, categoryTag(category), "stats")
+}
+
+func membersKey(t, category string) string {
+	return tenant.TaggedKey(t, categoryTag(category), "members")
+}
+
+func pricesKey(t, category, currency string) string {
+	return tenant.TaggedKey(t, categoryTag(category), "prices:"+currency)
+}
+
+func rankingKey(t string) string {
+	return tenant.TaggedKey(t, statsGroup, "ranking")
+}
+
+func recentKey(t string) string {
+	return tenant.TaggedKey(t, statsGroup, "recent")
+}
+
+func revisionsKey(t string) string {
+	return tenant.TaggedKey(t, statsGroup, "revisions")
+}
+
+func keys(t, category, currency string) []string {
+	return []string{statsKey(t, category), membersKey(t, category), pricesKey(t, category, currency)}
+}
+
+// update is a script call on the keys of one category
+type update struct {
+	tenant   string
+	category string
+	cmd      *redis.Cmd
+}
+
+// view moves the old version of every product out of the stats of its category and adds the new version,
+// then ranks the changed categories. The scripts are idempotent so replays after a crash converge
+func view(r redis.UniversalClient, msgs []*sarama.ConsumerMessage) error {
+	pipe := r.Pipeline()
+	updates := []update{}
+
+	for _, msg := range msgs {
+		p := pb.ProductUpdate{}
+		err := proto.Unmarshal(msg.Value, &p)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal kafka massaga: %s", err)
+		}
+		err = pb.UpcastProductUpdate(&p)
+		if err != nil {
+			return err
+		}
+
+		if !filter.Serves(p.Tenant) {
+			continue
+		}
+
+		UUID := string(msg.Key)
+		changed := msg.Timestamp.Unix()
+
+		if p.Old != nil && !sameSlot(p.Old, p.New) {
+			currency := p.Old.Price.Currency
+			cmd := removeScript.Eval(pipe, keys(p.Tenant, p.Old.Category, currency), UUID, currency, changed)
+			updates = append(updates, update{tenant: p.Tenant, category: p.Old.Category, cmd: cmd})
+		}
+		if p.New != nil {
+			currency := p.New.Price.Currency
+			cmd := addScript.Eval(pipe, keys(p.Tenant, p.New.Category, currency), UUID, p.New.Price.Units, currency, changed)
+			updates = append(updates, update{tenant: p.Tenant, category: p.New.Category, cmd: cmd})
+		}
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+	_, err := pipe.Exec()
+	if err != nil {
+		return fmt.Errorf("failed to apply %d category stats updates: %s", len(updates), err)
+	}
+
+	pipe = r.Pipeline()
+	for _, u := range updates {
+		result, err := u.cmd.Result()
+		if err != nil {
+			return fmt.Errorf("failed to update stats of category %s: %s", u.category, err)
+		}
+		fields, err := rankFields(result)
+		if err != nil {
+			return fmt.Errorf("failed to read stats of category %s: %s", u.category, err)
+		}
+		keys := []string{rankingKey(u.tenant), recentKey(u.tenant), revisionsKey(u.tenant)}
+		rankScript.Eval(pipe, keys, u.category, fields[0], fields[1], fields[2])
+	}
+	_, err = pipe.Exec()
+	if err != nil {
+		return fmt.Errorf("failed to rank %d categories: %s", len(updates), err)
+	}
+	return nil
+}
+
+// rankFields parses the count, revision and last change a stats script returns
+func rankFields(result interface{}) ([]string, error) {
+	fields, ok := result.([]interface{})
+	if !ok || len(fields) != 3 {
+		return nil, fmt.Errorf("unexpected script result %v", result)
+	}
+	values := make([]string, len(fields))
+	for i, f := range fields {
+		switch v := f.(type) {
+		case nil:
+			values[i] = "0"
+		case string:
+			values[i] = v
+		default:
+			return nil, fmt.Errorf("unexpected script result %v", result)
+		}
+	}
+	return values, nil
+}
+
+// sameSlot tells if the new version only changes the price within the same category and currency
+func sameSlot(old, new *pb.Product) bool {
+	return new != nil && old.Category == new.Category && old.Price.Currency == new.Price.Currency
+}
+
+// stats of a category, prices in minor units per currency
+type stats struct {
+	count       int64
+	lastChanged time.Time
+	prices      map[string]*priceStats
+}
+
+type priceStats struct {
+	count int64
+	sum   int64
+	min   int64
+	max   int64
+}
+
+func load(r redis.UniversalClient, t, category string) (*stats, error) {
+	fields, err := r.HGetAll(statsKey(t, category)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stats of category %s: %s", category, err)
+	}
+
+	s := &stats{prices: map[string]*priceStats{}}
+	s.count, _ = strconv.ParseInt(fields["count"], 10, 64)
+	changed, _ := strconv.ParseInt(fields["lastChanged"], 10, 64)
+	s.lastChanged = time.Unix(changed, 0)
+
+	for field, value := range fields {
+		if !strings.HasPrefix(field, "count:") {
+			continue
+		}
+		currency := strings.TrimPrefix(field, "count:")
+		ps := &priceStats{}
+		ps.count, _ = strconv.ParseInt(value, 10, 64)
+		if ps.count <= 0 {
+			continue
+		}
+		ps.sum, _ = strconv.ParseInt(fields["sum:"+currency], 10, 64)
+
+		key := pricesKey(t, category, currency)
+		min, err := r.ZRangeWithScores(key, 0, 0).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load min price of category %s: %s", category, err)
+		}
+		max, err := r.ZRevRangeWithScores(key, 0, 0).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load max price of category %s: %s", category, err)
+		}
+		if len(min) == 0 || len(max) == 0 {
+			continue
+		}
+		ps.min = int64(min[0].Score)
+		ps.max = int64(max[0].Score)
+		s.prices[currency] = ps
+	}
+
+	return s, nil
+}
+
+func top(r redis.UniversalClient, t, by string, limit int64) error {
+	key := rankingKey(t)
+	if by == "changed" {
+		key = recentKey(t)
+	}
+	categories, err := r.ZRevRange(key, 0, limit-1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to load category ranking: %s", err)
+	}
+
+	for _, category := range categories {
+		s, err := load(r, t, category)
+		if err != nil {
+			return err
+		}
+
+		currencies := []string{}
+		for currency := range s.prices {
+			currencies = append(currencies, currency)
+		}
+		sort.Strings(currencies)
+
+		prices := []string{}
+		for _, currency := range currencies {
+			ps := s.prices[currency]
+			prices = append(prices, fmt.Sprintf("%s min %s avg %s max %s",
+				currency, formatUnits(ps.min), formatUnits(ps.sum/ps.count), formatUnits(ps.max)))
+		}
+
+		fmt.Printf("%s %d products, changed %s, %s\n",
+			category, s.count, s.lastChanged.Format(time.RFC3339), strings.Join(prices, ", "))
+	}
+
+	return nil
+}
+
+// formatUnits prints minor units with two decimal places
+func formatUnits(units int64) string {
+	sign := ""
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units/100, units%100)
+}
@@ -3,15 +3,13 @@ package main
 import (
 	"encoding/csv"
 	"flag"
-	"fmt"
 	"io"
 	"log"
-	"math/rand"
 	"os"
+	"strings"
 	"time"
 
-	lorem "github.com/drhodes/golorem"
-	"github.com/satori/go.uuid"
+	"github.com/damoon/eventstore-example/pkg/fake"
 )
 
 func main() {
@@ -20,35 +18,87 @@ func main() {
 	add := flag.Int("add", 15, "probability to add a row")
 	remove := flag.Int("remove", 10, "probability to remove a row")
 	modify := flag.Int("replace", 30, "probability to modify a row")
+	moves := flag.Int("moves", 0, "number of kept rows that move to another category, on top of the modified ones")
+	header := flag.Bool("header", false, "keep the first row as header")
+	categories := flag.Int("categories", 0, "size of the pool of categories, 0 generates a category per row")
+	categorySeed := flag.Int64("categorySeed", 0, "seed of the pool of categories")
+	zipf := flag.Float64("zipf", 0, "exponent above 1 to skew the categories, 0 picks them uniformly")
+	prices := flag.String("prices", fake.DefaultOptions.Prices, "price distribution: "+strings.Join(fake.Prices, ", "))
+	meanPrice := flag.Int64("meanPrice", fake.DefaultOptions.MeanPrice, "mean price in cents")
+	textSize := flag.String("text", fake.DefaultOptions.TextSize, "size of descriptions and long texts: "+strings.Join(fake.TextSizes, ", "))
 	flag.Parse()
 
 	log.Printf("seed: %d\n", *seed)
-	rand.Seed(*seed)
+	g, err := fake.New(*seed, fake.Options{
+		Categories:   *categories,
+		CategorySeed: *categorySeed,
+		Zipf:         *zipf,
+		Prices:       *prices,
+		MeanPrice:    *meanPrice,
+		TextSize:     *textSize,
+	})
+	if err != nil {
+		log.Panicf("error setting up generator: %s\n", err)
+	}
 
 	r := csv.NewReader(os.Stdin)
 	w := csv.NewWriter(os.Stdout)
 
+	if *header {
+		record, err := r.Read()
+		if err != nil {
+			log.Panicf("error reading header: %s\n", err)
+		}
+		err = w.Write(record)
+		if err != nil {
+			log.Panicf("error keeping header: %s\n", err)
+		}
+	}
+
+	// rows are kept in memory to pick the moves among all kept rows
+	rows := [][]string{}
+	kept := []int{}
+	// the categories of the kept rows in the input, a move differs from them even if the row got modified
+	originals := []string{}
 	for {
 		record, err := r.Read()
 		if err == io.EOF {
 			break
 		}
+		if err != nil {
+			log.Panicf("error reading row: %s\n", err)
+		}
 
-		if rand.Intn(100) < *add {
-			err := w.Write(newRow())
-			if err != nil {
-				log.Panicf("error adding new row: %s\n", err)
-			}
+		if g.Intn(100) < *add {
+			rows = append(rows, g.Row())
 		}
-		if rand.Intn(100) < *remove {
+		if g.Intn(100) < *remove {
 			continue
 		}
-		if rand.Intn(100) < *modify {
-			record = modifyRow(record)
+		original := record[4]
+		if g.Intn(100) < *modify {
+			record = modifyRow(g, record)
 		}
-		err = w.Write(record)
+		rows = append(rows, record)
+		kept = append(kept, len(rows)-1)
+		originals = append(originals, original)
+	}
+
+	if *moves > len(kept) {
+		log.Panicf("error moving rows: %d moves requested, only %d rows kept\n", *moves, len(kept))
+	}
+	for _, i := range g.Perm(len(kept))[:*moves] {
+		row := rows[kept[i]]
+		row[4], err = g.OtherCategory(originals[i])
 		if err != nil {
-			log.Panicf("error keeping row: %s\n", err)
+			log.Panicf("error moving row: %s\n", err)
+		}
+	}
+
+	for _, row := range rows {
+		err := w.Write(row)
+		if err != nil {
+			log.Panicf("error writing row: %s\n", err)
 		}
 	}
 
@@ -58,42 +108,27 @@ func main() {
 	}
 }
 
-func modifyRow(in []string) []string {
-	if rand.Intn(2) == 1 {
-		in[1] = fmt.Sprintf("%s %s %s", lorem.Word(4, 13), lorem.Word(4, 13), lorem.Word(4, 13))
+func modifyRow(g *fake.Generator, in []string) []string {
+	if g.Intn(2) == 1 {
+		in[1] = g.Title()
 	}
-	if rand.Intn(2) == 1 {
-		in[2] = lorem.Sentence(12, 24)
+	if g.Intn(2) == 1 {
+		in[2] = g.Description()
 	}
-	if rand.Intn(2) == 1 {
-		in[3] = lorem.Paragraph(3, 6)
+	if g.Intn(2) == 1 {
+		in[3] = g.Longtext()
 	}
-	if rand.Intn(2) == 1 {
-		in[4] = fmt.Sprintf("%s/%s", lorem.Word(4, 13), lorem.Word(4, 13))
+	if g.Intn(2) == 1 {
+		in[4] = g.Category()
 	}
-	if rand.Intn(2) == 1 {
-		in[5] = lorem.Url()
+	if g.Intn(2) == 1 {
+		in[5] = g.URL()
 	}
-	if rand.Intn(2) == 1 {
-		in[6] = lorem.Url()
+	if g.Intn(2) == 1 {
+		in[6] = g.URL()
 	}
-	if rand.Intn(2) == 1 {
-		in[7] = fmt.Sprintf("%.2f", float64(rand.Intn(10000))/100)
+	if g.Intn(2) == 1 {
+		in[7] = g.Price()
 	}
 	return in
 }
-
-func newRow() []string {
-	UUID := uuid.NewV4()
-	price := float64(rand.Intn(10000)) / 100
-	return []string{
-		UUID.String(),
-		fmt.Sprintf("%s %s %s", lorem.Word(4, 13), lorem.Word(4, 13), lorem.Word(4, 13)),
-		lorem.Sentence(12, 24),
-		lorem.Paragraph(3, 6),
-		fmt.Sprintf("%s/%s", lorem.Word(4, 13), lorem.Word(4, 13)),
-		lorem.Url(),
-		lorem.Url(),
-		fmt.Sprintf("%.2f", price),
-	}
-}
@@ -3,29 +3,51 @@ package main
 import (
 	"encoding/csv"
 	"flag"
-	"fmt"
 	"log"
-	"math/rand"
 	"os"
+	"strings"
 	"time"
 
-	lorem "github.com/drhodes/golorem"
-	"github.com/satori/go.uuid"
+	"github.com/damoon/eventstore-example/pkg/fake"
 )
 
 func main() {
 
 	seed := flag.Int64("seed", time.Now().UnixNano(), "seed for random number generator")
 	rows := flag.Int("rows", 100000, "number of rows")
+	header := flag.Bool("header", false, "write a header row with the column names")
+	categories := flag.Int("categories", 0, "size of the pool of categories, 0 generates a category per row")
+	categorySeed := flag.Int64("categorySeed", 0, "seed of the pool of categories")
+	zipf := flag.Float64("zipf", 0, "exponent above 1 to skew the categories, 0 picks them uniformly")
+	prices := flag.String("prices", fake.DefaultOptions.Prices, "price distribution: "+strings.Join(fake.Prices, ", "))
+	meanPrice := flag.Int64("meanPrice", fake.DefaultOptions.MeanPrice, "mean price in cents")
+	textSize := flag.String("text", fake.DefaultOptions.TextSize, "size of descriptions and long texts: "+strings.Join(fake.TextSizes, ", "))
 	flag.Parse()
 
 	log.Printf("seed: %d\n", *seed)
-	rand.Seed(*seed)
+	g, err := fake.New(*seed, fake.Options{
+		Categories:   *categories,
+		CategorySeed: *categorySeed,
+		Zipf:         *zipf,
+		Prices:       *prices,
+		MeanPrice:    *meanPrice,
+		TextSize:     *textSize,
+	})
+	if err != nil {
+		log.Panicf("error setting up generator: %s\n", err)
+	}
 
 	w := csv.NewWriter(os.Stdout)
 
+	if *header {
+		err := w.Write(columns)
+		if err != nil {
+			log.Panicf("error adding header: %s\n", err)
+		}
+	}
+
 	for i := 0; i < *rows; i++ {
-		err := w.Write(newRow())
+		err := w.Write(g.Row())
 		if err != nil {
 			log.Panicf("error adding row: %s\n", err)
 		}
@@ -37,17 +59,4 @@ func main() {
 	}
 }
 
-func newRow() []string {
-	UUID := uuid.NewV4()
-	price := float64(rand.Intn(10000)) / 100
-	return []string{
-		UUID.String(),
-		fmt.Sprintf("%s %s %s", lorem.Word(4, 13), lorem.Word(4, 13), lorem.Word(4, 13)),
-		lorem.Sentence(12, 24),
-		lorem.Paragraph(3, 6),
-		fmt.Sprintf("%s/%s", lorem.Word(4, 13), lorem.Word(4, 13)),
-		lorem.Url(),
-		lorem.Url(),
-		fmt.Sprintf("%.2f", price),
-	}
-}
+var columns = []string{"uuid", "title", "description", "longtext", "category", "smallImageURL", "largeImageURL", "price"}
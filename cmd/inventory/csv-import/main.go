@@ -1,46 +1,172 @@
 package main
 
 import (
-	"encoding/csv"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"strconv"
+	"time"
 
 	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/feed"
 	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/product"
+	"github.com/damoon/eventstore-example/pkg/simba"
+	"github.com/damoon/eventstore-example/pkg/tenant"
 	"github.com/golang/protobuf/proto"
+	"github.com/satori/go.uuid"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
-	brokerList   = kingpin.Flag("brokerList", "List of brokers to connect").Default("kafka:9092").Strings()
-	topic        = kingpin.Flag("topic", "Topic name").Default("products").String()
-	verbose      = kingpin.Flag("verbose", "Verbosity").Default("false").Bool()
-	currentPath  = kingpin.Arg("current", "path to current import file").Required().String()
-	previousPath = kingpin.Arg("previous", "path to previous import file").Default("/dev/null").String()
+	brokerList      = kingpin.Flag("brokerList", "List of brokers to connect").Default("kafka:9092").Strings()
+	topic           = kingpin.Flag("topic", "Topic name").Default("products").String()
+	importsTopic    = kingpin.Flag("importsTopic", "Topic name for import run events").Default("imports").String()
+	verbose         = kingpin.Flag("verbose", "Verbosity").Default("false").Bool()
+	txnID           = kingpin.Flag("transactionalID", "Publish the whole import in one kafka transaction using this id").Default("").String()
+	rejectPath      = kingpin.Flag("rejectReport", "Write rejected rows and the reasons to this csv file").Default("").String()
+	maxErrorRate    = kingpin.Flag("maxErrorRate", "Abort if the share of rejected rows of a file exceeds this rate, by default any rejected row aborts").Default("0").Float64()
+	defaultCurrency = kingpin.Flag("currency", "ISO 4217 currency of prices without currency column").Default("EUR").String()
+	format          = kingpin.Flag("format", "Format of the import files: csv, jsonl, xml or parquet").Default("csv").String()
+	tenantID        = kingpin.Flag("tenant", "Catalogue the products belong to, empty for the default catalogue").Default("").String()
+	mappingPath     = kingpin.Flag("mapping", "YAML or JSON file describing header, delimiter, encoding and column mapping of the import files").Default("").String()
+	currentPath     = kingpin.Arg("current", "path to current import file").Required().String()
+	previousPath    = kingpin.Arg("previous", "path to previous import file").Default("/dev/null").String()
 )
 
 func main() {
 
 	kingpin.Parse()
 
+	err := tenant.Validate(*tenantID)
+	if err != nil {
+		log.Panicf("failed to parse flags: %s", err)
+	}
+
+	log.Printf("tenant %s", tenant.Name(*tenantID))
 	log.Printf("current import file %s", *currentPath)
 	log.Printf("previous import file %s", *previousPath)
 
+	var input chan<- *sarama.ProducerMessage
+	var finish, abort func()
+	if *txnID == "" {
+		input, finish, abort = asyncProducer()
+	} else {
+		input, finish, abort = transactionalProducer()
+	}
+	fail := func(format string, v ...interface{}) {
+		abort()
+		log.Panicf(format, v...)
+	}
+
+	report, err := newRejectReport(*rejectPath)
+	if err != nil {
+		fail("failed to create reject report: %s", err)
+	}
+	l, err := feed.LoadLayout(*mappingPath)
+	if err != nil {
+		fail("failed to load column mapping: %s", err)
+	}
+	prevProducts, err := load(*previousPath, l, report)
+	if err != nil {
+		fail("failed to load previous import file: %s", err)
+	}
+	currentProducts, err := load(*currentPath, l, report)
+	if err != nil {
+		fail("failed to load current import file: %s", err)
+	}
+	err = report.Close()
+	if err != nil {
+		fail("failed to write reject report: %s", err)
+	}
+
+	runID := uuid.NewV4().String()
+	log.Printf("import run %s", runID)
+
+	started, err := importStarted(runID)
+	if err != nil {
+		fail("failed to prepare import started event: %s", err)
+	}
+	err = sendImportEvent(input, runID, &pb.ImportEvent{Event: &pb.ImportEvent_Started{Started: started}})
+	if err != nil {
+		fail("failed to send import started event: %s", err)
+	}
+
+	counts, err := product.Diff(prevProducts, currentProducts, func(UUID string, msg *pb.ProductUpdate) error {
+		if *verbose {
+			log.Printf("%s product %s\n", operation(msg), UUID)
+		}
+		return sendUpdate(input, UUID, msg, runID)
+	})
+	if err != nil {
+		fail("failed to send update massage: %s", err)
+	}
+
+	completed := &pb.ImportCompleted{
+		RunID:       runID,
+		Inserts:     counts.Inserts,
+		Updates:     counts.Updates,
+		Deletes:     counts.Deletes,
+		CompletedAt: time.Now().Unix(),
+		Tenant:      *tenantID,
+	}
+	err = sendImportEvent(input, runID, &pb.ImportEvent{Event: &pb.ImportEvent_Completed{Completed: completed}})
+	if err != nil {
+		fail("failed to send import completed event: %s", err)
+	}
+
+	finish()
+
+	log.Printf("import run %s: %d inserts, %d updates, %d deletes", runID, counts.Inserts, counts.Updates, counts.Deletes)
+}
+
+func importStarted(runID string) (*pb.ImportStarted, error) {
+	currentChecksum, err := checksum(*currentPath)
+	if err != nil {
+		return nil, err
+	}
+	previousChecksum, err := checksum(*previousPath)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ImportStarted{
+		RunID:            runID,
+		CurrentPath:      *currentPath,
+		CurrentChecksum:  currentChecksum,
+		PreviousPath:     *previousPath,
+		PreviousChecksum: previousChecksum,
+		StartedAt:        time.Now().Unix(),
+		Tenant:           *tenantID,
+	}, nil
+}
+
+func checksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %s", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("failed to hash %s: %s", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func asyncProducer() (chan<- *sarama.ProducerMessage, func(), func()) {
 	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Flush.MaxMessages = 500
+	config.Producer.Idempotent = true
+	config.Net.MaxOpenRequests = 1
 	producer, err := sarama.NewAsyncProducer(*brokerList, config)
 	if err != nil {
 		log.Panicf("failed to setup the kafka producer: %s", err)
 	}
-	defer func() {
-		if err := producer.Close(); err != nil {
-			log.Panicf("failed to close the kafka producer: %s", err)
-		}
-	}()
 
 	go func() {
 		for err := range producer.Errors() {
@@ -48,87 +174,87 @@ func main() {
 		}
 	}()
 
-	prevProducts, err := rows(*previousPath)
+	return producer.Input(), func() {
+			if err := producer.Close(); err != nil {
+				log.Panicf("failed to close the kafka producer: %s", err)
+			}
+		}, func() {
+			// sent messages can not be taken back without a transaction
+			producer.AsyncClose()
+		}
+}
+
+func transactionalProducer() (chan<- *sarama.ProducerMessage, func(), func()) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	client, err := sarama.NewClient(*brokerList, config)
 	if err != nil {
-		log.Panicf("failed to load previous import file: %s", err)
+		log.Panicf("failed to setup the kafka client: %s", err)
 	}
-	currentProducts, err := rows(*currentPath)
+	producer, err := simba.NewTransactionalProducer(client, *txnID)
 	if err != nil {
-		log.Panicf("failed to load current import file: %s", err)
+		log.Panicf("failed to setup the transactional kafka producer: %s", err)
+	}
+	err = producer.Begin()
+	if err != nil {
+		log.Panicf("failed to begin transaction: %s", err)
 	}
 
-	upsert(prevProducts, currentProducts, producer.Input())
-	remove(prevProducts, producer.Input())
-
-}
-
-func upsert(prevProducts, currentProducts map[string][]string, ch chan<- *sarama.ProducerMessage) {
-	for _, currentRow := range currentProducts {
-
-		UUID := currentRow[0]
-
-		prevRow, prevFound := prevProducts[UUID]
-
-		if equal(prevRow, currentRow) {
-			if *verbose {
-				log.Printf("skip unchanged product %s\n", UUID)
+	ch := make(chan *sarama.ProducerMessage)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range ch {
+			err := producer.Send(msg)
+			if err != nil {
+				abortTransaction(producer)
+				log.Panicf("failed to send msg (key %s): %s", msg.Key, err)
 			}
-			continue
 		}
+	}()
 
-		if *verbose {
-			ll := "insert product %s\n"
-			if prevFound {
-				ll = "update product %s\n"
+	return ch, func() {
+			close(ch)
+			<-done
+			if err := producer.Commit(); err != nil {
+				abortTransaction(producer)
+				log.Panicf("failed to commit transaction: %s", err)
 			}
-			log.Printf(ll, UUID)
-		}
-
-		prev, err := row2product(prevRow)
-		if err != nil {
-			log.Panicf("failed to serialize previous product %s: %s", UUID, err)
-		}
-		curr, err := row2product(currentRow)
-		if err != nil {
-			log.Panicf("failed to serialize previous product %s: %s", UUID, err)
-		}
-		msg := &pb.ProductUpdate{
-			Old: prev,
-			New: curr,
-		}
-
-		err = sendUpdate(ch, UUID, msg)
-		if err != nil {
-			log.Panicf("failed to send update massage: %s", err)
+			log.Printf("committed transaction %s", *txnID)
+			if err := producer.Close(); err != nil {
+				log.Panicf("failed to close the kafka producer: %s", err)
+			}
+		}, func() {
+			close(ch)
+			<-done
+			abortTransaction(producer)
 		}
+}
 
-		delete(prevProducts, UUID)
+// abortTransaction discards the messages of the import, consumers reading committed never see them
+func abortTransaction(producer *simba.TransactionalProducer) {
+	if err := producer.Abort(); err != nil {
+		log.Printf("failed to abort transaction %s: %s", *txnID, err)
+	} else {
+		log.Printf("aborted transaction %s", *txnID)
+	}
+	if err := producer.Close(); err != nil {
+		log.Printf("failed to close the kafka producer: %s", err)
 	}
 }
 
-func remove(prevProducts map[string][]string, ch chan<- *sarama.ProducerMessage) {
-	for _, prevRow := range prevProducts {
-		UUID := prevRow[0]
-		if *verbose {
-			log.Printf("delete product %s\n", UUID)
-		}
-
-		prev, err := row2product(prevRow)
-		if err != nil {
-			log.Panicf("failed to serialize previous product %s: %s", UUID, err)
-		}
-		msg := &pb.ProductUpdate{
-			Old: prev,
-		}
-
-		err = sendUpdate(ch, UUID, msg)
-		if err != nil {
-			log.Panicf("failed to send update massage: %s", err)
-		}
+func operation(msg *pb.ProductUpdate) string {
+	switch {
+	case msg.Old == nil:
+		return "insert"
+	case msg.New == nil:
+		return "delete"
 	}
+	return "update"
 }
 
-func sendUpdate(ch chan<- *sarama.ProducerMessage, UUID string, msg *pb.ProductUpdate) error {
+func sendUpdate(ch chan<- *sarama.ProducerMessage, UUID string, msg *pb.ProductUpdate, runID string) error {
+	msg.Tenant = *tenantID
 	bytes, err := proto.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to serialize product delete massage: %s", err)
@@ -137,74 +263,52 @@ func sendUpdate(ch chan<- *sarama.ProducerMessage, UUID string, msg *pb.ProductU
 		Topic: *topic,
 		Key:   sarama.StringEncoder(UUID),
 		Value: sarama.ByteEncoder(bytes),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(pb.ImportRunHeader), Value: []byte(runID)},
+			{Key: []byte(tenant.Header), Value: []byte(*tenantID)},
+		},
 	}
 	return nil
 }
 
-func equal(a, b []string) bool {
-
-	// If one is nil, the other must also be nil.
-	if (a == nil) != (b == nil) {
-		return false
-	}
-
-	if len(a) != len(b) {
-		return false
+func sendImportEvent(ch chan<- *sarama.ProducerMessage, runID string, msg *pb.ImportEvent) error {
+	bytes, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to serialize import event: %s", err)
 	}
-
-	for i := range a {
-		if a[i] != b[i] {
-			return false
-		}
+	ch <- &sarama.ProducerMessage{
+		Topic:   *importsTopic,
+		Key:     sarama.StringEncoder(runID),
+		Value:   sarama.ByteEncoder(bytes),
+		Headers: []sarama.RecordHeader{{Key: []byte(tenant.Header), Value: []byte(*tenantID)}},
 	}
-
-	return true
+	return nil
 }
 
-func rows(path string) (map[string][]string, error) {
+func load(path string, l *feed.Layout, report *rejectReport) (map[string]*pb.Product, error) {
 
-	f, err := os.Open(path)
+	r, err := feed.Open(*format, path, l, *defaultCurrency)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open previous import file: %s", err)
+		return nil, err
 	}
-	defer f.Close()
+	defer r.Close()
 
-	m := make(map[string][]string)
-	r := csv.NewReader(f)
-	for {
-		row, err := r.Read()
-		if err == io.EOF {
-			break
-		}
-		uuid := row[0]
-
-		if _, ok := m[uuid]; ok {
-			return nil, fmt.Errorf("product doublication in import list")
-		}
-
-		m[uuid] = row
+	rejected := 0
+	m, total, err := feed.Load(r, func(e *feed.RecordError) error {
+		rejected++
+		return report.reject(path, e.Pos, e.Record, e.Reasons)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return m, nil
-}
 
-func row2product(row []string) (*pb.Product, error) {
-
-	if row == nil {
-		return nil, nil
+	if rejected > 0 {
+		rate := float64(rejected) / float64(total)
+		log.Printf("rejected %d of %d records (%.2f%%) in %s", rejected, total, rate*100, path)
+		if rate > *maxErrorRate {
+			return nil, fmt.Errorf("error rate %.4f exceeds threshold %.4f", rate, *maxErrorRate)
+		}
 	}
 
-	price, err := strconv.ParseFloat(row[7], 32)
-	if err != nil {
-		return &pb.Product{}, err
-	}
-	return &pb.Product{
-		Uuid:          row[0],
-		Title:         row[1],
-		Description:   row[2],
-		Longtext:      row[3],
-		Category:      row[4],
-		SmallImageURL: row[5],
-		LargeImageURL: row[6],
-		Price:         float32(price),
-	}, nil
+	return m, nil
 }
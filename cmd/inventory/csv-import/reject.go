@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// rejectReport collects rows that failed validation
+type rejectReport struct {
+	f *os.File
+	w *csv.Writer
+}
+
+func newRejectReport(path string) (*rejectReport, error) {
+	if path == "" {
+		return &rejectReport{}, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %s", path, err)
+	}
+	return &rejectReport{
+		f: f,
+		w: csv.NewWriter(f),
+	}, nil
+}
+
+func (r *rejectReport) reject(path string, line int, row []string, reasons []string) error {
+	if *verbose || r.w == nil {
+		log.Printf("reject %s:%d: %s", path, line, strings.Join(reasons, "; "))
+	}
+	if r.w == nil {
+		return nil
+	}
+
+	record := append([]string{path, strconv.Itoa(line), strings.Join(reasons, "; ")}, row...)
+	err := r.w.Write(record)
+	if err != nil {
+		return fmt.Errorf("failed to write reject report: %s", err)
+	}
+	return nil
+}
+
+func (r *rejectReport) Close() error {
+	if r.w == nil {
+		return nil
+	}
+	r.w.Flush()
+	if err := r.w.Error(); err != nil {
+		return err
+	}
+	return r.f.Close()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/simba"
+	"github.com/damoon/eventstore-example/pkg/tenant"
+	"github.com/golang/protobuf/proto"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	brokerList        = kingpin.Flag("brokerList", "List of brokers to connect").Default("localhost:9092").Strings()
+	topic             = kingpin.Flag("topic", "Topic name").Default("products").String()
+	currentTopic      = kingpin.Flag("currentTopic", "Log compacted topic of the latest products").Default("current-products").String()
+	partitions        = kingpin.Flag("partitions", "Partitions of the compacted topic if it gets created").Default("6").Int32()
+	replicationFactor = kingpin.Flag("replicationFactor", "Replication factor of the compacted topic if it gets created").Default("3").Int16()
+	legacy            = kingpin.Flag("legacyCurrency", "ISO 4217 currency of the float prices of products published before prices carried a currency, reading such a product fails without it").Default("").String()
+)
+
+func main() {
+	kingpin.Parse()
+	pb.LegacyCurrency = *legacy
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Idempotent = true
+	config.Producer.Return.Successes = true
+	config.Net.MaxOpenRequests = 1
+
+	err := createCompactedTopic(config)
+	if err != nil {
+		log.Panicf("failed to setup topic %s: %s", *currentTopic, err)
+	}
+
+	producer, err := sarama.NewSyncProducer(*brokerList, config)
+	if err != nil {
+		log.Panicf("failed to setup kafka producer: %s", err)
+	}
+	defer func() {
+		if err := producer.Close(); err != nil {
+			log.Panicf("failed to close kafka producer: %s", err)
+		}
+	}()
+
+	consumerConfig := sarama.NewConfig()
+	consumerConfig.Version = sarama.V1_1_0_0
+	consumerConfig.Consumer.IsolationLevel = sarama.ReadCommitted
+	consumerConfig.Consumer.Return.Errors = true
+	consumerConfig.Consumer.Offsets.Initial = sarama.OffsetOldest
+	topics := []string{*topic}
+	consumer, err := sarama.NewConsumerGroup(*brokerList, "inventory-current-products-v1", consumerConfig)
+	if err != nil {
+		log.Panicf("failed to setup kafka consumer group: %s", err)
+	}
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			log.Panicf("failed to close kafka consumer group: %s", err)
+		}
+	}()
+
+	v := func(msg *sarama.ConsumerMessage) error {
+		return view(producer, msg)
+	}
+	// compaction keeps the last write per key, updates of a product must not overtake each other
+	simba := simba.NewOrderedConsumer(nil, v)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		<-signals
+		log.Print("interrupt is detected")
+		cancel()
+	}()
+
+	err = simba.Run(ctx, consumer, topics)
+	if err != nil {
+		log.Panicf("failed to consume: %s", err)
+	}
+}
+
+func createCompactedTopic(config *sarama.Config) error {
+	admin, err := sarama.NewClusterAdmin(*brokerList, config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to kafka: %s", err)
+	}
+	defer admin.Close()
+
+	compact := "compact"
+	err = admin.CreateTopic(*currentTopic, &sarama.TopicDetail{
+		NumPartitions:     *partitions,
+		ReplicationFactor: *replicationFactor,
+		ConfigEntries:     map[string]*string{"cleanup.policy": &compact},
+	}, false)
+	if e, ok := err.(*sarama.TopicError); ok && e.Err == sarama.ErrTopicAlreadyExists {
+		return checkCompaction(admin)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("created topic %s", *currentTopic)
+	return nil
+}
+
+func checkCompaction(admin sarama.ClusterAdmin) error {
+	entries, err := admin.DescribeConfig(sarama.ConfigResource{
+		Type:        sarama.TopicResource,
+		Name:        *currentTopic,
+		ConfigNames: []string{"cleanup.policy"},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe topic config: %s", err)
+	}
+	for _, e := range entries {
+		if e.Name == "cleanup.policy" && e.Value != "compact" {
+			return fmt.Errorf("topic exists with cleanup.policy %s, expected compact", e.Value)
+		}
+	}
+	return nil
+}
+
+func view(producer sarama.SyncProducer, msg *sarama.ConsumerMessage) error {
+
+	p := pb.ProductUpdate{}
+	err := proto.Unmarshal(msg.Value, &p)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal kafka massaga: %s", err)
+	}
+	err = pb.UpcastProductUpdate(&p)
+	if err != nil {
+		return err
+	}
+
+	UUID := string(msg.Key)
+
+	// products of different tenants may share a uuid
+	current := &sarama.ProducerMessage{
+		Topic:   *currentTopic,
+		Key:     sarama.StringEncoder(tenant.Key(p.Tenant, UUID)),
+		Headers: []sarama.RecordHeader{{Key: []byte(tenant.Header), Value: []byte(p.Tenant)}},
+	}
+
+	// a nil value is a tombstone, compaction removes the product eventually
+	if p.New != nil {
+		bytes, err := proto.Marshal(p.New)
+		if err != nil {
+			return fmt.Errorf("failed to marshal the prduct %s: %s", UUID, err)
+		}
+		current.Value = sarama.ByteEncoder(bytes)
+	}
+
+	_, _, err = producer.SendMessage(current)
+	if err != nil {
+		return fmt.Errorf("failed to publish current state of %s: %s", UUID, err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/damoon/eventstore-example/pkg/simba"
+	"github.com/damoon/eventstore-example/pkg/tenant"
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const runsKey = "imports"
+const runKeyPrefix = "imports:"
+
+var (
+	redisAddress  = kingpin.Flag("redisAddress", "Redis Host, the sentinels or cluster nodes").Default("redis:6379").Strings()
+	redisPassword = kingpin.Flag("redisPassword", "Redis Password").Default("").String()
+	redisDatabase = kingpin.Flag("redisDatabase", "Redis Database").Default("0").Int()
+	redisMaster   = kingpin.Flag("redisMaster", "Name of the master monitored by the redis sentinels").Default("").String()
+	redisCluster  = kingpin.Flag("redisCluster", "Connect to a redis cluster").Default("false").Bool()
+
+	viewCmd    = kingpin.Command("view", "Record import runs from kafka into redis")
+	brokerList = viewCmd.Flag("brokerList", "List of brokers to connect").Default("localhost:9092").Strings()
+	topic      = viewCmd.Flag("topic", "Topic name").Default("imports").String()
+
+	listCmd    = kingpin.Command("list", "List import runs and their results")
+	limit      = listCmd.Flag("limit", "Number of runs to list").Default("20").Int64()
+	listTenant = listCmd.Flag("tenant", "Tenant of the import runs, empty for the default catalogue").Default("").String()
+)
+
+func main() {
+	cmd := kingpin.Parse()
+
+	r, err := redisclient.New(redisclient.Options{
+		Addresses:  *redisAddress,
+		MasterName: *redisMaster,
+		Cluster:    *redisCluster,
+		Password:   *redisPassword,
+		Database:   *redisDatabase,
+	})
+	if err != nil {
+		log.Panicf("failed to setup redis client: %s", err)
+	}
+
+	switch cmd {
+	case viewCmd.FullCommand():
+		consume(r)
+	case listCmd.FullCommand():
+		err := list(r, *listTenant, *limit)
+		if err != nil {
+			log.Panicf("failed to list import runs: %s", err)
+		}
+	}
+}
+
+func consume(r redis.UniversalClient) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	topics := []string{*topic}
+	consumer, err := sarama.NewConsumerGroup(*brokerList, "inventory-imports-v1", config)
+	if err != nil {
+		log.Panicf("failed to setup kafka consumer group: %s", err)
+	}
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			log.Panicf("failed to close kafka consumer group: %s", err)
+		}
+	}()
+
+	v := func(msg *sarama.ConsumerMessage) error {
+		return view(r, msg)
+	}
+	simba := simba.NewConsumer(nil, v)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		<-signals
+		log.Print("interrupt is detected")
+		cancel()
+	}()
+
+	err = simba.Run(ctx, consumer, topics)
+	if err != nil {
+		log.Panicf("failed to consume: %s", err)
+	}
+}
+
+func view(r redis.UniversalClient, msg *sarama.ConsumerMessage) error {
+
+	e := pb.ImportEvent{}
+	err := proto.Unmarshal(msg.Value, &e)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal kafka massaga: %s", err)
+	}
+
+	runID := string(msg.Key)
+
+	switch {
+	case e.GetStarted() != nil:
+		bytes, err := proto.Marshal(e.GetStarted())
+		if err != nil {
+			return fmt.Errorf("failed to marshal import start of %s: %s", runID, err)
+		}
+		t := e.GetStarted().Tenant
+		err = r.HSet(tenant.Key(t, runKeyPrefix+runID), "started", bytes).Err()
+		if err != nil {
+			return fmt.Errorf("failed to record import start of %s: %s", runID, err)
+		}
+		err = r.ZAdd(tenant.Key(t, runsKey), redis.Z{Score: float64(e.GetStarted().StartedAt), Member: runID}).Err()
+		if err != nil {
+			return fmt.Errorf("failed to index import run %s: %s", runID, err)
+		}
+
+	case e.GetCompleted() != nil:
+		bytes, err := proto.Marshal(e.GetCompleted())
+		if err != nil {
+			return fmt.Errorf("failed to marshal import completion of %s: %s", runID, err)
+		}
+		err = r.HSet(tenant.Key(e.GetCompleted().Tenant, runKeyPrefix+runID), "completed", bytes).Err()
+		if err != nil {
+			return fmt.Errorf("failed to record import completion of %s: %s", runID, err)
+		}
+	}
+
+	return nil
+}
+
+func list(r redis.UniversalClient, t string, limit int64) error {
+	runIDs, err := r.ZRevRange(tenant.Key(t, runsKey), 0, limit-1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to load import runs: %s", err)
+	}
+
+	for _, runID := range runIDs {
+		fields, err := r.HGetAll(tenant.Key(t, runKeyPrefix+runID)).Result()
+		if err != nil {
+			return fmt.Errorf("failed to load import run %s: %s", runID, err)
+		}
+
+		started := pb.ImportStarted{}
+		err = proto.Unmarshal([]byte(fields["started"]), &started)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal import start of %s: %s", runID, err)
+		}
+
+		startedAt := time.Unix(started.StartedAt, 0).Format(time.RFC3339)
+
+		c, ok := fields["completed"]
+		if !ok {
+			fmt.Printf("%s %s incomplete %s\n", runID, startedAt, started.CurrentPath)
+			continue
+		}
+
+		completed := pb.ImportCompleted{}
+		err = proto.Unmarshal([]byte(c), &completed)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal import completion of %s: %s", runID, err)
+		}
+
+		fmt.Printf("%s %s completed %s (sha256 %s) in %ds: %d inserts, %d updates, %d deletes\n",
+			runID, startedAt, started.CurrentPath, started.CurrentChecksum,
+			completed.CompletedAt-started.StartedAt,
+			completed.Inserts, completed.Updates, completed.Deletes)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/damoon/eventstore-example/pkg/simba"
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+const productStockKeyPrefix = "product-stock:"
+
+var (
+	brokerList        = kingpin.Flag("brokerList", "List of brokers to connect").Default("localhost:9092").Strings()
+	productsTopic     = kingpin.Flag("productsTopic", "Topic of the product updates").Default("products").String()
+	stockTopic        = kingpin.Flag("stockTopic", "Topic of the stock events").Default("stock").String()
+	group             = kingpin.Flag("group", "Consumer group").Default("inventory-product-stock-v1").String()
+	stateDir          = kingpin.Flag("stateDir", "Directory of the local state stores").Default("/var/lib/inventory/state").String()
+	replicationFactor = kingpin.Flag("replicationFactor", "Replication factor of the changelog topics").Default("1").Int16()
+	redisAddress      = kingpin.Flag("redisAddress", "Redis Host, the sentinels or cluster nodes").Default("redis:6379").Strings()
+	redisPassword     = kingpin.Flag("redisPassword", "Redis Password").Default("").String()
+	redisDatabase     = kingpin.Flag("redisDatabase", "Redis Database").Default("0").Int()
+	redisMaster       = kingpin.Flag("redisMaster", "Name of the master monitored by the redis sentinels").Default("").String()
+	redisCluster      = kingpin.Flag("redisCluster", "Connect to a redis cluster").Default("false").Bool()
+)
+
+// product-stock joins every stock event with the latest product of its uuid
+func main() {
+	kingpin.Parse()
+
+	r, err := redisclient.New(redisclient.Options{
+		Addresses:  *redisAddress,
+		MasterName: *redisMaster,
+		Cluster:    *redisCluster,
+		Password:   *redisPassword,
+		Database:   *redisDatabase,
+	})
+	if err != nil {
+		log.Panicf("failed to setup redis client: %s", err)
+	}
+
+	stores, err := simba.NewStores(*brokerList, *stateDir, *group, *replicationFactor)
+	if err != nil {
+		log.Panicf("failed to setup state stores: %s", err)
+	}
+	defer stores.Close()
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Group.Rebalance.Strategy = sarama.BalanceStrategyRange
+	topics := []string{*stockTopic, *productsTopic}
+	consumer, err := sarama.NewConsumerGroup(*brokerList, *group, config)
+	if err != nil {
+		log.Panicf("failed to setup kafka consumer group: %s", err)
+	}
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			log.Panicf("failed to close kafka consumer group: %s", err)
+		}
+	}()
+
+	v := func(msg *sarama.ConsumerMessage, row []byte) error {
+		return view(r, msg, row)
+	}
+	simba, err := simba.NewJoinConsumer(stores, *stockTopic, *productsTopic, v)
+	if err != nil {
+		log.Panicf("failed to setup join: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		<-signals
+		log.Print("interrupt is detected")
+		cancel()
+	}()
+
+	err = simba.Run(ctx, consumer, topics)
+	if err != nil {
+		log.Panicf("failed to consume: %s", err)
+	}
+}
+
+// view writes the stock level with title and category of the product, if it is known yet
+func view(r redis.UniversalClient, msg *sarama.ConsumerMessage, row []byte) error {
+
+	e := pb.StockEvent{}
+	err := proto.Unmarshal(msg.Value, &e)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal kafka massaga: %s", err)
+	}
+
+	UUID := string(msg.Key)
+	l := e.GetLevel()
+	fields := map[string]interface{}{
+		"version":   e.Version,
+		"available": l.GetOnHand() - l.GetReserved(),
+	}
+
+	if row != nil {
+		p := pb.ProductUpdate{}
+		err := proto.Unmarshal(row, &p)
+		if err != nil {
+			return fmt.Errorf("failed to unmarshal product %s: %s", UUID, err)
+		}
+		if p.New != nil {
+			fields["title"] = p.New.Title
+			fields["category"] = p.New.Category
+		}
+	}
+
+	err = r.HMSet(productStockKeyPrefix+UUID, fields).Err()
+	if err != nil {
+		return fmt.Errorf("failed to write stock of product %s to redis: %s", UUID, err)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/damoon/eventstore-example/pkg/snapshot"
+	"github.com/go-redis/redis"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+// productKeyPattern matches product uuids, optionally prefixed by their tenant
+var productKeyPattern = regexp.MustCompile(`^([a-z0-9][a-z0-9_-]*:)?[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+
+// categoryKeyPattern matches the hash tagged category sets of all tenants
+var categoryKeyPattern = regexp.MustCompile(`^\{([a-z0-9][a-z0-9_-]*:)?categories:.+\}:members$`)
+
+// projection names the consumer group, topic and redis keys of a view
+type projection struct {
+	group string
+	topic string
+	owns  snapshot.Owner
+}
+
+var projections = map[string]projection{
+	"products": {
+		group: "inventory-products-v1",
+		topic: "products",
+		owns: func(key, typ string) bool {
+			return typ == "string" && productKeyPattern.MatchString(key)
+		},
+	},
+	"categories": {
+		group: "inventory-categories-v2",
+		topic: "products",
+		owns: func(key, typ string) bool {
+			return typ == "set" && categoryKeyPattern.MatchString(key)
+		},
+	},
+	"stock": {
+		group: "inventory-stock-v1",
+		topic: "stock",
+		owns: func(key, typ string) bool {
+			return typ == "hash" && strings.HasPrefix(key, "stock:")
+		},
+	},
+}
+
+var (
+	brokerList    = kingpin.Flag("brokerList", "List of brokers to connect").Default("localhost:9092").Strings()
+	redisAddress  = kingpin.Flag("redisAddress", "Redis Host, the sentinels or cluster nodes").Default("redis:6379").Strings()
+	redisPassword = kingpin.Flag("redisPassword", "Redis Password").Default("").String()
+	redisDatabase = kingpin.Flag("redisDatabase", "Redis Database").Default("0").Int()
+	redisMaster   = kingpin.Flag("redisMaster", "Name of the master monitored by the redis sentinels").Default("").String()
+	redisCluster  = kingpin.Flag("redisCluster", "Connect to a redis cluster").Default("false").Bool()
+	name          = kingpin.Flag("projection", "Projection to snapshot").Default("products").Enum("products", "categories", "stock")
+	group         = kingpin.Flag("group", "Consumer group of the projection, empty for its default group").Default("").String()
+	location      = kingpin.Flag("location", "Snapshot location, file:///path or s3://bucket/prefix").Default("file:///tmp/snapshots").String()
+	s3Endpoint    = kingpin.Flag("s3Endpoint", "S3 compatible endpoint, e.g. http://minio:9000").String()
+	s3Region      = kingpin.Flag("s3Region", "S3 region").Default("us-east-1").String()
+	s3AccessKey   = kingpin.Flag("s3AccessKey", "S3 access key").Envar("S3_ACCESS_KEY").String()
+	s3SecretKey   = kingpin.Flag("s3SecretKey", "S3 secret key").Envar("S3_SECRET_KEY").String()
+
+	dumpCmd  = kingpin.Command("dump", "Write the redis keys of the projection with the offsets of its consumer group")
+	interval = dumpCmd.Flag("interval", "Repeat the dump periodically, 0 dumps once").Default("0").Duration()
+
+	restoreCmd = kingpin.Command("restore", "Restore the redis keys of the projection and reset its consumer group to the offsets of the snapshot")
+	force      = restoreCmd.Flag("force", "Restore even if the consumer group has committed offsets").Bool()
+)
+
+func main() {
+	cmd := kingpin.Parse()
+
+	p := projections[*name]
+	if *group != "" {
+		p.group = *group
+	}
+
+	storage, err := snapshot.NewStorage(*location, snapshot.S3Options{
+		Endpoint:  *s3Endpoint,
+		Region:    *s3Region,
+		AccessKey: *s3AccessKey,
+		SecretKey: *s3SecretKey,
+	})
+	if err != nil {
+		log.Panicf("failed to setup snapshot storage: %s", err)
+	}
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	client, err := sarama.NewClient(*brokerList, config)
+	if err != nil {
+		log.Panicf("failed to connect to kafka: %s", err)
+	}
+	defer client.Close()
+
+	r, err := redisclient.New(redisclient.Options{
+		Addresses:  *redisAddress,
+		MasterName: *redisMaster,
+		Cluster:    *redisCluster,
+		Password:   *redisPassword,
+		Database:   *redisDatabase,
+	})
+	if err != nil {
+		log.Panicf("failed to setup redis client: %s", err)
+	}
+
+	switch cmd {
+	case dumpCmd.FullCommand():
+		for {
+			err := dump(client, r, storage, p)
+			if err != nil {
+				log.Panicf("failed to dump projection %s: %s", *name, err)
+			}
+			if *interval == 0 {
+				return
+			}
+			time.Sleep(*interval)
+		}
+	case restoreCmd.FullCommand():
+		err := restore(client, r, storage, p)
+		if err != nil {
+			log.Panicf("failed to restore projection %s: %s", *name, err)
+		}
+	}
+}
+
+// dump takes the offsets before the keys, replaying the tail converges because the views are idempotent
+func dump(client sarama.Client, r redis.UniversalClient, storage snapshot.Storage, p projection) error {
+	offsets, err := snapshot.CommittedOffsets(client, p.group, []string{p.topic})
+	if err != nil {
+		return err
+	}
+
+	w, err := storage.Create(*name + ".snapshot")
+	if err != nil {
+		return err
+	}
+	header := &pb.ProjectionSnapshot{
+		Group:     p.group,
+		CreatedAt: time.Now().Unix(),
+		Offsets:   offsets,
+	}
+	keys, err := snapshot.Dump(r, p.owns, header, w)
+	if err != nil {
+		w.Close()
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("dumped %d keys of %s at %s", keys, *name, formatOffsets(offsets))
+	return nil
+}
+
+func restore(client sarama.Client, r redis.UniversalClient, storage snapshot.Storage, p projection) error {
+	if !*force {
+		committed, err := snapshot.HasCommittedOffsets(client, p.group, []string{p.topic})
+		if err != nil {
+			return err
+		}
+		if committed {
+			return fmt.Errorf("consumer group %s has committed offsets already, stop the projection and use --force", p.group)
+		}
+	}
+
+	rd, err := storage.Open(*name + ".snapshot")
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+
+	header, keys, err := snapshot.Restore(r, p.owns, rd)
+	if err != nil {
+		return err
+	}
+	if header.Group != p.group {
+		log.Printf("warning: the snapshot was dumped for consumer group %s, its offsets are restored into %s", header.Group, p.group)
+	}
+
+	offsets, err := snapshot.OwnedOffsets(client, header.Offsets, []string{p.topic})
+	if err != nil {
+		return err
+	}
+	if len(offsets) != len(header.Offsets) {
+		log.Printf("warning: %d of %d offsets of the snapshot are not partitions of topic %s, they are skipped",
+			len(header.Offsets)-len(offsets), len(header.Offsets), p.topic)
+	}
+	err = snapshot.CommitOffsets(client, p.group, offsets)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("restored %d keys of %s from %s at %s", keys, *name,
+		time.Unix(header.CreatedAt, 0).Format(time.RFC3339), formatOffsets(offsets))
+	return nil
+}
+
+func formatOffsets(offsets []*pb.PartitionOffset) string {
+	parts := make([]string, len(offsets))
+	for i, o := range offsets {
+		parts[i] = fmt.Sprintf("%s/%d:%d", o.Topic, o.Partition, o.Offset)
+	}
+	return strings.Join(parts, " ")
+}
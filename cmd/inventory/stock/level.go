@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+)
+
+// level is the command side state of the stock of one product
+type level struct {
+	uuid         string
+	version      int64
+	onHand       int64
+	reserved     int64
+	reservations map[string]int64
+}
+
+// errInsufficientStock marks commands that would lead to negative stock
+type errInsufficientStock struct {
+	msg string
+}
+
+func (e errInsufficientStock) Error() string {
+	return e.msg
+}
+
+func newLevel(uuid string) *level {
+	return &level{
+		uuid:         uuid,
+		reservations: make(map[string]int64),
+	}
+}
+
+func (l *level) available() int64 {
+	return l.onHand - l.reserved
+}
+
+// apply updates the state with an event that already happened.
+// Like in the view the first event of a version wins, an event of a failed send
+// can land in the log after the next decision on the same version.
+func (l *level) apply(e *pb.StockEvent) {
+	if e.Version <= l.version {
+		return
+	}
+	switch {
+	case e.GetReceived() != nil:
+		l.onHand += e.GetReceived().Quantity
+	case e.GetReserved() != nil:
+		r := e.GetReserved()
+		l.reserved += r.Quantity
+		l.reservations[r.ReservationID] += r.Quantity
+	case e.GetReleased() != nil:
+		r := e.GetReleased()
+		l.reserved -= r.Quantity
+		l.reservations[r.ReservationID] -= r.Quantity
+		if l.reservations[r.ReservationID] == 0 {
+			delete(l.reservations, r.ReservationID)
+		}
+	case e.GetAdjusted() != nil:
+		l.onHand += e.GetAdjusted().Delta
+	}
+	l.version = e.Version
+}
+
+func (l *level) receive(quantity int64, reference string) (*pb.StockEvent, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("quantity needs to be positive, got %d", quantity)
+	}
+	e := l.event(l.onHand+quantity, l.reserved)
+	e.Event = &pb.StockEvent_Received{Received: &pb.StockReceived{Quantity: quantity, Reference: reference}}
+	return e, nil
+}
+
+func (l *level) reserve(quantity int64, reservationID string) (*pb.StockEvent, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("quantity needs to be positive, got %d", quantity)
+	}
+	if reservationID == "" {
+		return nil, fmt.Errorf("reservation id is required")
+	}
+	if quantity > l.available() {
+		return nil, errInsufficientStock{fmt.Sprintf("cannot reserve %d of %s, only %d available", quantity, l.uuid, l.available())}
+	}
+	e := l.event(l.onHand, l.reserved+quantity)
+	e.Event = &pb.StockEvent_Reserved{Reserved: &pb.StockReserved{Quantity: quantity, ReservationID: reservationID}}
+	return e, nil
+}
+
+func (l *level) release(quantity int64, reservationID string) (*pb.StockEvent, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("quantity needs to be positive, got %d", quantity)
+	}
+	if quantity > l.reservations[reservationID] {
+		return nil, errInsufficientStock{fmt.Sprintf("cannot release %d of %s, reservation %q holds %d", quantity, l.uuid, reservationID, l.reservations[reservationID])}
+	}
+	e := l.event(l.onHand, l.reserved-quantity)
+	e.Event = &pb.StockEvent_Released{Released: &pb.StockReleased{Quantity: quantity, ReservationID: reservationID}}
+	return e, nil
+}
+
+func (l *level) adjust(delta int64, reason string) (*pb.StockEvent, error) {
+	if delta == 0 {
+		return nil, fmt.Errorf("delta must not be zero")
+	}
+	if reason == "" {
+		return nil, fmt.Errorf("reason is required")
+	}
+	if l.onHand+delta < l.reserved {
+		return nil, errInsufficientStock{fmt.Sprintf("cannot adjust %s by %d, %d on hand and %d reserved", l.uuid, delta, l.onHand, l.reserved)}
+	}
+	e := l.event(l.onHand+delta, l.reserved)
+	e.Event = &pb.StockEvent_Adjusted{Adjusted: &pb.StockAdjusted{Delta: delta, Reason: reason}}
+	return e, nil
+}
+
+// event prepares the next event of the product with the resulting stock level
+func (l *level) event(onHand, reserved int64) *pb.StockEvent {
+	return &pb.StockEvent{
+		Uuid:       l.uuid,
+		Version:    l.version + 1,
+		OccurredAt: time.Now().Unix(),
+		Level: &pb.StockLevel{
+			OnHand:   onHand,
+			Reserved: reserved,
+		},
+	}
+}
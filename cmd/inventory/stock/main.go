@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/damoon/eventstore-example/pkg/simba"
+	"github.com/go-redis/redis"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	brokerList    = kingpin.Flag("brokerList", "List of brokers to connect").Default("localhost:9092").Strings()
+	topic         = kingpin.Flag("topic", "Topic name").Default("stock").String()
+	redisAddress  = kingpin.Flag("redisAddress", "Redis Host, the sentinels or cluster nodes").Default("redis:6379").Strings()
+	redisPassword = kingpin.Flag("redisPassword", "Redis Password").Default("").String()
+	redisDatabase = kingpin.Flag("redisDatabase", "Redis Database").Default("0").Int()
+	redisMaster   = kingpin.Flag("redisMaster", "Name of the master monitored by the redis sentinels").Default("").String()
+	redisCluster  = kingpin.Flag("redisCluster", "Connect to a redis cluster").Default("false").Bool()
+
+	serveCmd = kingpin.Command("serve", "Accept stock commands via http and publish the resulting events")
+	listen   = serveCmd.Flag("listen", "Address to listen on").Default(":8080").String()
+
+	viewCmd = kingpin.Command("view", "Project available stock from kafka into redis")
+
+	showCmd = kingpin.Command("show", "Show available stock of products")
+	uuids   = showCmd.Arg("uuid", "Product uuids").Required().Strings()
+)
+
+func main() {
+	cmd := kingpin.Parse()
+
+	switch cmd {
+	case serveCmd.FullCommand():
+		serve()
+	case viewCmd.FullCommand():
+		consume(newRedis())
+	case showCmd.FullCommand():
+		err := show(newRedis(), *uuids)
+		if err != nil {
+			log.Panicf("failed to show stock: %s", err)
+		}
+	}
+}
+
+func newRedis() redis.UniversalClient {
+	r, err := redisclient.New(redisclient.Options{
+		Addresses:  *redisAddress,
+		MasterName: *redisMaster,
+		Cluster:    *redisCluster,
+		Password:   *redisPassword,
+		Database:   *redisDatabase,
+	})
+	if err != nil {
+		log.Panicf("failed to setup redis client: %s", err)
+	}
+	return r
+}
+
+func serve() {
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Idempotent = true
+	config.Producer.Return.Successes = true
+	config.Net.MaxOpenRequests = 1
+
+	client, err := sarama.NewClient(*brokerList, config)
+	if err != nil {
+		log.Panicf("failed to connect to kafka: %s", err)
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		log.Panicf("failed to setup kafka producer: %s", err)
+	}
+	defer func() {
+		if err := producer.Close(); err != nil {
+			log.Panicf("failed to close kafka producer: %s", err)
+		}
+	}()
+
+	s := newService(producer, *topic, topicReplayer(client, *topic))
+	err = s.reload()
+	if err != nil {
+		log.Panicf("failed to replay stock events: %s", err)
+	}
+
+	http.Handle("/stock/", s)
+	log.Printf("listening on %s", *listen)
+	log.Panic(http.ListenAndServe(*listen, nil))
+}
+
+func consume(r redis.UniversalClient) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	topics := []string{*topic}
+	consumer, err := sarama.NewConsumerGroup(*brokerList, "inventory-stock-v1", config)
+	if err != nil {
+		log.Panicf("failed to setup kafka consumer group: %s", err)
+	}
+	defer func() {
+		if err := consumer.Close(); err != nil {
+			log.Panicf("failed to close kafka consumer group: %s", err)
+		}
+	}()
+
+	v := func(msg *sarama.ConsumerMessage) error {
+		return view(r, msg)
+	}
+	simba := simba.NewConsumer(nil, v)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		<-signals
+		log.Print("interrupt is detected")
+		cancel()
+	}()
+
+	err = simba.Run(ctx, consumer, topics)
+	if err != nil {
+		log.Panicf("failed to consume: %s", err)
+	}
+}
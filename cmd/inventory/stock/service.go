@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// service validates stock commands against the current levels and publishes the resulting events.
+// It needs to be the only writer of the stock topic.
+type service struct {
+	mux      sync.Mutex
+	levels   map[string]*level
+	producer sarama.SyncProducer
+	topic    string
+	replay   replayer
+	// stale is set by a failed send, the event may be in the log anyway
+	stale bool
+}
+
+// replayer passes all stock events published so far to apply
+type replayer func(apply func(e *pb.StockEvent)) error
+
+func newService(producer sarama.SyncProducer, topic string, replay replayer) *service {
+	return &service{
+		levels:   make(map[string]*level),
+		producer: producer,
+		topic:    topic,
+		replay:   replay,
+	}
+}
+
+// reload rebuilds the stock levels from all events published so far
+func (s *service) reload() error {
+	s.levels = make(map[string]*level)
+	events := 0
+	err := s.replay(func(e *pb.StockEvent) {
+		s.level(e.Uuid).apply(e)
+		events++
+	})
+	if err != nil {
+		return err
+	}
+	s.stale = false
+
+	log.Printf("replayed %d stock events of %d products", events, len(s.levels))
+	return nil
+}
+
+// topicReplayer reads the stock events of all partitions of a topic up to their newest offsets
+func topicReplayer(client sarama.Client, topic string) replayer {
+	return func(apply func(e *pb.StockEvent)) error {
+		consumer, err := sarama.NewConsumerFromClient(client)
+		if err != nil {
+			return fmt.Errorf("failed to setup kafka consumer: %s", err)
+		}
+		defer consumer.Close()
+
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			return fmt.Errorf("failed to list partitions of topic %s: %s", topic, err)
+		}
+
+		for _, partition := range partitions {
+			newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+			if err != nil {
+				return fmt.Errorf("failed to get newest offset of %s/%d: %s", topic, partition, err)
+			}
+			oldest, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+			if err != nil {
+				return fmt.Errorf("failed to get oldest offset of %s/%d: %s", topic, partition, err)
+			}
+			if newest == oldest {
+				continue
+			}
+
+			pc, err := consumer.ConsumePartition(topic, partition, oldest)
+			if err != nil {
+				return fmt.Errorf("failed to consume %s/%d: %s", topic, partition, err)
+			}
+			for msg := range pc.Messages() {
+				e := &pb.StockEvent{}
+				err := proto.Unmarshal(msg.Value, e)
+				if err != nil {
+					pc.Close()
+					return fmt.Errorf("failed to unmarshal stock event at %s/%d/%d: %s", topic, partition, msg.Offset, err)
+				}
+				apply(e)
+				if msg.Offset >= newest-1 {
+					break
+				}
+			}
+			if err := pc.Close(); err != nil {
+				return fmt.Errorf("failed to close partition consumer %s/%d: %s", topic, partition, err)
+			}
+		}
+		return nil
+	}
+}
+
+func (s *service) level(uuid string) *level {
+	l, ok := s.levels[uuid]
+	if !ok {
+		l = newLevel(uuid)
+		s.levels[uuid] = l
+	}
+	return l
+}
+
+// handle decides on a command and publishes the event before it becomes part of the state
+func (s *service) handle(uuid string, decide func(l *level) (*pb.StockEvent, error)) (*pb.StockEvent, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.stale {
+		err := s.reload()
+		if err != nil {
+			return nil, fmt.Errorf("failed to reload stock levels: %s", err)
+		}
+	}
+
+	l := s.level(uuid)
+	e, err := decide(l)
+	if err != nil {
+		return nil, commandRejected{err}
+	}
+
+	bytes, err := proto.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stock event of %s: %s", uuid, err)
+	}
+	_, _, err = s.producer.SendMessage(&sarama.ProducerMessage{
+		Topic: s.topic,
+		Key:   sarama.StringEncoder(uuid),
+		Value: sarama.ByteEncoder(bytes),
+	})
+	if err != nil {
+		// the outcome is unknown, the next command decides on the levels of the log instead of reusing the version
+		s.stale = true
+		return nil, fmt.Errorf("failed to publish stock event of %s: %s", uuid, err)
+	}
+
+	l.apply(e)
+	return e, nil
+}
+
+// ServeHTTP accepts GET /stock/<uuid> and POST /stock/<uuid>/<receive|reserve|release|adjust>
+func (s *service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/stock/"), "/"), "/")
+	uuid := parts[0]
+	if uuid == "" || len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if len(parts) == 1 {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.mux.Lock()
+		if s.stale {
+			err := s.reload()
+			if err != nil {
+				s.mux.Unlock()
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		l := s.level(uuid)
+		state := stockState{UUID: uuid, Version: l.version, OnHand: l.onHand, Reserved: l.reserved, Available: l.available()}
+		s.mux.Unlock()
+		writeJSON(w, state)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var decide func(l *level) (*pb.StockEvent, error)
+	switch parts[1] {
+	case "receive":
+		quantity, err := strconv.ParseInt(r.FormValue("quantity"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid quantity", http.StatusBadRequest)
+			return
+		}
+		decide = func(l *level) (*pb.StockEvent, error) { return l.receive(quantity, r.FormValue("reference")) }
+	case "reserve":
+		quantity, err := strconv.ParseInt(r.FormValue("quantity"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid quantity", http.StatusBadRequest)
+			return
+		}
+		decide = func(l *level) (*pb.StockEvent, error) { return l.reserve(quantity, r.FormValue("reservation")) }
+	case "release":
+		quantity, err := strconv.ParseInt(r.FormValue("quantity"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid quantity", http.StatusBadRequest)
+			return
+		}
+		decide = func(l *level) (*pb.StockEvent, error) { return l.release(quantity, r.FormValue("reservation")) }
+	case "adjust":
+		delta, err := strconv.ParseInt(r.FormValue("delta"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid delta", http.StatusBadRequest)
+			return
+		}
+		decide = func(l *level) (*pb.StockEvent, error) { return l.adjust(delta, r.FormValue("reason")) }
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	e, err := s.handle(uuid, decide)
+	if rejected, ok := err.(commandRejected); ok {
+		status := http.StatusBadRequest
+		if _, ok := rejected.err.(errInsufficientStock); ok {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if err != nil {
+		log.Printf("failed to handle %s of %s: %s", parts[1], uuid, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, stockState{
+		UUID:      uuid,
+		Version:   e.Version,
+		OnHand:    e.Level.OnHand,
+		Reserved:  e.Level.Reserved,
+		Available: e.Level.OnHand - e.Level.Reserved,
+	})
+}
+
+// commandRejected marks commands that are invalid in the current state
+type commandRejected struct {
+	err error
+}
+
+func (e commandRejected) Error() string {
+	return e.err.Error()
+}
+
+type stockState struct {
+	UUID      string `json:"uuid"`
+	Version   int64  `json:"version"`
+	OnHand    int64  `json:"onHand"`
+	Reserved  int64  `json:"reserved"`
+	Available int64  `json:"available"`
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		log.Printf("failed to write response: %s", err)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+const lampID = "4c61efbc-4f73-43f6-ba88-cab234b10f63"
+
+// memoryLog is the stock topic of a test, it publishes like a sync producer and replays its events
+type memoryLog struct {
+	mux    sync.Mutex
+	events []*pb.StockEvent
+	// lost fails the next sends before the event is written
+	lost int
+	// ambiguous fails the next sends after the event is written
+	ambiguous int
+}
+
+func (m *memoryLog) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if m.lost > 0 {
+		m.lost--
+		return 0, 0, errors.New("request timed out")
+	}
+	bytes, err := msg.Value.Encode()
+	if err != nil {
+		return 0, 0, err
+	}
+	e := &pb.StockEvent{}
+	err = proto.Unmarshal(bytes, e)
+	if err != nil {
+		return 0, 0, err
+	}
+	m.events = append(m.events, e)
+	if m.ambiguous > 0 {
+		m.ambiguous--
+		return 0, 0, errors.New("request timed out")
+	}
+	return 0, int64(len(m.events) - 1), nil
+}
+
+func (m *memoryLog) SendMessages(msgs []*sarama.ProducerMessage) error {
+	for _, msg := range msgs {
+		_, _, err := m.SendMessage(msg)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memoryLog) Close() error {
+	return nil
+}
+
+func (m *memoryLog) replay(apply func(e *pb.StockEvent)) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for _, e := range m.events {
+		apply(e)
+	}
+	return nil
+}
+
+func newTestService(t *testing.T) (*service, *memoryLog) {
+	m := &memoryLog{}
+	s := newService(m, "stock", m.replay)
+	err := s.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, m
+}
+
+func receive(quantity int64) func(l *level) (*pb.StockEvent, error) {
+	return func(l *level) (*pb.StockEvent, error) { return l.receive(quantity, "delivery") }
+}
+
+func reserve(quantity int64, reservation string) func(l *level) (*pb.StockEvent, error) {
+	return func(l *level) (*pb.StockEvent, error) { return l.reserve(quantity, reservation) }
+}
+
+// checkLog verifies that the versions of the log are unique and no event leaves negative available stock
+func checkLog(t *testing.T, m *memoryLog) {
+	t.Helper()
+	versions := map[int64]bool{}
+	for _, e := range m.events {
+		if versions[e.Version] {
+			t.Fatalf("version %d is published twice", e.Version)
+		}
+		versions[e.Version] = true
+		if e.Level.OnHand-e.Level.Reserved < 0 {
+			t.Fatalf("event at version %d leaves %d available", e.Version, e.Level.OnHand-e.Level.Reserved)
+		}
+	}
+}
+
+func TestConcurrentReservationsKeepAvailableStock(t *testing.T) {
+	s, m := newTestService(t)
+	_, err := s.handle(lampID, receive(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	reserved := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := s.handle(lampID, reserve(1, fmt.Sprintf("order-%d", i)))
+			mux.Lock()
+			defer mux.Unlock()
+			if r, ok := err.(commandRejected); ok {
+				if _, ok := r.err.(errInsufficientStock); !ok {
+					t.Errorf("expected insufficient stock, got %s", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			reserved++
+		}(i)
+	}
+	wg.Wait()
+
+	if reserved != 5 {
+		t.Fatalf("expected 5 of 20 reservations to succeed, got %d", reserved)
+	}
+	checkLog(t, m)
+
+	// the replayed levels match, an adjustment below the reserved stock is rejected
+	err = s.reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := s.level(lampID)
+	if l.version != 6 || l.onHand != 5 || l.reserved != 5 || l.available() != 0 {
+		t.Fatalf("expected 5 on hand and 5 reserved at version 6, got %+v", l)
+	}
+	_, err = s.handle(lampID, func(l *level) (*pb.StockEvent, error) { return l.adjust(-1, "broken") })
+	if _, ok := err.(commandRejected); !ok {
+		t.Fatalf("expected the adjustment below the reserved stock to be rejected, got %v", err)
+	}
+}
+
+func TestFailedSendReloadsLevels(t *testing.T) {
+	s, m := newTestService(t)
+	_, err := s.handle(lampID, receive(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the event of an ambiguous failure is in the log, the next reservation may not use its stock
+	m.ambiguous = 1
+	_, err = s.handle(lampID, reserve(3, "order-1"))
+	if err == nil {
+		t.Fatal("expected the send to fail")
+	}
+	_, err = s.handle(lampID, reserve(1, "order-2"))
+	if _, ok := err.(commandRejected); !ok {
+		t.Fatalf("expected the reservation to be rejected after the reload, got %v", err)
+	}
+
+	// a lost event frees its version and stock again
+	_, err = s.handle(lampID, receive(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.lost = 1
+	_, err = s.handle(lampID, reserve(2, "order-3"))
+	if err == nil {
+		t.Fatal("expected the send to fail")
+	}
+	e, err := s.handle(lampID, reserve(2, "order-4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Version != 4 || e.Level.OnHand != 5 || e.Level.Reserved != 5 {
+		t.Fatalf("expected 5 on hand and 5 reserved at version 4, got %v", e)
+	}
+	checkLog(t, m)
+}
+
+func TestApplySkipsPublishedVersions(t *testing.T) {
+	l := newLevel(lampID)
+	received, err := l.receive(1, "delivery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.apply(received)
+
+	// two reservations decided on the same version, e.g. by a second writer
+	first, err := l.reserve(1, "order-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := l.reserve(1, "order-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.apply(first)
+	l.apply(second)
+
+	if l.version != 2 || l.reserved != 1 || l.available() != 0 || l.reservations["order-2"] != 0 {
+		t.Fatalf("expected only the first reservation of version 2, got %+v", l)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
+)
+
+const stockKeyPrefix = "stock:"
+
+// setLevel only overwrites older versions, simba incorporates messages concurrently
+var setLevel = redis.NewScript(`
+local current = tonumber(redis.call("HGET", KEYS[1], "version") or "0")
+if current >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call("HMSET", KEYS[1], "version", ARGV[1], "onHand", ARGV[2], "reserved", ARGV[3], "available", ARGV[4])
+return 1
+`)
+
+func view(r redis.UniversalClient, msg *sarama.ConsumerMessage) error {
+
+	e := pb.StockEvent{}
+	err := proto.Unmarshal(msg.Value, &e)
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal kafka massaga: %s", err)
+	}
+
+	UUID := string(msg.Key)
+	l := e.GetLevel()
+
+	err = setLevel.Run(r, []string{stockKeyPrefix + UUID}, e.Version, l.GetOnHand(), l.GetReserved(), l.GetOnHand()-l.GetReserved()).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set stock level of %s in redis: %s", UUID, err)
+	}
+	return nil
+}
+
+func show(r redis.UniversalClient, uuids []string) error {
+	for _, uuid := range uuids {
+		fields, err := r.HGetAll(stockKeyPrefix + uuid).Result()
+		if err != nil {
+			return fmt.Errorf("failed to load stock level of %s: %s", uuid, err)
+		}
+		if len(fields) == 0 {
+			fmt.Printf("%s unknown\n", uuid)
+			continue
+		}
+		fmt.Printf("%s available %s (on hand %s, reserved %s, version %s)\n",
+			uuid, fields["available"], fields["onHand"], fields["reserved"], fields["version"])
+	}
+	return nil
+}
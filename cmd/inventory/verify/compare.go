@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/damoon/eventstore-example/pkg/store"
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
+)
+
+const chunkSize = 1000
+
+// productKeyPattern matches product uuids, optionally prefixed by their tenant
+var productKeyPattern = regexp.MustCompile(`^(([a-z0-9][a-z0-9_-]*):)?([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})$`)
+
+// categoryKeyPattern matches the hash tagged category sets of all tenants
+var categoryKeyPattern = regexp.MustCompile(`^\{(([a-z0-9][a-z0-9_-]*):)?categories:(.+)\}:members$`)
+
+// report counts the differences and collects the writes that repair them
+type report struct {
+	counts   map[string]int
+	products []store.ProductWrite
+	changes  []store.CategoryChange
+}
+
+func newReport() *report {
+	return &report{counts: map[string]int{}}
+}
+
+func (r *report) add(kind, format string, args ...interface{}) {
+	r.counts[kind]++
+	if *verbose || r.counts[kind] <= *maxReported {
+		fmt.Printf("%s: %s\n", kind, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *report) consistent() bool {
+	return len(r.counts) == 0
+}
+
+func (r *report) summary() {
+	kinds := []string{}
+	for kind := range r.counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Printf("%d %s\n", r.counts[kind], kind)
+	}
+}
+
+// redisKeys are the keys of the products and categories views found in redis,
+// the masters of a cluster get scanned concurrently
+type redisKeys struct {
+	mux        sync.Mutex
+	products   map[string]*entry
+	categories map[string]*category
+}
+
+// scan visits all keys, product keys have no common prefix
+func scan(r redis.UniversalClient) (*redisKeys, error) {
+	keys := &redisKeys{
+		products:   map[string]*entry{},
+		categories: map[string]*category{},
+	}
+
+	err := redisclient.ForEachMaster(r, func(c *redis.Client) error {
+		candidates := []string{}
+		iter := c.Scan(0, "*", chunkSize).Iterator()
+		for iter.Next() {
+			key := iter.Val()
+			if productKeyPattern.MatchString(key) || categoryKeyPattern.MatchString(key) {
+				candidates = append(candidates, key)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return err
+		}
+		return classify(c, candidates, keys)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan redis keys: %s", err)
+	}
+
+	return keys, nil
+}
+
+// classify keeps the product strings and category sets, other views like stock:<uuid> look like tenant prefixed products
+func classify(c *redis.Client, candidates []string, keys *redisKeys) error {
+	for start := 0; start < len(candidates); start += chunkSize {
+		end := start + chunkSize
+		if end > len(candidates) {
+			end = len(candidates)
+		}
+
+		pipe := c.Pipeline()
+		types := make([]*redis.StatusCmd, end-start)
+		for i, key := range candidates[start:end] {
+			types[i] = pipe.Type(key)
+		}
+		_, err := pipe.Exec()
+		if err != nil {
+			return err
+		}
+
+		keys.mux.Lock()
+		for i, key := range candidates[start:end] {
+			switch types[i].Val() {
+			case "string":
+				m := productKeyPattern.FindStringSubmatch(key)
+				if m != nil && filter.Serves(m[2]) {
+					keys.products[key] = &entry{tenant: m[2], uuid: m[3]}
+				}
+			case "set":
+				m := categoryKeyPattern.FindStringSubmatch(key)
+				if m != nil && filter.Serves(m[2]) {
+					keys.categories[key] = &category{tenant: m[2], name: m[3]}
+				}
+			}
+		}
+		keys.mux.Unlock()
+	}
+	return nil
+}
+
+func sortedKeys(products map[string]*entry) []string {
+	keys := make([]string, 0, len(products))
+	for key := range products {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// compareProducts reports products that are missing in redis, differ from the model or should not exist
+func compareProducts(r redis.UniversalClient, m *model, found *redisKeys, rep *report) error {
+	keys := sortedKeys(m.products)
+
+	for start := 0; start < len(keys); start += chunkSize {
+		end := start + chunkSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		pipe := r.Pipeline()
+		cmds := make([]*redis.StringCmd, end-start)
+		for i, key := range keys[start:end] {
+			cmds[i] = pipe.Get(key)
+		}
+		_, err := pipe.Exec()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("failed to load products from redis: %s", err)
+		}
+
+		for i, key := range keys[start:end] {
+			e := m.products[key]
+			bytes, err := cmds[i].Bytes()
+			if err == redis.Nil {
+				rep.add("missing product", "%s", key)
+				rep.products = append(rep.products, store.ProductWrite{Tenant: e.tenant, UUID: e.uuid, Product: e.product})
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to load product %s from redis: %s", key, err)
+			}
+
+			actual := &pb.Product{}
+			err = proto.Unmarshal(bytes, actual)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal product %s from redis: %s", key, err)
+			}
+			err = pb.UpcastProduct(actual)
+			if err != nil {
+				return fmt.Errorf("failed to upcast product %s from redis: %s", key, err)
+			}
+
+			if !proto.Equal(e.product, actual) {
+				rep.add("divergent product", "%s differs in %v", key, diff(e.product, actual))
+				rep.products = append(rep.products, store.ProductWrite{Tenant: e.tenant, UUID: e.uuid, Product: e.product})
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(found.products) {
+		if _, ok := m.products[key]; ok {
+			continue
+		}
+		e := found.products[key]
+		rep.add("extra product", "%s", key)
+		rep.products = append(rep.products, store.ProductWrite{Tenant: e.tenant, UUID: e.uuid})
+	}
+
+	return nil
+}
+
+// diff names the fields that differ
+func diff(expected, actual *pb.Product) []string {
+	fields := []string{}
+	if expected.Uuid != actual.Uuid {
+		fields = append(fields, "uuid")
+	}
+	if expected.Title != actual.Title {
+		fields = append(fields, "title")
+	}
+	if expected.Description != actual.Description {
+		fields = append(fields, "description")
+	}
+	if expected.Longtext != actual.Longtext {
+		fields = append(fields, "longtext")
+	}
+	if expected.Category != actual.Category {
+		fields = append(fields, "category")
+	}
+	if expected.SmallImageURL != actual.SmallImageURL {
+		fields = append(fields, "smallImageURL")
+	}
+	if expected.LargeImageURL != actual.LargeImageURL {
+		fields = append(fields, "largeImageURL")
+	}
+	if !proto.Equal(expected.Price, actual.Price) {
+		fields = append(fields, "price")
+	}
+	return fields
+}
+
+// compareCategories reports products missing in their category set and members that belong elsewhere
+func compareCategories(r redis.UniversalClient, m *model, found *redisKeys, rep *report) error {
+	keys := make([]string, 0, len(m.categories))
+	for key := range m.categories {
+		keys = append(keys, key)
+	}
+	for key := range found.categories {
+		if _, ok := m.categories[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		expected, ok := m.categories[key]
+		if !ok {
+			expected = found.categories[key]
+		}
+
+		members, err := r.SMembers(key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to load category %s from redis: %s", key, err)
+		}
+		actual := map[string]bool{}
+		for _, member := range members {
+			actual[member] = true
+		}
+
+		for _, uuid := range sortedMembers(expected.members) {
+			if actual[uuid] {
+				continue
+			}
+			rep.add("missing category member", "%s in %s", uuid, key)
+			rep.changes = append(rep.changes, store.CategoryChange{Tenant: expected.tenant, UUID: uuid, New: expected.name})
+		}
+		for _, uuid := range members {
+			if expected.members[uuid] {
+				continue
+			}
+			rep.add("extra category member", "%s in %s", uuid, key)
+			rep.changes = append(rep.changes, store.CategoryChange{Tenant: expected.tenant, UUID: uuid, Old: expected.name})
+		}
+	}
+
+	return nil
+}
+
+func sortedMembers(members map[string]bool) []string {
+	uuids := make([]string, 0, len(members))
+	for uuid := range members {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	return uuids
+}
+
+// repair writes the model over the differences, in chunks like the views write their batches
+func repair(r redis.UniversalClient, rep *report) error {
+	s := store.NewRedis(r)
+
+	for start := 0; start < len(rep.products); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rep.products) {
+			end = len(rep.products)
+		}
+		err := s.WriteProducts("", rep.products[start:end], nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(rep.changes); start += chunkSize {
+		end := start + chunkSize
+		if end > len(rep.changes) {
+			end = len(rep.changes)
+		}
+		err := s.WriteCategories("", rep.changes[start:end], nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/feed"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/damoon/eventstore-example/pkg/tenant"
+	kingpin "gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	redisAddress  = kingpin.Flag("redisAddress", "Redis Host, the sentinels or cluster nodes").Default("redis:6379").Strings()
+	redisPassword = kingpin.Flag("redisPassword", "Redis Password").Default("").String()
+	redisDatabase = kingpin.Flag("redisDatabase", "Redis Database").Default("0").Int()
+	redisMaster   = kingpin.Flag("redisMaster", "Name of the master monitored by the redis sentinels").Default("").String()
+	redisCluster  = kingpin.Flag("redisCluster", "Connect to a redis cluster").Default("false").Bool()
+	fix           = kingpin.Flag("repair", "Write the expected state over missing, extra and divergent entries, stop the views first").Default("false").Bool()
+	maxReported   = kingpin.Flag("maxReported", "Differences to print per kind").Default("100").Int()
+	verbose       = kingpin.Flag("verbose", "Print all differences").Default("false").Bool()
+	legacy        = kingpin.Flag("legacyCurrency", "ISO 4217 currency of the float prices of products published before prices carried a currency, reading such a product fails without it").Default("").String()
+
+	topicCmd   = kingpin.Command("topic", "Replay the products topic and compare it with redis")
+	brokerList = topicCmd.Flag("brokerList", "List of brokers to connect").Default("localhost:9092").Strings()
+	topic      = topicCmd.Flag("topic", "Topic name").Default("products").String()
+	tenants    = topicCmd.Flag("tenant", "Tenants to verify, all if none are given").Strings()
+
+	csvCmd          = kingpin.Command("csv", "Read an import file like csv-import does and compare it with redis")
+	csvPath         = csvCmd.Arg("path", "Import file, rows csv-import rejects are skipped").Required().String()
+	csvHeader       = csvCmd.Flag("header", "The first row holds column names, like header in the mapping").Default("false").Bool()
+	csvTenant       = csvCmd.Flag("tenant", "Catalogue the products belong to, empty for the default catalogue").Default("").String()
+	defaultCurrency = csvCmd.Flag("currency", "ISO 4217 currency of prices without currency column").Default("EUR").String()
+	format          = csvCmd.Flag("format", "Format of the import file: csv, jsonl, xml or parquet").Default("csv").String()
+	mappingPath     = csvCmd.Flag("mapping", "YAML or JSON file describing header, delimiter, encoding and column mapping of the import file").Default("").String()
+)
+
+var filter tenant.Filter
+
+func main() {
+	cmd := kingpin.Parse()
+	pb.LegacyCurrency = *legacy
+
+	r, err := redisclient.New(redisclient.Options{
+		Addresses:  *redisAddress,
+		MasterName: *redisMaster,
+		Cluster:    *redisCluster,
+		Password:   *redisPassword,
+		Database:   *redisDatabase,
+	})
+	if err != nil {
+		log.Panicf("failed to setup redis client: %s", err)
+	}
+
+	var m *model
+	switch cmd {
+	case topicCmd.FullCommand():
+		filter, err = tenant.NewFilter(*tenants)
+		if err != nil {
+			log.Panicf("failed to parse flags: %s", err)
+		}
+
+		config := sarama.NewConfig()
+		config.Version = sarama.V1_1_0_0
+		config.Consumer.IsolationLevel = sarama.ReadCommitted
+		config.Consumer.Return.Errors = true
+		client, err := sarama.NewClient(*brokerList, config)
+		if err != nil {
+			log.Panicf("failed to connect to kafka: %s", err)
+		}
+		defer client.Close()
+
+		m, err = replay(client, *topic)
+		if err != nil {
+			log.Panicf("failed to replay topic %s: %s", *topic, err)
+		}
+
+	case csvCmd.FullCommand():
+		filter, err = tenant.NewFilter([]string{*csvTenant})
+		if err != nil {
+			log.Panicf("failed to parse flags: %s", err)
+		}
+
+		l, err := feed.LoadLayout(*mappingPath)
+		if err != nil {
+			log.Panicf("failed to load column mapping: %s", err)
+		}
+		if *csvHeader {
+			l.Header = true
+		}
+		m, err = readSnapshot(*csvPath, *csvTenant, l)
+		if err != nil {
+			log.Panicf("failed to read import file: %s", err)
+		}
+	}
+	log.Printf("expecting %d products in %d categories", len(m.products), len(m.categories))
+
+	found, err := scan(r)
+	if err != nil {
+		log.Panicf("failed to list views in redis: %s", err)
+	}
+
+	rep := newReport()
+	err = compareProducts(r, m, found, rep)
+	if err != nil {
+		log.Panicf("failed to compare products: %s", err)
+	}
+	err = compareCategories(r, m, found, rep)
+	if err != nil {
+		log.Panicf("failed to compare categories: %s", err)
+	}
+
+	rep.summary()
+	if rep.consistent() {
+		log.Print("redis matches the source")
+		return
+	}
+
+	if !*fix {
+		os.Exit(1)
+	}
+	err = repair(r, rep)
+	if err != nil {
+		log.Panicf("failed to repair redis: %s", err)
+	}
+	log.Printf("repaired %d products and %d category members", len(rep.products), len(rep.changes))
+}
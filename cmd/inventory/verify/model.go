@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/feed"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/store"
+	"github.com/damoon/eventstore-example/pkg/tenant"
+	"github.com/golang/protobuf/proto"
+)
+
+// replayIdle ends the replay of a partition that has no more deliverable messages,
+// the last offsets can be transaction markers or aborted records
+const replayIdle = 5 * time.Second
+
+// model is the expected content of the views, derived from the source events
+type model struct {
+	products   map[string]*entry
+	categories map[string]*category
+}
+
+type entry struct {
+	tenant  string
+	uuid    string
+	product *pb.Product
+}
+
+type category struct {
+	tenant  string
+	name    string
+	members map[string]bool
+}
+
+func newModel() *model {
+	return &model{
+		products:   map[string]*entry{},
+		categories: map[string]*category{},
+	}
+}
+
+func (m *model) put(t, uuid string, p *pb.Product) {
+	key := tenant.Key(t, uuid)
+	if p == nil {
+		delete(m.products, key)
+		return
+	}
+	m.products[key] = &entry{tenant: t, uuid: uuid, product: p}
+}
+
+// index groups the products by category like the categories view does
+func (m *model) index() {
+	for _, e := range m.products {
+		key := store.CategoryKey(e.tenant, e.product.Category)
+		c, ok := m.categories[key]
+		if !ok {
+			c = &category{tenant: e.tenant, name: e.product.Category, members: map[string]bool{}}
+			m.categories[key] = c
+		}
+		c.members[e.uuid] = true
+	}
+}
+
+// replay reads all committed product updates of the topic up to the current end of its partitions
+func replay(client sarama.Client, topic string) (*model, error) {
+	m := newModel()
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup kafka consumer: %s", err)
+	}
+	defer consumer.Close()
+
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %s", topic, err)
+	}
+
+	for _, partition := range partitions {
+		oldest, err := client.GetOffset(topic, partition, sarama.OffsetOldest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get oldest offset of %s/%d: %s", topic, partition, err)
+		}
+		newest, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get newest offset of %s/%d: %s", topic, partition, err)
+		}
+		if oldest >= newest {
+			continue
+		}
+
+		err = replayPartition(consumer, m, topic, partition, newest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m.index()
+	return m, nil
+}
+
+func replayPartition(consumer sarama.Consumer, m *model, topic string, partition int32, newest int64) error {
+	pc, err := consumer.ConsumePartition(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return fmt.Errorf("failed to consume %s/%d: %s", topic, partition, err)
+	}
+	defer pc.Close()
+
+	count := 0
+	for {
+		select {
+		case msg := <-pc.Messages():
+			p := pb.ProductUpdate{}
+			err := proto.Unmarshal(msg.Value, &p)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal kafka massaga %s/%d:%d: %s", topic, partition, msg.Offset, err)
+			}
+			err = pb.UpcastProductUpdate(&p)
+			if err != nil {
+				return fmt.Errorf("failed to upcast kafka massaga %s/%d:%d: %s", topic, partition, msg.Offset, err)
+			}
+			count++
+
+			if filter.Serves(p.Tenant) {
+				m.put(p.Tenant, string(msg.Key), p.New)
+			}
+
+			if msg.Offset+1 >= newest {
+				log.Printf("replayed %d messages of %s/%d", count, topic, partition)
+				return nil
+			}
+
+		case err := <-pc.Errors():
+			return fmt.Errorf("failed to consume %s/%d: %s", topic, partition, err)
+
+		case <-time.After(replayIdle):
+			log.Printf("replayed %d messages of %s/%d, the remaining offsets are not deliverable", count, topic, partition)
+			return nil
+		}
+	}
+}
+
+// readSnapshot loads an import file with the readers of csv-import,
+// the records csv-import rejects are skipped as they never reach the views
+func readSnapshot(path, t string, l *feed.Layout) (*model, error) {
+	r, err := feed.Open(*format, path, l, *defaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	rejected := 0
+	products, total, err := feed.Load(r, func(e *feed.RecordError) error {
+		rejected++
+		if *verbose {
+			log.Printf("skip %s:%d: %s", path, e.Pos, strings.Join(e.Reasons, "; "))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %s", path, err)
+	}
+	if rejected > 0 {
+		log.Printf("skipped %d of %d records of %s that csv-import rejects", rejected, total, path)
+	}
+
+	m := newModel()
+	for UUID, p := range products {
+		m.put(t, UUID, p)
+	}
+	m.index()
+	return m, nil
+}
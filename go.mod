@@ -0,0 +1,24 @@
+module github.com/damoon/eventstore-example
+
+go 1.14
+
+require (
+	github.com/DataDog/zstd v1.3.5 // indirect
+	github.com/Shopify/sarama v1.22.0
+	github.com/alecthomas/template v0.0.0-20160405071501-a0175ee3bccc // indirect
+	github.com/alecthomas/units v0.0.0-20151022065526-2efee857e7cf // indirect
+	github.com/apache/thrift v0.13.1-0.20201008052519-daf620915714 // indirect
+	github.com/eapache/go-resiliency v1.1.0 // indirect
+	github.com/eapache/queue v1.1.0 // indirect
+	github.com/go-redis/redis v6.13.2+incompatible
+	github.com/golang/protobuf v1.3.3
+	github.com/klauspost/compress v1.10.5 // indirect
+	github.com/lib/pq v1.2.0
+	github.com/pierrec/lz4 v0.0.0-20190327172049-315a67e90e41 // indirect
+	github.com/satori/go.uuid v1.2.0
+	github.com/xitongsys/parquet-go v1.5.4
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0
+	go.etcd.io/bbolt v1.3.3
+	gopkg.in/alecthomas/kingpin.v2 v2.2.6
+	gopkg.in/yaml.v2 v2.2.2
+)
@@ -0,0 +1,86 @@
+package aggregate
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Aggregate validates commands and changes its state only by applying events
+type Aggregate interface {
+	// Root returns the bookkeeping of version and unsaved events
+	Root() *Root
+	// Apply changes the state according to an event that already happened
+	Apply(event proto.Message) error
+}
+
+// Snapshotter is implemented by aggregates that can be restored from a snapshot
+type Snapshotter interface {
+	Snapshot() (proto.Message, error)
+	Restore(snapshot proto.Message) error
+}
+
+// Root tracks the version of an aggregate and the events that are not saved yet
+type Root struct {
+	id      string
+	version int64
+	changes []proto.Message
+}
+
+// NewRoot constructs the root of a new aggregate
+func NewRoot(id string) Root {
+	return Root{id: id}
+}
+
+// ID identifies the aggregate
+func (r *Root) ID() string {
+	return r.id
+}
+
+// Version is the number of saved events of the aggregate
+func (r *Root) Version() int64 {
+	return r.version
+}
+
+// Changes lists the events that are applied but not saved yet
+func (r *Root) Changes() []proto.Message {
+	return r.changes
+}
+
+// Record applies a new event and remembers it to be saved
+func Record(a Aggregate, event proto.Message) error {
+	err := a.Apply(event)
+	if err != nil {
+		return err
+	}
+	r := a.Root()
+	r.changes = append(r.changes, event)
+	return nil
+}
+
+func encode(id string, version int64, event proto.Message) (Event, error) {
+	data, err := proto.Marshal(event)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to marshal event %d of %s: %s", version, id, err)
+	}
+	return Event{
+		AggregateID: id,
+		Version:     version,
+		Type:        proto.MessageName(event),
+		Data:        data,
+	}, nil
+}
+
+func decode(e Event) (proto.Message, error) {
+	t := proto.MessageType(e.Type)
+	if t == nil {
+		return nil, fmt.Errorf("unknown event type %s", e.Type)
+	}
+	msg := reflect.New(t.Elem()).Interface().(proto.Message)
+	err := proto.Unmarshal(e.Data, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event %d of %s: %s", e.Version, e.AggregateID, err)
+	}
+	return msg, nil
+}
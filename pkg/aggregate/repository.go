@@ -0,0 +1,149 @@
+package aggregate
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Repository loads aggregates from their events and saves new events with optimistic concurrency
+type Repository struct {
+	store         Store
+	snapshots     SnapshotStore
+	snapshotEvery int64
+	construct     func(id string) Aggregate
+}
+
+// NewRepository constructs a Repository.
+// With a SnapshotStore a snapshot gets taken every snapshotEvery versions of aggregates that implement Snapshotter.
+func NewRepository(store Store, snapshots SnapshotStore, snapshotEvery int64, construct func(id string) Aggregate) *Repository {
+	return &Repository{
+		store:         store,
+		snapshots:     snapshots,
+		snapshotEvery: snapshotEvery,
+		construct:     construct,
+	}
+}
+
+// Load restores an aggregate from its latest snapshot and the events after it
+func (r *Repository) Load(id string) (Aggregate, error) {
+	a := r.construct(id)
+	root := a.Root()
+
+	err := r.restore(a)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := r.store.Load(id, root.version+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events of %s: %s", id, err)
+	}
+	for _, e := range events {
+		if e.Version != root.version+1 {
+			return nil, fmt.Errorf("event stream of %s is not continuous: expected version %d, got %d", id, root.version+1, e.Version)
+		}
+		event, err := decode(e)
+		if err != nil {
+			return nil, err
+		}
+		err = a.Apply(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply event %d of %s: %s", e.Version, id, err)
+		}
+		root.version = e.Version
+	}
+
+	return a, nil
+}
+
+func (r *Repository) restore(a Aggregate) error {
+	s, ok := a.(Snapshotter)
+	if !ok || r.snapshots == nil {
+		return nil
+	}
+
+	root := a.Root()
+	snapshot, err := r.snapshots.LoadSnapshot(root.id)
+	if err != nil {
+		return fmt.Errorf("failed to load snapshot of %s: %s", root.id, err)
+	}
+	if snapshot == nil {
+		return nil
+	}
+
+	state, err := decode(Event{AggregateID: root.id, Version: snapshot.Version, Type: snapshot.Type, Data: snapshot.Data})
+	if err != nil {
+		return err
+	}
+	err = s.Restore(state)
+	if err != nil {
+		return fmt.Errorf("failed to restore snapshot of %s: %s", root.id, err)
+	}
+	root.version = snapshot.Version
+	return nil
+}
+
+// Save appends the unsaved events of an aggregate.
+// It fails with a ConflictError if the aggregate is not at expectedVersion anymore.
+func (r *Repository) Save(a Aggregate, expectedVersion int64) error {
+	root := a.Root()
+	if root.version != expectedVersion {
+		return ConflictError{AggregateID: root.id, Expected: expectedVersion, Actual: root.version}
+	}
+	if len(root.changes) == 0 {
+		return nil
+	}
+
+	events := make([]Event, len(root.changes))
+	for i, change := range root.changes {
+		e, err := encode(root.id, expectedVersion+int64(i)+1, change)
+		if err != nil {
+			return err
+		}
+		events[i] = e
+	}
+
+	err := r.store.Append(root.id, expectedVersion, events)
+	if err != nil {
+		return err
+	}
+	root.version = expectedVersion + int64(len(events))
+	root.changes = nil
+
+	if r.snapshotDue(expectedVersion, root.version) {
+		err := r.snapshot(a)
+		if err != nil {
+			log.Printf("failed to snapshot %s: %s", root.id, err)
+		}
+	}
+	return nil
+}
+
+func (r *Repository) snapshotDue(from, to int64) bool {
+	return r.snapshots != nil && r.snapshotEvery > 0 && from/r.snapshotEvery != to/r.snapshotEvery
+}
+
+func (r *Repository) snapshot(a Aggregate) error {
+	s, ok := a.(Snapshotter)
+	if !ok {
+		return nil
+	}
+
+	root := a.Root()
+	state, err := s.Snapshot()
+	if err != nil {
+		return err
+	}
+	data, err := proto.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %s", err)
+	}
+	return r.snapshots.SaveSnapshot(Snapshot{
+		AggregateID: root.id,
+		Version:     root.version,
+		Type:        proto.MessageName(state),
+		Data:        data,
+	})
+}
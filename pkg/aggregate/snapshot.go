@@ -0,0 +1,57 @@
+package aggregate
+
+import (
+	"sync"
+)
+
+// Snapshot is the stored state of an aggregate at a version
+type Snapshot struct {
+	AggregateID string
+	Version     int64
+	Type        string
+	Data        []byte
+}
+
+// SnapshotStore persists the latest snapshot of aggregates
+type SnapshotStore interface {
+	// LoadSnapshot returns the latest snapshot of an aggregate, nil if there is none
+	LoadSnapshot(id string) (*Snapshot, error)
+	SaveSnapshot(s Snapshot) error
+}
+
+// MemorySnapshotStore keeps snapshots in memory
+type MemorySnapshotStore struct {
+	mux       sync.Mutex
+	snapshots map[string]Snapshot
+}
+
+// NewMemorySnapshotStore constructs an empty MemorySnapshotStore
+func NewMemorySnapshotStore() *MemorySnapshotStore {
+	return &MemorySnapshotStore{
+		snapshots: make(map[string]Snapshot),
+	}
+}
+
+// LoadSnapshot returns the latest snapshot of an aggregate, nil if there is none
+func (s *MemorySnapshotStore) LoadSnapshot(id string) (*Snapshot, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	snapshot, ok := s.snapshots[id]
+	if !ok {
+		return nil, nil
+	}
+	return &snapshot, nil
+}
+
+// SaveSnapshot replaces the snapshot of an aggregate if it is newer
+func (s *MemorySnapshotStore) SaveSnapshot(snapshot Snapshot) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if current, ok := s.snapshots[snapshot.AggregateID]; ok && current.Version >= snapshot.Version {
+		return nil
+	}
+	s.snapshots[snapshot.AggregateID] = snapshot
+	return nil
+}
@@ -0,0 +1,76 @@
+package aggregate
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Event is the stored form of an event of one aggregate
+type Event struct {
+	AggregateID string
+	Version     int64
+	Type        string
+	Data        []byte
+}
+
+// Store persists the event streams of aggregates
+type Store interface {
+	// Load returns the events of an aggregate starting with version from
+	Load(id string, from int64) ([]Event, error)
+	// Append adds events to the stream if it is still at the expected version
+	Append(id string, expectedVersion int64, events []Event) error
+}
+
+// ConflictError reports that another writer saved events in the meantime
+type ConflictError struct {
+	AggregateID string
+	Expected    int64
+	Actual      int64
+}
+
+func (e ConflictError) Error() string {
+	return fmt.Sprintf("version conflict for %s: expected version %d, actual version %d", e.AggregateID, e.Expected, e.Actual)
+}
+
+// MemoryStore keeps event streams in memory
+type MemoryStore struct {
+	mux     sync.Mutex
+	streams map[string][]Event
+}
+
+// NewMemoryStore constructs an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		streams: make(map[string][]Event),
+	}
+}
+
+// Load returns the events of an aggregate starting with version from
+func (s *MemoryStore) Load(id string, from int64) ([]Event, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	stream := s.streams[id]
+	if from < 1 {
+		from = 1
+	}
+	if from > int64(len(stream)) {
+		return nil, nil
+	}
+	events := make([]Event, len(stream)-int(from-1))
+	copy(events, stream[from-1:])
+	return events, nil
+}
+
+// Append adds events to the stream if it is still at the expected version
+func (s *MemoryStore) Append(id string, expectedVersion int64, events []Event) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	stream := s.streams[id]
+	if int64(len(stream)) != expectedVersion {
+		return ConflictError{AggregateID: id, Expected: expectedVersion, Actual: int64(len(stream))}
+	}
+	s.streams[id] = append(stream, events...)
+	return nil
+}
@@ -0,0 +1,50 @@
+package eventstore
+
+import (
+	"github.com/damoon/eventstore-example/pkg/aggregate"
+)
+
+// AggregateStore persists aggregates with one stream per aggregate
+type AggregateStore struct {
+	store *Store
+}
+
+// NewAggregateStore constructs an aggregate.Store on top of an event store
+func NewAggregateStore(store *Store) *AggregateStore {
+	return &AggregateStore{store: store}
+}
+
+// Load returns the events of an aggregate starting with version from
+func (a *AggregateStore) Load(id string, from int64) ([]aggregate.Event, error) {
+	events, err := a.store.ReadStream(id, from)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]aggregate.Event, len(events))
+	for i, e := range events {
+		result[i] = aggregate.Event{
+			AggregateID: id,
+			Version:     e.Version,
+			Type:        e.Type,
+			Data:        e.Value,
+		}
+	}
+	return result, nil
+}
+
+// Append adds events to the stream of an aggregate if it is still at the expected version
+func (a *AggregateStore) Append(id string, expectedVersion int64, events []aggregate.Event) error {
+	records := make([]Record, len(events))
+	for i, e := range events {
+		records[i] = Record{
+			Type:  e.Type,
+			Key:   []byte(id),
+			Value: e.Data,
+		}
+	}
+	_, err := a.store.Append(id, expectedVersion, records...)
+	if conflict, ok := err.(ConflictError); ok {
+		return aggregate.ConflictError{AggregateID: id, Expected: conflict.Expected, Actual: conflict.Actual}
+	}
+	return err
+}
@@ -0,0 +1,251 @@
+package eventstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const segmentSuffix = ".seg"
+
+// frames are laid out as length, crc32 of the body and the body
+const frameHeader = 8
+
+// location addresses a frame within a log
+type location struct {
+	segment int
+	offset  int64
+}
+
+type segment struct {
+	base int64
+	file *os.File
+	size int64
+}
+
+// log is an append-only sequence of frames split into segment files.
+// Segments are named by the sequence number of their first frame.
+type log struct {
+	dir         string
+	segmentSize int64
+	segments    []*segment
+}
+
+// mark remembers the end of a log to roll back failed appends
+type mark struct {
+	segments int
+	size     int64
+}
+
+func openLog(dir string, segmentSize int64) (*log, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %s", dir, err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list segments in %s: %s", dir, err)
+	}
+
+	l := &log{dir: dir, segmentSize: segmentSize}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), segmentSuffix) {
+			continue
+		}
+		base, err := strconv.ParseInt(strings.TrimSuffix(f.Name(), segmentSuffix), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected segment %s in %s", f.Name(), dir)
+		}
+		file, err := os.OpenFile(filepath.Join(dir, f.Name()), os.O_RDWR, 0644)
+		if err != nil {
+			l.close()
+			return nil, fmt.Errorf("failed to open segment %s: %s", f.Name(), err)
+		}
+		l.segments = append(l.segments, &segment{base: base, file: file, size: f.Size()})
+	}
+	sort.Slice(l.segments, func(i, j int) bool { return l.segments[i].base < l.segments[j].base })
+
+	return l, nil
+}
+
+// scan calls fn for every frame and cuts off a torn frame at the end of the log
+func (l *log) scan(fn func(loc location, body []byte) error) error {
+	for i, s := range l.segments {
+		var offset int64
+		for offset < s.size {
+			body, err := readFrame(s.file, offset, s.size)
+			if err != nil {
+				if i != len(l.segments)-1 {
+					return fmt.Errorf("segment %s is corrupt at offset %d: %s", s.file.Name(), offset, err)
+				}
+				return l.truncate(location{segment: i, offset: offset})
+			}
+			err = fn(location{segment: i, offset: offset}, body)
+			if err != nil {
+				return err
+			}
+			offset += frameHeader + int64(len(body))
+		}
+	}
+	return nil
+}
+
+// append writes a frame, a new segment starting with sequence number base gets started when the current one is full
+func (l *log) append(base int64, body []byte) (location, error) {
+	if len(l.segments) == 0 || l.segments[len(l.segments)-1].size >= l.segmentSize {
+		err := l.roll(base)
+		if err != nil {
+			return location{}, err
+		}
+	}
+
+	s := l.segments[len(l.segments)-1]
+	frame := make([]byte, frameHeader+len(body))
+	binary.BigEndian.PutUint32(frame[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(frame[4:8], crc32.ChecksumIEEE(body))
+	copy(frame[frameHeader:], body)
+
+	_, err := s.file.WriteAt(frame, s.size)
+	if err != nil {
+		return location{}, fmt.Errorf("failed to write to segment %s: %s", s.file.Name(), err)
+	}
+	loc := location{segment: len(l.segments) - 1, offset: s.size}
+	s.size += int64(len(frame))
+	return loc, nil
+}
+
+func (l *log) roll(base int64) error {
+	if len(l.segments) > 0 {
+		err := l.sync()
+		if err != nil {
+			return err
+		}
+	}
+	path := filepath.Join(l.dir, fmt.Sprintf("%020d%s", base, segmentSuffix))
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment %s: %s", path, err)
+	}
+	l.segments = append(l.segments, &segment{base: base, file: file})
+	return syncDir(l.dir)
+}
+
+func (l *log) read(loc location) ([]byte, error) {
+	s := l.segments[loc.segment]
+	return readFrame(s.file, loc.offset, s.size)
+}
+
+// readFrame reads the frame at offset, size limits where frames can end
+func readFrame(r io.ReaderAt, offset, size int64) ([]byte, error) {
+	header := make([]byte, frameHeader)
+	_, err := r.ReadAt(header, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frame header: %s", err)
+	}
+	length := int64(binary.BigEndian.Uint32(header[0:4]))
+	if offset+frameHeader+length > size {
+		return nil, fmt.Errorf("frame exceeds segment")
+	}
+	body := make([]byte, length)
+	_, err = r.ReadAt(body, offset+frameHeader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read frame body: %s", err)
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, fmt.Errorf("checksum mismatch")
+	}
+	return body, nil
+}
+
+func (l *log) mark() mark {
+	if len(l.segments) == 0 {
+		return mark{}
+	}
+	return mark{segments: len(l.segments), size: l.segments[len(l.segments)-1].size}
+}
+
+// rollback removes everything written after the mark
+func (l *log) rollback(m mark) error {
+	if m.segments == 0 {
+		for len(l.segments) > 0 {
+			err := l.dropLast()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return l.truncate(location{segment: m.segments - 1, offset: m.size})
+}
+
+// truncate removes the frame at loc and everything after it
+func (l *log) truncate(loc location) error {
+	for len(l.segments) > loc.segment+1 {
+		err := l.dropLast()
+		if err != nil {
+			return err
+		}
+	}
+	s := l.segments[loc.segment]
+	err := s.file.Truncate(loc.offset)
+	if err != nil {
+		return fmt.Errorf("failed to truncate segment %s: %s", s.file.Name(), err)
+	}
+	s.size = loc.offset
+	return nil
+}
+
+func (l *log) dropLast() error {
+	s := l.segments[len(l.segments)-1]
+	s.file.Close()
+	err := os.Remove(s.file.Name())
+	if err != nil {
+		return fmt.Errorf("failed to remove segment %s: %s", s.file.Name(), err)
+	}
+	l.segments = l.segments[:len(l.segments)-1]
+	return nil
+}
+
+// sync flushes the active segment to disk, older segments got synced when they were rolled
+func (l *log) sync() error {
+	if len(l.segments) == 0 {
+		return nil
+	}
+	s := l.segments[len(l.segments)-1]
+	err := s.file.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync segment %s: %s", s.file.Name(), err)
+	}
+	return nil
+}
+
+func (l *log) close() error {
+	var err error
+	for _, s := range l.segments {
+		if e := s.file.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory %s: %s", dir, err)
+	}
+	defer d.Close()
+	err = d.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync directory %s: %s", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,534 @@
+package eventstore
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// AnyVersion skips the version check of Append
+const AnyVersion = -1
+
+// SyncPolicy decides when appended events get flushed to disk
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes before Append returns
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes periodically, a crash loses at most one interval
+	SyncInterval
+	// SyncNever leaves flushing to the operating system
+	SyncNever
+)
+
+// Options configure a Store
+type Options struct {
+	SegmentSize  int64
+	Sync         SyncPolicy
+	SyncInterval time.Duration
+	// Partitions is the number of partitions subscriptions spread the streams over
+	Partitions int
+}
+
+// DefaultOptions flush every append, roll segments at 64MB and spread subscriptions over 16 partitions
+func DefaultOptions() Options {
+	return Options{
+		SegmentSize:  64 << 20,
+		Sync:         SyncAlways,
+		SyncInterval: time.Second,
+		Partitions:   16,
+	}
+}
+
+// Record is an event to append
+type Record struct {
+	Type  string
+	Key   []byte
+	Value []byte
+}
+
+// Event is a stored record with its position in its stream and in the store
+type Event struct {
+	Stream    string
+	Version   int64
+	Position  int64
+	Timestamp time.Time
+	Type      string
+	Key       []byte
+	Value     []byte
+}
+
+// ErrClosed is returned by operations on a closed store
+var ErrClosed = errors.New("store is closed")
+
+// ConflictError reports that a stream is not at the expected version
+type ConflictError struct {
+	Stream   string
+	Expected int64
+	Actual   int64
+}
+
+func (e ConflictError) Error() string {
+	return fmt.Sprintf("version conflict for stream %s: expected version %d, actual version %d", e.Stream, e.Expected, e.Actual)
+}
+
+type stream struct {
+	name      string
+	log       *log
+	locations []location
+}
+
+// entry is the global index of a position
+type entry struct {
+	stream  *stream
+	version int64
+}
+
+// Store is an embedded event store.
+// Every stream is a segmented append-only log, a global log orders all events of the store.
+type Store struct {
+	mux     sync.RWMutex
+	dir     string
+	opts    Options
+	lock    *os.File
+	global  *log
+	entries []entry
+	streams map[string]*stream
+	notify  chan struct{}
+	dirty   bool
+	closed  bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Open opens or creates the store in dir. Only one process can open a store at a time.
+func Open(dir string, opts Options) (*Store, error) {
+	err := os.MkdirAll(filepath.Join(dir, "streams"), 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create store %s: %s", dir, err)
+	}
+
+	lock, err := os.OpenFile(filepath.Join(dir, "LOCK"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock of store %s: %s", dir, err)
+	}
+	err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		lock.Close()
+		return nil, fmt.Errorf("failed to lock store %s: %s", dir, err)
+	}
+
+	s := &Store{
+		dir:     dir,
+		opts:    opts,
+		lock:    lock,
+		streams: make(map[string]*stream),
+		notify:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	err = s.recover()
+	if err != nil {
+		s.closeFiles()
+		return nil, err
+	}
+
+	if opts.Sync == SyncInterval {
+		s.wg.Add(1)
+		go s.syncLoop()
+	}
+
+	return s, nil
+}
+
+// recover loads the indexes and removes events that did not make it into the global log
+func (s *Store) recover() error {
+	global, err := openLog(filepath.Join(s.dir, "global"), s.opts.SegmentSize)
+	if err != nil {
+		return err
+	}
+	s.global = global
+
+	type ref struct {
+		name    string
+		version int64
+	}
+	refs := []ref{}
+	err = global.scan(func(loc location, body []byte) error {
+		position, version, name, err := decodeEntry(body)
+		if err != nil {
+			return err
+		}
+		if position != int64(len(refs)+1) {
+			return fmt.Errorf("global log is not continuous at position %d", position)
+		}
+		refs = append(refs, ref{name: name, version: version})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read global log: %s", err)
+	}
+	head := int64(len(refs))
+
+	dirs, err := ioutil.ReadDir(filepath.Join(s.dir, "streams"))
+	if err != nil {
+		return fmt.Errorf("failed to list streams: %s", err)
+	}
+	for _, d := range dirs {
+		name, err := url.PathUnescape(d.Name())
+		if err != nil {
+			return fmt.Errorf("unexpected stream directory %s", d.Name())
+		}
+		st, err := s.openStream(name)
+		if err != nil {
+			return err
+		}
+		var cut *location
+		err = st.log.scan(func(loc location, body []byte) error {
+			if cut != nil {
+				return nil
+			}
+			if len(body) < 8 {
+				return fmt.Errorf("event at offset %d is too short", loc.offset)
+			}
+			position := int64(binary.BigEndian.Uint64(body[0:8]))
+			if position > head {
+				cut = &loc
+				return nil
+			}
+			st.locations = append(st.locations, loc)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to read stream %s: %s", name, err)
+		}
+		if cut != nil {
+			err := st.log.truncate(*cut)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	s.entries = make([]entry, len(refs))
+	for i, r := range refs {
+		st, ok := s.streams[r.name]
+		if !ok || r.version > int64(len(st.locations)) {
+			return fmt.Errorf("event %d of stream %s at position %d is missing", r.version, r.name, i+1)
+		}
+		s.entries[i] = entry{stream: st, version: r.version}
+	}
+
+	return nil
+}
+
+func (s *Store) openStream(name string) (*stream, error) {
+	l, err := openLog(filepath.Join(s.dir, "streams", url.PathEscape(name)), s.opts.SegmentSize)
+	if err != nil {
+		return nil, err
+	}
+	st := &stream{name: name, log: l}
+	s.streams[name] = st
+	return st, nil
+}
+
+// Append adds records to a stream if it is at expectedVersion, AnyVersion skips the check.
+// It returns the global position of the last record.
+func (s *Store) Append(name string, expectedVersion int64, records ...Record) (int64, error) {
+	if name == "" || name == "." || name == ".." {
+		return 0, fmt.Errorf("invalid stream name %q", name)
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.closed {
+		return 0, ErrClosed
+	}
+
+	st, ok := s.streams[name]
+	if !ok {
+		var err error
+		st, err = s.openStream(name)
+		if err != nil {
+			return 0, err
+		}
+		err = syncDir(filepath.Join(s.dir, "streams"))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	version := int64(len(st.locations))
+	if expectedVersion != AnyVersion && expectedVersion != version {
+		return 0, ConflictError{Stream: name, Expected: expectedVersion, Actual: version}
+	}
+	if len(records) == 0 {
+		return int64(len(s.entries)), nil
+	}
+
+	streamMark := st.log.mark()
+	globalMark := s.global.mark()
+	rollback := func(err error) (int64, error) {
+		if e := st.log.rollback(streamMark); e != nil {
+			return 0, fmt.Errorf("%s, rollback failed: %s", err, e)
+		}
+		if e := s.global.rollback(globalMark); e != nil {
+			return 0, fmt.Errorf("%s, rollback failed: %s", err, e)
+		}
+		return 0, err
+	}
+
+	now := time.Now()
+	position := int64(len(s.entries))
+	locations := make([]location, len(records))
+	for i, r := range records {
+		loc, err := st.log.append(version+int64(i)+1, encodeEvent(position+int64(i)+1, version+int64(i)+1, now, r))
+		if err != nil {
+			return rollback(err)
+		}
+		locations[i] = loc
+	}
+	// the global log commits the events, so the streams need to hit the disk first
+	if s.opts.Sync == SyncAlways {
+		if err := st.log.sync(); err != nil {
+			return rollback(err)
+		}
+	}
+	for i := range records {
+		_, err := s.global.append(position+int64(i)+1, encodeEntry(position+int64(i)+1, version+int64(i)+1, name))
+		if err != nil {
+			return rollback(err)
+		}
+	}
+	if s.opts.Sync == SyncAlways {
+		if err := s.global.sync(); err != nil {
+			return rollback(err)
+		}
+	}
+
+	for i := range records {
+		st.locations = append(st.locations, locations[i])
+		s.entries = append(s.entries, entry{stream: st, version: version + int64(i) + 1})
+	}
+	s.dirty = true
+
+	close(s.notify)
+	s.notify = make(chan struct{})
+
+	return int64(len(s.entries)), nil
+}
+
+// Version returns the number of events in a stream
+func (s *Store) Version(name string) int64 {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	st, ok := s.streams[name]
+	if !ok {
+		return 0
+	}
+	return int64(len(st.locations))
+}
+
+// Position returns the global position of the latest event
+func (s *Store) Position() int64 {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return int64(len(s.entries))
+}
+
+// ReadStream returns the events of a stream starting with version from
+func (s *Store) ReadStream(name string, from int64) ([]Event, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	st, ok := s.streams[name]
+	if !ok {
+		return nil, nil
+	}
+	if from < 1 {
+		from = 1
+	}
+
+	events := []Event{}
+	for v := from; v <= int64(len(st.locations)); v++ {
+		e, err := s.read(st, v)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// readGlobal returns up to max events starting at position from and a channel that gets closed by the next append
+func (s *Store) readGlobal(from int64, max int) ([]Event, <-chan struct{}, error) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if s.closed {
+		return nil, nil, ErrClosed
+	}
+
+	events := []Event{}
+	for p := from; p <= int64(len(s.entries)) && len(events) < max; p++ {
+		en := s.entries[p-1]
+		e, err := s.read(en.stream, en.version)
+		if err != nil {
+			return nil, nil, err
+		}
+		events = append(events, e)
+	}
+	return events, s.notify, nil
+}
+
+func (s *Store) read(st *stream, version int64) (Event, error) {
+	body, err := st.log.read(st.locations[version-1])
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to read event %d of stream %s: %s", version, st.name, err)
+	}
+	e, err := decodeEvent(body)
+	if err != nil {
+		return Event{}, fmt.Errorf("failed to decode event %d of stream %s: %s", version, st.name, err)
+	}
+	e.Stream = st.name
+	return e, nil
+}
+
+func (s *Store) syncLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mux.Lock()
+			err := s.syncAll()
+			s.mux.Unlock()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to sync event store: %s\n", err)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *Store) syncAll() error {
+	if !s.dirty {
+		return nil
+	}
+	for _, st := range s.streams {
+		err := st.log.sync()
+		if err != nil {
+			return err
+		}
+	}
+	err := s.global.sync()
+	if err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// Close flushes and closes all files of the store
+func (s *Store) Close() error {
+	s.mux.Lock()
+	if s.closed {
+		s.mux.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	var err error
+	if s.opts.Sync != SyncNever {
+		err = s.syncAll()
+	}
+	close(s.notify)
+	s.mux.Unlock()
+
+	s.wg.Wait()
+	if e := s.closeFiles(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func (s *Store) closeFiles() error {
+	var err error
+	for _, st := range s.streams {
+		if e := st.log.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if s.global != nil {
+		if e := s.global.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if e := s.lock.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+func encodeEvent(position, version int64, timestamp time.Time, r Record) []byte {
+	b := make([]byte, 32+len(r.Type)+len(r.Key)+len(r.Value))
+	binary.BigEndian.PutUint64(b[0:8], uint64(position))
+	binary.BigEndian.PutUint64(b[8:16], uint64(version))
+	binary.BigEndian.PutUint64(b[16:24], uint64(timestamp.UnixNano()))
+	binary.BigEndian.PutUint32(b[24:28], uint32(len(r.Type)))
+	n := 28 + copy(b[28:], r.Type)
+	binary.BigEndian.PutUint32(b[n:n+4], uint32(len(r.Key)))
+	n += 4 + copy(b[n+4:], r.Key)
+	copy(b[n:], r.Value)
+	return b
+}
+
+func decodeEvent(b []byte) (Event, error) {
+	if len(b) < 32 {
+		return Event{}, fmt.Errorf("event too short")
+	}
+	e := Event{
+		Position:  int64(binary.BigEndian.Uint64(b[0:8])),
+		Version:   int64(binary.BigEndian.Uint64(b[8:16])),
+		Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(b[16:24]))),
+	}
+	n := 28 + int(binary.BigEndian.Uint32(b[24:28]))
+	if n+4 > len(b) {
+		return Event{}, fmt.Errorf("invalid type length")
+	}
+	e.Type = string(b[28:n])
+	m := n + 4 + int(binary.BigEndian.Uint32(b[n:n+4]))
+	if m > len(b) {
+		return Event{}, fmt.Errorf("invalid key length")
+	}
+	e.Key = b[n+4 : m]
+	e.Value = b[m:]
+	return e, nil
+}
+
+func encodeEntry(position, version int64, name string) []byte {
+	b := make([]byte, 16+len(name))
+	binary.BigEndian.PutUint64(b[0:8], uint64(position))
+	binary.BigEndian.PutUint64(b[8:16], uint64(version))
+	copy(b[16:], name)
+	return b
+}
+
+func decodeEntry(b []byte) (int64, int64, string, error) {
+	if len(b) < 16 {
+		return 0, 0, "", fmt.Errorf("global log entry too short")
+	}
+	return int64(binary.BigEndian.Uint64(b[0:8])), int64(binary.BigEndian.Uint64(b[8:16])), string(b[16:]), nil
+}
@@ -0,0 +1,258 @@
+package eventstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "eventstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func open(t *testing.T, dir string, opts Options) *Store {
+	t.Helper()
+	s, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func record(value string) Record {
+	return Record{Type: "test", Key: []byte("key"), Value: []byte(value)}
+}
+
+func appendValues(t *testing.T, s *Store, stream string, values ...string) {
+	t.Helper()
+	for _, v := range values {
+		_, err := s.Append(stream, AnyVersion, record(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// values returns the values of a stream, joined by spaces
+func values(t *testing.T, s *Store, stream string) string {
+	t.Helper()
+	events, err := s.ReadStream(stream, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vs := []string{}
+	for i, e := range events {
+		if e.Version != int64(i+1) {
+			t.Fatalf("expected version %d of stream %s, got %d", i+1, stream, e.Version)
+		}
+		vs = append(vs, string(e.Value))
+	}
+	return strings.Join(vs, " ")
+}
+
+// lastSegment returns the path of the newest segment of a log directory
+func lastSegment(t *testing.T, dir string) string {
+	t.Helper()
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if err != nil || len(segments) == 0 {
+		t.Fatalf("no segments in %s: %v", dir, err)
+	}
+	return segments[len(segments)-1]
+}
+
+func appendBytes(t *testing.T, path string, b []byte) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, err = f.Write(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVersionConflict(t *testing.T) {
+	s := open(t, tempDir(t), DefaultOptions())
+
+	position, err := s.Append("lamp", 0, record("created"), record("repriced"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position != 2 || s.Version("lamp") != 2 {
+		t.Fatalf("expected position 2 and version 2, got %d and %d", position, s.Version("lamp"))
+	}
+
+	_, err = s.Append("lamp", 1, record("renamed"))
+	conflict, ok := err.(ConflictError)
+	if !ok || conflict.Stream != "lamp" || conflict.Expected != 1 || conflict.Actual != 2 {
+		t.Fatalf("expected a conflict at version 2, got %v", err)
+	}
+	_, err = s.Append("table", 1, record("created"))
+	if _, ok := err.(ConflictError); !ok {
+		t.Fatalf("expected a conflict for a stream that does not exist yet, got %v", err)
+	}
+
+	_, err = s.Append("lamp", 2, record("renamed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Append("lamp", AnyVersion, record("deleted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := values(t, s, "lamp"); v != "created repriced renamed deleted" {
+		t.Fatalf("unexpected events %s", v)
+	}
+}
+
+func TestSegmentRolling(t *testing.T) {
+	dir := tempDir(t)
+	opts := DefaultOptions()
+	opts.SegmentSize = 100
+	s := open(t, dir, opts)
+
+	expected := []string{}
+	for i := 0; i < 20; i++ {
+		expected = append(expected, fmt.Sprintf("event-%d", i))
+	}
+	appendValues(t, s, "lamp", expected...)
+	appendValues(t, s, "table", "created")
+
+	streamSegments, _ := filepath.Glob(filepath.Join(dir, "streams", "lamp", "*"+segmentSuffix))
+	globalSegments, _ := filepath.Glob(filepath.Join(dir, "global", "*"+segmentSuffix))
+	if len(streamSegments) < 2 || len(globalSegments) < 2 {
+		t.Fatalf("expected the logs to roll, got %d stream and %d global segments", len(streamSegments), len(globalSegments))
+	}
+	// a segment is named by the version of its first event
+	if filepath.Base(streamSegments[0]) != fmt.Sprintf("%020d%s", 1, segmentSuffix) {
+		t.Fatalf("unexpected first segment %s", streamSegments[0])
+	}
+
+	err := s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = open(t, dir, opts)
+	if v := values(t, s, "lamp"); v != strings.Join(expected, " ") {
+		t.Fatalf("expected all events after reopening, got %s", v)
+	}
+	events, _, err := s.readGlobal(1, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 21 || events[20].Stream != "table" || events[20].Position != 21 {
+		t.Fatalf("expected 21 events in global order, got %d", len(events))
+	}
+}
+
+// frame encodes a body like log.append does
+func frame(body []byte) []byte {
+	b := make([]byte, frameHeader+len(body))
+	binary.BigEndian.PutUint32(b[0:4], uint32(len(body)))
+	binary.BigEndian.PutUint32(b[4:8], crc32.ChecksumIEEE(body))
+	copy(b[frameHeader:], body)
+	return b
+}
+
+func TestRecoverTornTail(t *testing.T) {
+	dir := tempDir(t)
+	s := open(t, dir, DefaultOptions())
+	appendValues(t, s, "lamp", "created", "repriced")
+	appendValues(t, s, "table", "created")
+	err := s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash within appends: the next event of the table made it into its stream but not into the global log,
+	// the next frame of the lamp and of the global log are torn
+	appendBytes(t, lastSegment(t, filepath.Join(dir, "streams", "table")), frame(encodeEvent(4, 2, time.Now(), record("orphan"))))
+	torn := frame(encodeEvent(5, 3, time.Now(), record("torn")))
+	appendBytes(t, lastSegment(t, filepath.Join(dir, "streams", "lamp")), torn[:len(torn)-3])
+	entry := frame(encodeEntry(4, 2, "table"))
+	appendBytes(t, lastSegment(t, filepath.Join(dir, "global")), entry[:frameHeader+2])
+
+	s = open(t, dir, DefaultOptions())
+	if s.Position() != 3 || values(t, s, "lamp") != "created repriced" || values(t, s, "table") != "created" {
+		t.Fatalf("expected the 3 committed events, got position %d, lamp %q and table %q",
+			s.Position(), values(t, s, "lamp"), values(t, s, "table"))
+	}
+
+	// the cut tails get overwritten by the next appends
+	_, err = s.Append("table", 1, record("repriced"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Append("lamp", 2, record("renamed"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = open(t, dir, DefaultOptions())
+	if s.Position() != 5 || values(t, s, "lamp") != "created repriced renamed" || values(t, s, "table") != "created repriced" {
+		t.Fatalf("expected 5 events after reopening, got position %d, lamp %q and table %q",
+			s.Position(), values(t, s, "lamp"), values(t, s, "table"))
+	}
+}
+
+func TestRollbackFailedAppend(t *testing.T) {
+	dir := tempDir(t)
+	opts := DefaultOptions()
+	// every frame starts a segment
+	opts.SegmentSize = 1
+	s := open(t, dir, opts)
+	appendValues(t, s, "lamp", "created")
+
+	// the segment of the next global entry exists already, so writing the global log fails
+	// after the events got written to the stream
+	blocker := filepath.Join(dir, "global", fmt.Sprintf("%020d%s", 3, segmentSuffix))
+	err := ioutil.WriteFile(blocker, nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Append("lamp", 1, record("repriced"), record("renamed"))
+	if err == nil {
+		t.Fatal("expected the append to fail")
+	}
+	if s.Version("lamp") != 1 || s.Position() != 1 || values(t, s, "lamp") != "created" {
+		t.Fatalf("expected the failed append to leave no events, got version %d at position %d", s.Version("lamp"), s.Position())
+	}
+	segments, _ := filepath.Glob(filepath.Join(dir, "streams", "lamp", "*"+segmentSuffix))
+	if len(segments) != 1 {
+		t.Fatalf("expected the segments of the failed append to be removed, got %v", segments)
+	}
+
+	err = os.Remove(blocker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Append("lamp", 1, record("repriced"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = open(t, dir, opts)
+	if s.Position() != 2 || values(t, s, "lamp") != "created repriced" {
+		t.Fatalf("expected 2 events after reopening, got position %d and %q", s.Position(), values(t, s, "lamp"))
+	}
+}
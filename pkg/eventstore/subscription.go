@@ -0,0 +1,267 @@
+package eventstore
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+const subscriptionBatch = 1000
+const offsetFlushInterval = time.Second
+
+// Topic is the topic of all messages of a subscription
+const Topic = "eventstore"
+
+// StreamHeader carries the stream name of an event
+const StreamHeader = "stream"
+
+// Subscription delivers the events of a store in global order as kafka messages.
+// The partition of a message is a hash of the stream name, so the events of a stream
+// keep their order and simba works with a fixed set of partitions. The offset is the global position.
+// It implements simba.Source.
+type Subscription struct {
+	store   *Store
+	group   string
+	msgs    chan *sarama.ConsumerMessage
+	errs    chan error
+	closing chan struct{}
+	wg      sync.WaitGroup
+	mux     sync.Mutex
+	marked  int64
+	flushed int64
+	// delivered and per partition marked positions
+	delivered  []delivery
+	partitions []int64
+	stopOnce   sync.Once
+}
+
+// Subscribe delivers all events starting at position from
+func (s *Store) Subscribe(from int64) *Subscription {
+	sub := newSubscription(s, "")
+	sub.start(from)
+	return sub
+}
+
+// SubscribeGroup resumes after the last offset marked by the group
+func (s *Store) SubscribeGroup(group string) (*Subscription, error) {
+	sub := newSubscription(s, group)
+	marked, err := sub.loadOffset()
+	if err != nil {
+		return nil, err
+	}
+	sub.marked = marked
+	sub.flushed = marked
+	sub.start(marked + 1)
+	return sub, nil
+}
+
+// delivery is the partition and position of a delivered event
+type delivery struct {
+	partition int32
+	position  int64
+}
+
+func newSubscription(s *Store, group string) *Subscription {
+	partitions := s.opts.Partitions
+	if partitions < 1 {
+		partitions = 1
+	}
+	return &Subscription{
+		store:      s,
+		group:      group,
+		msgs:       make(chan *sarama.ConsumerMessage, subscriptionBatch),
+		errs:       make(chan error, 1),
+		closing:    make(chan struct{}),
+		partitions: make([]int64, partitions),
+	}
+}
+
+func (sub *Subscription) start(from int64) {
+	if from < 1 {
+		from = 1
+	}
+	sub.wg.Add(1)
+	go sub.deliver(from)
+	if sub.group != "" {
+		sub.wg.Add(1)
+		go sub.flushLoop()
+	}
+}
+
+func (sub *Subscription) deliver(position int64) {
+	defer sub.wg.Done()
+	for {
+		events, next, err := sub.store.readGlobal(position, subscriptionBatch)
+		if err == ErrClosed {
+			return
+		}
+		if err != nil {
+			select {
+			case <-sub.closing:
+			default:
+				sub.errs <- err
+			}
+			return
+		}
+		for _, e := range events {
+			msg := message(e, sub.partition(e.Stream))
+			sub.mux.Lock()
+			sub.delivered = append(sub.delivered, delivery{partition: msg.Partition, position: e.Position})
+			sub.mux.Unlock()
+			select {
+			case sub.msgs <- msg:
+				position = e.Position + 1
+			case <-sub.closing:
+				return
+			}
+		}
+		if len(events) == subscriptionBatch {
+			continue
+		}
+		select {
+		case <-next:
+		case <-sub.closing:
+			return
+		}
+	}
+}
+
+// partition hashes a stream name onto the partitions of the subscription
+func (sub *Subscription) partition(stream string) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(stream))
+	return int32(h.Sum32() % uint32(len(sub.partitions)))
+}
+
+func message(e Event, partition int32) *sarama.ConsumerMessage {
+	msg := &sarama.ConsumerMessage{
+		Topic:     Topic,
+		Partition: partition,
+		Offset:    e.Position,
+		Key:       e.Key,
+		Value:     e.Value,
+		Timestamp: e.Timestamp,
+		Headers:   []*sarama.RecordHeader{{Key: []byte(StreamHeader), Value: []byte(e.Stream)}},
+	}
+	if e.Type != "" {
+		msg.Headers = append(msg.Headers, &sarama.RecordHeader{Key: []byte("type"), Value: []byte(e.Type)})
+	}
+	return msg
+}
+
+// Messages delivers the events
+func (sub *Subscription) Messages() <-chan *sarama.ConsumerMessage {
+	return sub.msgs
+}
+
+// Errors reports read failures, the subscription stops after an error
+func (sub *Subscription) Errors() <-chan error {
+	return sub.errs
+}
+
+// MarkOffset remembers the message and its predecessors of the same partition as processed.
+// The global offset of the group only advances over events whose partitions got marked past them.
+func (sub *Subscription) MarkOffset(msg *sarama.ConsumerMessage, metadata string) {
+	sub.mux.Lock()
+	defer sub.mux.Unlock()
+	if msg.Partition < 0 || int(msg.Partition) >= len(sub.partitions) {
+		return
+	}
+	if msg.Offset > sub.partitions[msg.Partition] {
+		sub.partitions[msg.Partition] = msg.Offset
+	}
+	i := 0
+	for i < len(sub.delivered) && sub.partitions[sub.delivered[i].partition] >= sub.delivered[i].position {
+		i++
+	}
+	if i == 0 {
+		return
+	}
+	sub.marked = sub.delivered[i-1].position
+	sub.delivered = sub.delivered[i:]
+}
+
+func (sub *Subscription) flushLoop() {
+	defer sub.wg.Done()
+	ticker := time.NewTicker(offsetFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := sub.flushOffset()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to store offset of group %s: %s\n", sub.group, err)
+			}
+		case <-sub.closing:
+			return
+		}
+	}
+}
+
+func (sub *Subscription) offsetPath() string {
+	return filepath.Join(sub.store.dir, "groups", url.PathEscape(sub.group))
+}
+
+func (sub *Subscription) loadOffset() (int64, error) {
+	b, err := ioutil.ReadFile(sub.offsetPath())
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read offset of group %s: %s", sub.group, err)
+	}
+	offset, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse offset of group %s: %s", sub.group, err)
+	}
+	return offset, nil
+}
+
+// flushOffset writes the marked offset atomically by renaming a temporary file
+func (sub *Subscription) flushOffset() error {
+	sub.mux.Lock()
+	marked := sub.marked
+	sub.mux.Unlock()
+	if marked == sub.flushed {
+		return nil
+	}
+
+	path := sub.offsetPath()
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	err = ioutil.WriteFile(tmp, []byte(strconv.FormatInt(marked, 10)+"\n"), 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return err
+	}
+	sub.flushed = marked
+	return nil
+}
+
+// Close stops the delivery and stores the marked offset of the group
+func (sub *Subscription) Close() error {
+	var err error
+	sub.stopOnce.Do(func() {
+		close(sub.closing)
+		sub.wg.Wait()
+		if sub.group != "" {
+			err = sub.flushOffset()
+		}
+	})
+	return err
+}
@@ -0,0 +1,133 @@
+package eventstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func receive(t *testing.T, sub *Subscription, n int) []*sarama.ConsumerMessage {
+	t.Helper()
+	msgs := []*sarama.ConsumerMessage{}
+	for len(msgs) < n {
+		select {
+		case msg := <-sub.Messages():
+			msgs = append(msgs, msg)
+		case err := <-sub.Errors():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received %d of %d messages", len(msgs), n)
+		}
+	}
+	return msgs
+}
+
+func streamOf(msg *sarama.ConsumerMessage) string {
+	for _, h := range msg.Headers {
+		if string(h.Key) == StreamHeader {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// twoPartitions returns two stream names the subscription delivers in different partitions
+func twoPartitions(sub *Subscription) (string, string) {
+	a := "stream-0"
+	for i := 1; ; i++ {
+		b := fmt.Sprintf("stream-%d", i)
+		if sub.partition(b) != sub.partition(a) {
+			return a, b
+		}
+	}
+}
+
+func TestSubscriptionPartitions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Partitions = 4
+	s := open(t, tempDir(t), opts)
+	for i := 0; i < 100; i++ {
+		appendValues(t, s, fmt.Sprintf("product-%d", i), "created", "repriced")
+	}
+
+	sub := s.Subscribe(1)
+	defer sub.Close()
+	partitions := map[string]int32{}
+	for i, msg := range receive(t, sub, 200) {
+		if msg.Topic != Topic || msg.Offset != int64(i+1) {
+			t.Fatalf("expected position %d of topic %s, got %d of %s", i+1, Topic, msg.Offset, msg.Topic)
+		}
+		if msg.Partition < 0 || msg.Partition >= 4 {
+			t.Fatalf("unexpected partition %d", msg.Partition)
+		}
+		// the events of a stream stay in one partition
+		name := streamOf(msg)
+		if p, ok := partitions[name]; ok && p != msg.Partition {
+			t.Fatalf("stream %s is delivered in the partitions %d and %d", name, p, msg.Partition)
+		}
+		partitions[name] = msg.Partition
+	}
+	if len(partitions) != 100 || len(sub.partitions) != 4 {
+		t.Fatalf("expected 100 streams in 4 partitions, got %d streams and %d partitions", len(partitions), len(sub.partitions))
+	}
+}
+
+func TestSubscriptionResumesAfterMarkedOffset(t *testing.T) {
+	dir := tempDir(t)
+	s := open(t, dir, DefaultOptions())
+
+	sub, err := s.SubscribeGroup("view")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := twoPartitions(sub)
+	appendValues(t, s, a, "a1")
+	appendValues(t, s, b, "b1", "b2")
+	appendValues(t, s, a, "a2")
+
+	msgs := receive(t, sub, 4)
+	// the partition of b is done, the first event of a holds the offset back
+	sub.MarkOffset(msgs[2], "")
+	if sub.marked != 0 {
+		t.Fatalf("expected no marked offset while %s is pending, got %d", a, sub.marked)
+	}
+	sub.MarkOffset(msgs[0], "")
+	if sub.marked != 3 {
+		t.Fatalf("expected offset 3 to be marked, got %d", sub.marked)
+	}
+	err = sub.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the group resumes with the unmarked event, also after reopening the store
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = open(t, dir, DefaultOptions())
+	sub, err = s.SubscribeGroup("view")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	appendValues(t, s, b, "b3")
+
+	msgs = receive(t, sub, 2)
+	if msgs[0].Offset != 4 || string(msgs[0].Value) != "a2" || msgs[1].Offset != 5 || streamOf(msgs[1]) != b {
+		t.Fatalf("expected a2 at offset 4 and b3 at offset 5, got %s at %d and %s at %d",
+			msgs[0].Value, msgs[0].Offset, msgs[1].Value, msgs[1].Offset)
+	}
+
+	// another group starts at the beginning
+	other, err := s.SubscribeGroup("other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if msgs := receive(t, other, 1); msgs[0].Offset != 1 {
+		t.Fatalf("expected a new group to start at offset 1, got %d", msgs[0].Offset)
+	}
+}
@@ -0,0 +1,266 @@
+package fake
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/satori/go.uuid"
+)
+
+// Prices lists the supported price distributions
+var Prices = []string{"uniform", "normal", "lognormal"}
+
+// TextSizes lists the supported lengths of descriptions and long texts
+var TextSizes = []string{"small", "medium", "large"}
+
+// Options shape the generated products
+type Options struct {
+	// Categories limits the products to a pool of categories, 0 generates a new category for every product
+	Categories int
+	// CategorySeed derives the pool, generators with different seeds share it
+	CategorySeed int64
+	// Zipf skews the pool with the exponent s > 1, 0 picks the categories uniformly
+	Zipf float64
+	// Prices is one of Prices
+	Prices string
+	// MeanPrice in minor units
+	MeanPrice int64
+	// TextSize is one of TextSizes
+	TextSize string
+}
+
+// DefaultOptions match the products of the first fake data generators
+var DefaultOptions = Options{
+	Prices:    "uniform",
+	MeanPrice: 5000,
+	TextSize:  "medium",
+}
+
+// textSize bounds the words of a description and the sentences of a long text
+type textSize struct {
+	minWords, maxWords         int
+	minSentences, maxSentences int
+}
+
+var textSizes = map[string]textSize{
+	"small":  {minWords: 4, maxWords: 8, minSentences: 1, maxSentences: 2},
+	"medium": {minWords: 12, maxWords: 24, minSentences: 3, maxSentences: 6},
+	"large":  {minWords: 24, maxWords: 48, minSentences: 12, maxSentences: 24},
+}
+
+// Generator derives all fields from one seeded random source, the same seed and call sequence give the same rows
+type Generator struct {
+	rnd        *rand.Rand
+	opts       Options
+	text       textSize
+	categories []string
+	zipf       *rand.Zipf
+}
+
+// New constructs a Generator
+func New(seed int64, o Options) (*Generator, error) {
+	text, ok := textSizes[o.TextSize]
+	if !ok {
+		return nil, fmt.Errorf("unknown text size %q", o.TextSize)
+	}
+	switch o.Prices {
+	case "uniform", "normal", "lognormal":
+	default:
+		return nil, fmt.Errorf("unknown price distribution %q", o.Prices)
+	}
+	if o.MeanPrice <= 0 {
+		return nil, fmt.Errorf("mean price needs to be positive, got %d", o.MeanPrice)
+	}
+	if o.Categories < 0 || o.Categories > len(words)*len(words) {
+		return nil, fmt.Errorf("number of categories needs to be between 0 and %d, got %d", len(words)*len(words), o.Categories)
+	}
+	if o.Zipf != 0 && (o.Zipf <= 1 || o.Categories == 0) {
+		return nil, fmt.Errorf("zipf needs an exponent above 1 and a pool of categories")
+	}
+
+	g := &Generator{
+		rnd:  rand.New(rand.NewSource(seed)),
+		opts: o,
+		text: text,
+	}
+
+	if o.Categories > 0 {
+		pool := rand.New(rand.NewSource(o.CategorySeed))
+		seen := map[string]bool{}
+		for len(g.categories) < o.Categories {
+			c := categoryName(pool)
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+			g.categories = append(g.categories, c)
+		}
+		if o.Zipf != 0 {
+			g.zipf = rand.NewZipf(g.rnd, o.Zipf, 1, uint64(o.Categories-1))
+		}
+	}
+
+	return g, nil
+}
+
+// Intn exposes the random source to decide about rows deterministically
+func (g *Generator) Intn(n int) int {
+	return g.rnd.Intn(n)
+}
+
+// Perm exposes the random source to pick rows deterministically
+func (g *Generator) Perm(n int) []int {
+	return g.rnd.Perm(n)
+}
+
+// UUID returns a version 4 uuid made of the seeded random source
+func (g *Generator) UUID() string {
+	u := uuid.UUID{}
+	g.rnd.Read(u[:])
+	u.SetVersion(uuid.V4)
+	u.SetVariant(uuid.VariantRFC4122)
+	return u.String()
+}
+
+// Title returns three words
+func (g *Generator) Title() string {
+	return fmt.Sprintf("%s %s %s", word(g.rnd), word(g.rnd), word(g.rnd))
+}
+
+// Description returns a sentence
+func (g *Generator) Description() string {
+	return sentence(g.rnd, between(g.rnd, g.text.minWords, g.text.maxWords))
+}
+
+// Longtext returns a paragraph
+func (g *Generator) Longtext() string {
+	n := between(g.rnd, g.text.minSentences, g.text.maxSentences)
+	sentences := make([]string, n)
+	for i := range sentences {
+		sentences[i] = sentence(g.rnd, between(g.rnd, 5, 22))
+	}
+	return strings.Join(sentences, " ")
+}
+
+// Category picks from the pool or generates a new category
+func (g *Generator) Category() string {
+	switch {
+	case g.zipf != nil:
+		return g.categories[g.zipf.Uint64()]
+	case len(g.categories) > 0:
+		return g.categories[g.rnd.Intn(len(g.categories))]
+	}
+	return categoryName(g.rnd)
+}
+
+// OtherCategory returns a category that differs from the given one
+func (g *Generator) OtherCategory(current string) (string, error) {
+	if len(g.categories) == 1 && g.categories[0] == current {
+		return "", fmt.Errorf("a pool of one category does not allow to move products")
+	}
+	for {
+		c := g.Category()
+		if c != current {
+			return c, nil
+		}
+	}
+}
+
+// URL returns an image url
+func (g *Generator) URL() string {
+	return fmt.Sprintf("http://www.%s.%s/%s/%s.jpg", word(g.rnd), tlds[g.rnd.Intn(len(tlds))], word(g.rnd), word(g.rnd))
+}
+
+// Price returns a decimal with two places like csv-import expects
+func (g *Generator) Price() string {
+	cents := g.Units()
+	return fmt.Sprintf("%d.%02d", cents/100, cents%100)
+}
+
+// Units returns a price in minor units
+func (g *Generator) Units() int64 {
+	mean := float64(g.opts.MeanPrice)
+	var units float64
+	switch g.opts.Prices {
+	case "uniform":
+		units = g.rnd.Float64() * 2 * mean
+	case "normal":
+		units = g.rnd.NormFloat64()*mean/3 + mean
+	case "lognormal":
+		// sigma 1 and mu chosen for the mean, most prices are low with a long tail of expensive products
+		units = math.Exp(g.rnd.NormFloat64() + math.Log(mean) - 0.5)
+	}
+	if units < 1 {
+		return 1
+	}
+	return int64(units)
+}
+
+// Row returns a product in the positional column order of csv-import
+func (g *Generator) Row() []string {
+	return []string{
+		g.UUID(),
+		g.Title(),
+		g.Description(),
+		g.Longtext(),
+		g.Category(),
+		g.URL(),
+		g.URL(),
+		g.Price(),
+	}
+}
+
+// Product returns a product with a price in the currency
+func (g *Generator) Product(currency string) *pb.Product {
+	return &pb.Product{
+		Uuid:          g.UUID(),
+		Title:         g.Title(),
+		Description:   g.Description(),
+		Longtext:      g.Longtext(),
+		Category:      g.Category(),
+		SmallImageURL: g.URL(),
+		LargeImageURL: g.URL(),
+		Price:         &pb.Money{Units: g.Units(), Currency: currency},
+	}
+}
+
+func categoryName(rnd *rand.Rand) string {
+	return fmt.Sprintf("%s/%s", word(rnd), word(rnd))
+}
+
+func between(rnd *rand.Rand, min, max int) int {
+	return min + rnd.Intn(max-min+1)
+}
+
+func word(rnd *rand.Rand) string {
+	return words[rnd.Intn(len(words))]
+}
+
+func sentence(rnd *rand.Rand, n int) string {
+	ws := make([]string, n)
+	for i := range ws {
+		ws[i] = word(rnd)
+	}
+	s := strings.Join(ws, " ") + "."
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+var tlds = []string{"com", "net", "org", "de", "io"}
+
+var words = []string{
+	"abditioribus", "accipiens", "admiratio", "aeterna", "amore", "animae", "apud", "audire",
+	"beatitudinis", "bonum", "caelum", "carnis", "causa", "certe", "cogitatione", "conscientia",
+	"consuetudo", "corpus", "cura", "delectatio", "deus", "dicere", "domine", "dulcedo",
+	"ecce", "enim", "ergo", "errore", "etiam", "excellentiam", "fallacia", "fide",
+	"gaudium", "gloria", "gratia", "homines", "honor", "ignorantia", "imago", "inde",
+	"ipsa", "itaque", "iudicium", "labor", "lingua", "lucem", "magis", "manifestum",
+	"memoria", "mens", "miseria", "modo", "mundi", "natura", "nihil", "nomen",
+	"nunc", "oculi", "omnia", "opera", "otium", "pacem", "perit", "plena",
+	"potius", "quaero", "quidem", "ratio", "rebus", "requies", "sane", "sapientia",
+	"scio", "sensus", "sermo", "sicut", "silentio", "spiritus", "tamen", "tempus",
+	"terra", "tibi", "tota", "ubi", "umquam", "unde", "usque", "valde",
+	"vanitas", "veritas", "via", "vita", "voce", "voluntas", "vox", "vult",
+}
@@ -0,0 +1,84 @@
+package feed
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+)
+
+type csvReader struct {
+	f         *os.File
+	r         *csv.Reader
+	positions []int
+	width     int
+	line      int
+}
+
+func newCSVReader(path string, l *Layout) (Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import file: %s", err)
+	}
+
+	r, err := l.reader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	var header []string
+	if l.Header {
+		header, err = r.Read()
+		if err != nil && err != io.EOF {
+			f.Close()
+			return nil, fmt.Errorf("failed to read header: %s", err)
+		}
+	}
+	positions, err := l.positions(header)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &csvReader{
+		f:         f,
+		r:         r,
+		positions: positions,
+		width:     l.width(header),
+	}, nil
+}
+
+func (c *csvReader) Read() (*pb.Product, error) {
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		perr, ok := err.(*csv.ParseError)
+		if !ok {
+			return nil, fmt.Errorf("failed to read import file: %s", err)
+		}
+		c.line = perr.Line
+		return nil, &RecordError{Pos: perr.Line, Record: record, Reasons: []string{perr.Err.Error()}}
+	}
+
+	c.line, _ = c.r.FieldPos(0)
+
+	if c.width > 0 && len(record) != c.width {
+		reason := fmt.Sprintf("expected %d columns, found %d", c.width, len(record))
+		return nil, &RecordError{Pos: c.line, Record: record, Reasons: []string{reason}}
+	}
+
+	return parse(c.line, record, arrange(record, c.positions))
+}
+
+func (c *csvReader) Pos() int {
+	return c.line
+}
+
+func (c *csvReader) Close() error {
+	return c.f.Close()
+}
@@ -0,0 +1,83 @@
+package feed
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// windows1252 holds the code points of 0x80-0x9f, the range where windows-1252 differs from latin1
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
+
+// decoder returns a function that converts a reader of the named encoding to utf-8
+func decoder(encoding string) (func(io.Reader) io.Reader, error) {
+	switch strings.ToLower(encoding) {
+	case "", "utf-8", "utf8":
+		return skipBOM, nil
+	case "latin1", "iso-8859-1":
+		return func(r io.Reader) io.Reader {
+			return &charmapReader{r: bufio.NewReader(r)}
+		}, nil
+	case "windows-1252", "cp1252":
+		return func(r io.Reader) io.Reader {
+			return &charmapReader{r: bufio.NewReader(r), high: &windows1252}
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %s", encoding)
+}
+
+func skipBOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	bom, err := br.Peek(3)
+	if err == nil && bytes.Equal(bom, []byte{0xef, 0xbb, 0xbf}) {
+		br.Discard(3)
+	}
+	return br
+}
+
+// charmapReader decodes single byte encodings to utf-8
+type charmapReader struct {
+	r    *bufio.Reader
+	high *[32]rune
+	buf  []byte
+}
+
+func (c *charmapReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(c.buf) > 0 {
+			m := copy(p[n:], c.buf)
+			c.buf = c.buf[m:]
+			n += m
+			continue
+		}
+
+		b, err := c.r.ReadByte()
+		if err != nil {
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+
+		r := rune(b)
+		if c.high != nil && b >= 0x80 && b < 0xa0 {
+			r = c.high[b-0x80]
+		}
+		if r < utf8.RuneSelf {
+			p[n] = byte(r)
+			n++
+			continue
+		}
+		enc := make([]byte, utf8.RuneLen(r))
+		utf8.EncodeRune(enc, r)
+		c.buf = enc
+	}
+	return n, nil
+}
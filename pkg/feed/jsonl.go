@@ -0,0 +1,107 @@
+package feed
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+)
+
+const maxJSONLineSize = 16 * 1024 * 1024
+
+var shortDecimal = regexp.MustCompile(`^[0-9]+(\.[0-9]{1,2})?$`)
+
+// jsonlReader reads one json object per line
+type jsonlReader struct {
+	f      *os.File
+	s      *bufio.Scanner
+	fields map[string]int
+	line   int
+}
+
+func newJSONLReader(path string, l *Layout) (Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import file: %s", err)
+	}
+
+	decode, err := decoder(l.Encoding)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s := bufio.NewScanner(decode(f))
+	s.Buffer(make([]byte, 64*1024), maxJSONLineSize)
+
+	return &jsonlReader{
+		f:      f,
+		s:      s,
+		fields: l.fields(),
+	}, nil
+}
+
+func (j *jsonlReader) Read() (*pb.Product, error) {
+	for j.s.Scan() {
+		j.line++
+		line := bytes.TrimSpace(j.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		record := []string{string(line)}
+
+		obj := map[string]interface{}{}
+		d := json.NewDecoder(bytes.NewReader(line))
+		d.UseNumber()
+		err := d.Decode(&obj)
+		if err != nil {
+			return nil, &RecordError{Pos: j.line, Record: record, Reasons: []string{err.Error()}}
+		}
+
+		row := make([]string, len(schema))
+		for name, value := range obj {
+			i, ok := j.fields[name]
+			if !ok || value == nil {
+				continue
+			}
+			row[i] = jsonValue(value)
+		}
+
+		return parse(j.line, record, row)
+	}
+	if err := j.s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read import file: %s", err)
+	}
+	return nil, io.EOF
+}
+
+// jsonValue pads numbers with up to two decimal places, json does not keep trailing zeros
+func jsonValue(v interface{}) string {
+	n, ok := v.(json.Number)
+	if !ok {
+		return fmt.Sprint(v)
+	}
+	s := n.String()
+	if !shortDecimal.MatchString(s) {
+		return s
+	}
+	dot := strings.Index(s, ".")
+	if dot == -1 {
+		return s + ".00"
+	}
+	return s + strings.Repeat("0", 2-(len(s)-dot-1))
+}
+
+func (j *jsonlReader) Pos() int {
+	return j.line
+}
+
+func (j *jsonlReader) Close() error {
+	return j.f.Close()
+}
@@ -0,0 +1,196 @@
+package feed
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"unicode/utf8"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Layout describes how the columns of a partner feed map onto the schema
+type Layout struct {
+	// Header tells if the first row holds column names
+	Header bool `yaml:"header"`
+	// Delimiter separates the fields of a row, defaults to a comma
+	Delimiter string `yaml:"delimiter"`
+	// Comment starts a line that gets ignored
+	Comment string `yaml:"comment"`
+	// LazyQuotes allows quotes in unquoted fields and unescaped quotes in quoted fields
+	LazyQuotes bool `yaml:"lazyQuotes"`
+	// TrimLeadingSpace ignores leading white space of fields
+	TrimLeadingSpace bool `yaml:"trimLeadingSpace"`
+	// Encoding of the file: utf-8, latin1 or windows-1252
+	Encoding string `yaml:"encoding"`
+	// Columns maps column names, or positions for files without header, to schema fields
+	Columns map[string]string `yaml:"columns"`
+}
+
+// DefaultLayout matches the files of the fake generators
+func DefaultLayout() *Layout {
+	return &Layout{}
+}
+
+// LoadLayout reads a yaml or json mapping, an empty path gives the DefaultLayout
+func LoadLayout(path string) (*Layout, error) {
+	if path == "" {
+		return DefaultLayout(), nil
+	}
+
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping %s: %s", path, err)
+	}
+
+	l := DefaultLayout()
+	err = yaml.UnmarshalStrict(bytes, l)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mapping %s: %s", path, err)
+	}
+
+	for _, field := range l.Columns {
+		if schemaIndex(field) == -1 {
+			return nil, fmt.Errorf("mapping %s: unknown product field %s", path, field)
+		}
+	}
+	if _, err := decoder(l.Encoding); err != nil {
+		return nil, fmt.Errorf("mapping %s: %s", path, err)
+	}
+
+	return l, nil
+}
+
+// reader sets up a csv reader according to the layout
+func (l *Layout) reader(r io.Reader) (*csv.Reader, error) {
+	decode, err := decoder(l.Encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	c := csv.NewReader(decode(r))
+	c.FieldsPerRecord = -1
+	c.LazyQuotes = l.LazyQuotes
+	c.TrimLeadingSpace = l.TrimLeadingSpace
+	if l.Delimiter != "" {
+		c.Comma, err = singleRune("delimiter", l.Delimiter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if l.Comment != "" {
+		c.Comment, err = singleRune("comment", l.Comment)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// positions resolves the file column of each schema field.
+// A position of -1 marks a field that is not part of the file.
+func (l *Layout) positions(header []string) ([]int, error) {
+	positions := make([]int, len(schema))
+
+	if len(l.Columns) == 0 {
+		if !l.Header {
+			for i := range positions {
+				positions[i] = -1
+				if i < positionalColumns {
+					positions[i] = i
+				}
+			}
+			return positions, nil
+		}
+		for i, c := range schema {
+			positions[i] = indexOf(header, c.name)
+		}
+		return positions, nil
+	}
+
+	for i := range positions {
+		positions[i] = -1
+	}
+	for column, field := range l.Columns {
+		pos := -1
+		if l.Header {
+			pos = indexOf(header, column)
+			if pos == -1 {
+				return nil, fmt.Errorf("column %s not found in header", column)
+			}
+		} else {
+			var err error
+			pos, err = strconv.Atoi(column)
+			if err != nil {
+				return nil, fmt.Errorf("column %s needs to be a position for files without header", column)
+			}
+		}
+		positions[schemaIndex(field)] = pos
+	}
+	return positions, nil
+}
+
+// width returns the number of columns every record needs to have, 0 if unknown
+func (l *Layout) width(header []string) int {
+	if l.Header {
+		return len(header)
+	}
+	if len(l.Columns) == 0 {
+		return positionalColumns
+	}
+	return 0
+}
+
+// arrange reorders a record into schema order
+func arrange(record []string, positions []int) []string {
+	row := make([]string, len(positions))
+	for i, pos := range positions {
+		if pos >= 0 && pos < len(record) {
+			row[i] = record[pos]
+		}
+	}
+	return row
+}
+
+func schemaIndex(field string) int {
+	for i, c := range schema {
+		if c.name == field {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexOf(list []string, s string) int {
+	for i, e := range list {
+		if e == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func singleRune(name, s string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(s)
+	if size != len(s) {
+		return 0, fmt.Errorf("%s %q needs to be a single character", name, s)
+	}
+	return r, nil
+}
+
+// fields resolves the schema index of each named source field of record based formats
+func (l *Layout) fields() map[string]int {
+	fields := make(map[string]int)
+	if len(l.Columns) == 0 {
+		for i, c := range schema {
+			fields[c.name] = i
+		}
+		return fields
+	}
+	for column, field := range l.Columns {
+		fields[column] = schemaIndex(field)
+	}
+	return fields
+}
@@ -0,0 +1,145 @@
+package feed
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// parquetReader loads the mapped columns of a parquet file and yields them row by row
+type parquetReader struct {
+	f       source.ParquetFile
+	columns map[int]parquetColumn
+	rows    int
+	row     int
+}
+
+// parquetColumn holds the values of a column with the scale of decimal columns, -1 for other types
+type parquetColumn struct {
+	values []interface{}
+	scale  int32
+}
+
+func newParquetReader(path string, l *Layout) (Reader, error) {
+	f, err := local.NewLocalFileReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import file: %s", err)
+	}
+
+	r, err := reader.NewParquetColumnReader(f, 1)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read parquet footer: %s", err)
+	}
+	rows := r.GetNumRows()
+	root := r.SchemaHandler.GetRootExName()
+
+	columns := make(map[int]parquetColumn)
+	for name, i := range l.fields() {
+		path := root + "." + name
+		inPath, ok := r.SchemaHandler.ExPathToInPath[path]
+		if !ok {
+			continue
+		}
+		values, _, _, err := r.ReadColumnByPath(path, rows)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read column %s: %s", name, err)
+		}
+		element := r.SchemaHandler.SchemaElements[r.SchemaHandler.MapIndex[inPath]]
+		columns[i] = parquetColumn{values: values, scale: decimalScale(element)}
+	}
+
+	return &parquetReader{
+		f:       f,
+		columns: columns,
+		rows:    int(rows),
+	}, nil
+}
+
+func (p *parquetReader) Read() (*pb.Product, error) {
+	if p.row >= p.rows {
+		return nil, io.EOF
+	}
+	p.row++
+
+	row := make([]string, len(schema))
+	for i, c := range p.columns {
+		if p.row-1 < len(c.values) {
+			row[i] = parquetValue(c.values[p.row-1], c.scale)
+		}
+	}
+	return parse(p.row, row, row)
+}
+
+// decimalScale returns the scale of a decimal column, or -1 if the column holds no decimals
+func decimalScale(e *parquet.SchemaElement) int32 {
+	if e.LogicalType != nil && e.LogicalType.IsSetDECIMAL() {
+		return e.LogicalType.DECIMAL.Scale
+	}
+	if e.IsSetConvertedType() && e.GetConvertedType() == parquet.ConvertedType_DECIMAL {
+		return e.GetScale()
+	}
+	return -1
+}
+
+// parquetValue formats a value as text, decimals are formatted with their scale
+func parquetValue(v interface{}, scale int32) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if scale >= 0 {
+			// binary decimals are big endian two's complement integers
+			unscaled := new(big.Int).SetBytes([]byte(v))
+			if len(v) > 0 && v[0]&0x80 != 0 {
+				unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(v)*8)))
+			}
+			return decimal(unscaled, scale)
+		}
+		return v
+	case int32:
+		if scale >= 0 {
+			return decimal(big.NewInt(int64(v)), scale)
+		}
+		return strconv.FormatInt(int64(v), 10)
+	case int64:
+		if scale >= 0 {
+			return decimal(big.NewInt(v), scale)
+		}
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', 2, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// decimal formats an unscaled decimal with two places if that is exact, with all places of its scale otherwise
+func decimal(unscaled *big.Int, scale int32) string {
+	d := new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+	cents := new(big.Rat).Mul(d, big.NewRat(100, 1))
+	if scale <= 2 || cents.IsInt() {
+		return d.FloatString(2)
+	}
+	return d.FloatString(int(scale))
+}
+
+func (p *parquetReader) Pos() int {
+	return p.row
+}
+
+func (p *parquetReader) Close() error {
+	return p.f.Close()
+}
@@ -0,0 +1,151 @@
+package feed
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/types"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type int64Prices struct {
+	UUID     string `parquet:"name=uuid, type=UTF8"`
+	Title    string `parquet:"name=title, type=UTF8"`
+	Category string `parquet:"name=category, type=UTF8"`
+	Price    int64  `parquet:"name=price, type=DECIMAL, scale=2, precision=18, basetype=INT64"`
+}
+
+type binaryPrices struct {
+	UUID     string `parquet:"name=uuid, type=UTF8"`
+	Title    string `parquet:"name=title, type=UTF8"`
+	Category string `parquet:"name=category, type=UTF8"`
+	Price    string `parquet:"name=price, type=DECIMAL, scale=3, precision=10, basetype=FIXED_LEN_BYTE_ARRAY, length=5"`
+}
+
+type integerPrices struct {
+	UUID     string `parquet:"name=uuid, type=UTF8"`
+	Title    string `parquet:"name=title, type=UTF8"`
+	Category string `parquet:"name=category, type=UTF8"`
+	Price    int32  `parquet:"name=price, type=INT32"`
+}
+
+// writeParquet writes the rows into a parquet file with the schema of their struct
+func writeParquet(t *testing.T, obj interface{}, rows ...interface{}) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "products.parquet")
+	f, err := local.NewLocalFileWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := writer.NewParquetWriter(f, obj, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		err = w.Write(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.WriteStop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadParquet loads a parquet file and returns the prices of the loaded products and the rejected rows
+func loadParquet(t *testing.T, path string) (map[string]int64, []int) {
+	t.Helper()
+	r, err := Open("parquet", path, DefaultLayout(), "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	rejected := []int{}
+	products, _, err := Load(r, func(e *RecordError) error {
+		rejected = append(rejected, e.Pos)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prices := make(map[string]int64)
+	for _, p := range products {
+		prices[p.Title] = p.Price.Units
+	}
+	return prices, rejected
+}
+
+func TestParquetDecimalPrices(t *testing.T) {
+	path := writeParquet(t, new(int64Prices),
+		int64Prices{UUID: "4c61efbc-4f73-43f6-ba88-cab234b10f63", Title: "Lamp", Category: "Home/Light", Price: 1999},
+		int64Prices{UUID: "7d3a8c1e-1f0e-4b8e-9a63-2d1c2f3e4b5a", Title: "Table", Category: "Home", Price: 12000},
+	)
+	prices, rejected := loadParquet(t, path)
+	if len(rejected) != 0 || len(prices) != 2 || prices["Lamp"] != 1999 || prices["Table"] != 12000 {
+		t.Fatalf("expected the lamp at 1999 and the table at 12000 cents, got %v and the rejected rows %v", prices, rejected)
+	}
+
+	// binary decimals of a finer scale load if they are whole cents
+	path = writeParquet(t, new(binaryPrices),
+		binaryPrices{UUID: "4c61efbc-4f73-43f6-ba88-cab234b10f63", Title: "Lamp", Category: "Home/Light", Price: types.StrIntToBinary("19990", "BigEndian", 5, true)},
+		binaryPrices{UUID: "7d3a8c1e-1f0e-4b8e-9a63-2d1c2f3e4b5a", Title: "Table", Category: "Home", Price: types.StrIntToBinary("12005", "BigEndian", 5, true)},
+	)
+	prices, rejected = loadParquet(t, path)
+	if len(prices) != 1 || prices["Lamp"] != 1999 || len(rejected) != 1 || rejected[0] != 2 {
+		t.Fatalf("expected the lamp at 1999 cents and the table at 12.005 to be rejected, got %v and the rejected rows %v", prices, rejected)
+	}
+}
+
+func TestParquetIntegerPrices(t *testing.T) {
+	path := writeParquet(t, new(integerPrices),
+		integerPrices{UUID: "4c61efbc-4f73-43f6-ba88-cab234b10f63", Title: "Lamp", Category: "Home/Light", Price: 1999},
+	)
+	// an integer without scale is ambiguous, it is no price in cents nor in euro
+	prices, rejected := loadParquet(t, path)
+	if len(prices) != 0 || len(rejected) != 1 {
+		t.Fatalf("expected the integer price to be rejected, got %v", prices)
+	}
+}
+
+func TestParquetValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		scale int32
+		text  string
+	}{
+		{nil, -1, ""},
+		{"Lamp", -1, "Lamp"},
+		{int32(1999), -1, "1999"},
+		{int64(1999), -1, "1999"},
+		{int32(1999), 2, "19.99"},
+		{int64(5), 0, "5.00"},
+		{int64(-1999), 2, "-19.99"},
+		{int64(19995), 3, "19.995"},
+		{"\x07\xcf", 2, "19.99"},
+		{"\xf8\x31", 2, "-19.99"},
+		{float64(19.99), -1, "19.99"},
+		{true, -1, "true"},
+	}
+	for _, tt := range tests {
+		text := parquetValue(tt.value, tt.scale)
+		if text != tt.text {
+			t.Errorf("expected %#v with scale %d as %q, got %q", tt.value, tt.scale, tt.text, text)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Package feed reads the product files of partners in the formats and column layouts csv-import accepts
+package feed
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+)
+
+// Reader yields the products of an import file.
+// Read returns io.EOF after the last product and a *RecordError for an
+// invalid record, reading can continue after a *RecordError.
+type Reader interface {
+	Read() (*pb.Product, error)
+	// Pos returns the position of the last record read, like RecordError.Pos
+	Pos() int
+	Close() error
+}
+
+// formats lists the constructors of all supported import file formats
+var formats = map[string]func(path string, l *Layout) (Reader, error){
+	"csv":     newCSVReader,
+	"jsonl":   newJSONLReader,
+	"xml":     newXMLReader,
+	"parquet": newParquetReader,
+}
+
+// Formats lists the names of the supported formats
+func Formats() []string {
+	names := []string{}
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open reads a file of the format, prices without currency get the given one
+func Open(format, path string, l *Layout, currency string) (Reader, error) {
+	open, ok := formats[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %s, use one of %s", format, strings.Join(Formats(), ", "))
+	}
+	r, err := open(path, l)
+	if err != nil {
+		return nil, err
+	}
+	return &currencyReader{Reader: r, currency: currency}, nil
+}
+
+// currencyReader fills in the currency of prices without one
+type currencyReader struct {
+	Reader
+	currency string
+}
+
+func (c *currencyReader) Read() (*pb.Product, error) {
+	p, err := c.Reader.Read()
+	if err == nil && p != nil && p.Price.Currency == "" {
+		p.Price.Currency = c.currency
+	}
+	return p, err
+}
+
+// RecordError describes an invalid record of an import file
+type RecordError struct {
+	// Pos is the line of the record, or its number for formats without lines
+	Pos     int
+	Record  []string
+	Reasons []string
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("record %d: %s", e.Pos, strings.Join(e.Reasons, "; "))
+}
+
+// Load reads all products of a file by uuid. Invalid records and repeated uuids are passed to reject
+// and skipped, the first product of a uuid is kept. It returns the number of records read.
+func Load(r Reader, reject func(e *RecordError) error) (map[string]*pb.Product, int, error) {
+	m := make(map[string]*pb.Product)
+	total := 0
+	for {
+		p, err := r.Read()
+		if err == io.EOF {
+			return m, total, nil
+		}
+		total++
+
+		if err == nil {
+			if _, ok := m[p.Uuid]; ok {
+				err = &RecordError{Pos: r.Pos(), Record: []string{p.Uuid}, Reasons: []string{fmt.Sprintf("duplicate uuid %s", p.Uuid)}}
+			}
+		}
+
+		if err != nil {
+			rerr, ok := err.(*RecordError)
+			if !ok {
+				return nil, total, err
+			}
+			err := reject(rerr)
+			if err != nil {
+				return nil, total, err
+			}
+			continue
+		}
+
+		m[p.Uuid] = p
+	}
+}
+
+// parse validates a row in schema order and converts it into a product
+func parse(pos int, record, row []string) (*pb.Product, error) {
+	reasons := validate(row)
+	if len(reasons) != 0 {
+		return nil, &RecordError{Pos: pos, Record: record, Reasons: reasons}
+	}
+	p, err := row2product(row)
+	if err != nil {
+		return nil, &RecordError{Pos: pos, Record: record, Reasons: []string{err.Error()}}
+	}
+	return p, nil
+}
+
+func row2product(row []string) (*pb.Product, error) {
+
+	if row == nil {
+		return nil, nil
+	}
+
+	units, err := minorUnits(row[7])
+	if err != nil {
+		return &pb.Product{}, err
+	}
+	return &pb.Product{
+		Uuid:          row[0],
+		Title:         row[1],
+		Description:   row[2],
+		Longtext:      row[3],
+		Category:      row[4],
+		SmallImageURL: row[5],
+		LargeImageURL: row[6],
+		Price: &pb.Money{
+			Units:    units,
+			Currency: row[8],
+		},
+	}, nil
+}
+
+// minorUnits converts a decimal with two places like 99.99 into 9999 without rounding errors
+func minorUnits(price string) (int64, error) {
+	parts := strings.Split(price, ".")
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("invalid price %q", price)
+	}
+	units, err := strconv.ParseInt(parts[0]+parts[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid price %q: %s", price, err)
+	}
+	return units, nil
+}
@@ -0,0 +1,65 @@
+package feed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSkipsRejectedRecords(t *testing.T) {
+	dir, err := ioutil.TempDir("", "feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "products.csv")
+	rows := "uuid,title,description,longtext,category,smallImageURL,largeImageURL,price,currency\n" +
+		"4c61efbc-4f73-43f6-ba88-cab234b10f63,Lamp,,,Home/Light,,,19.99,\n" +
+		"not-a-uuid,Chair,,,Home,,,5.00,\n" +
+		"4c61efbc-4f73-43f6-ba88-cab234b10f63,Lamp again,,,Home/Light,,,29.99,\n" +
+		"7d3a8c1e-1f0e-4b8e-9a63-2d1c2f3e4b5a,Table,,,Home,,,12,\n" +
+		"0e0b1c9a-3f5d-4a7e-8c2b-1d9e8f7a6b5c,Desk,,,Office,,,99.00,USD\n"
+	err = ioutil.WriteFile(path, []byte(rows), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Open("csv", path, &Layout{Header: true}, "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	rejected := []string{}
+	lines := []int{}
+	products, total, err := Load(r, func(e *RecordError) error {
+		rejected = append(rejected, e.Reasons...)
+		lines = append(lines, e.Pos)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total != 5 || len(products) != 2 {
+		t.Fatalf("expected 2 of 5 records to load, got %d of %d", len(products), total)
+	}
+	if len(rejected) != 3 {
+		t.Fatalf("expected the invalid uuid, the repeated uuid and the invalid price to be rejected, got %v", rejected)
+	}
+	if fmt.Sprint(lines) != "[3 4 5]" {
+		t.Fatalf("expected the rejects at the lines 3, 4 and 5, got %v", lines)
+	}
+
+	lamp := products["4c61efbc-4f73-43f6-ba88-cab234b10f63"]
+	if lamp.Title != "Lamp" || lamp.Price.Units != 1999 || lamp.Price.Currency != "EUR" {
+		t.Fatalf("expected the first lamp in the default currency, got %v", lamp)
+	}
+	desk := products["0e0b1c9a-3f5d-4a7e-8c2b-1d9e8f7a6b5c"]
+	if desk.Price.Currency != "USD" {
+		t.Fatalf("expected the currency of the record, got %v", desk)
+	}
+}
@@ -0,0 +1,96 @@
+package feed
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/satori/go.uuid"
+)
+
+var (
+	pricePattern    = regexp.MustCompile(`^[0-9]+\.[0-9]{2}$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	categoryPattern = regexp.MustCompile(`^[^/\s]([^/]*[^/\s])?(/[^/\s]([^/]*[^/\s])?)*$`)
+)
+
+// column declares name and constraints of one csv column
+type column struct {
+	name     string
+	required bool
+	check    func(value string) error
+}
+
+// schema lists the columns of an import file in order
+var schema = []column{
+	{name: "uuid", required: true, check: isUUID},
+	{name: "title", required: true},
+	{name: "description"},
+	{name: "longtext"},
+	{name: "category", required: true, check: isCategory},
+	{name: "smallImageURL", check: isURL},
+	{name: "largeImageURL", check: isURL},
+	{name: "price", required: true, check: isPrice},
+	{name: "currency", check: isCurrency},
+}
+
+// positionalColumns is the number of columns of files without header and mapping
+const positionalColumns = 8
+
+// validate returns the reasons why a row in schema order does not match the schema
+func validate(row []string) []string {
+	reasons := []string{}
+	for i, c := range schema {
+		value := row[i]
+		if value == "" {
+			if c.required {
+				reasons = append(reasons, fmt.Sprintf("%s is required", c.name))
+			}
+			continue
+		}
+		if c.check == nil {
+			continue
+		}
+		if err := c.check(value); err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", c.name, err))
+		}
+	}
+	return reasons
+}
+
+func isUUID(value string) error {
+	_, err := uuid.FromString(value)
+	if err != nil {
+		return fmt.Errorf("invalid uuid %q", value)
+	}
+	return nil
+}
+
+func isPrice(value string) error {
+	if !pricePattern.MatchString(value) {
+		return fmt.Errorf("invalid price %q, expected a decimal with two places", value)
+	}
+	return nil
+}
+
+func isCurrency(value string) error {
+	if !currencyPattern.MatchString(value) {
+		return fmt.Errorf("invalid currency %q, expected an ISO 4217 code", value)
+	}
+	return nil
+}
+
+func isURL(value string) error {
+	u, err := url.ParseRequestURI(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url %q", value)
+	}
+	return nil
+}
+
+func isCategory(value string) error {
+	if !categoryPattern.MatchString(value) {
+		return fmt.Errorf("invalid category %q", value)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+)
+
+// feedItem is a product of a google shopping style rss or atom feed
+type feedItem struct {
+	ID                   string   `xml:"id"`
+	Title                string   `xml:"title"`
+	Description          string   `xml:"description"`
+	ProductType          string   `xml:"product_type"`
+	ImageLink            string   `xml:"image_link"`
+	AdditionalImageLinks []string `xml:"additional_image_link"`
+	Price                string   `xml:"price"`
+}
+
+// xmlReader reads the item (rss) or entry (atom) elements of a product feed.
+// A lenient decoder walks the feed, every item is decoded strictly on its own,
+// like a line of jsonl, a malformed item is rejected and reading continues with the next one.
+type xmlReader struct {
+	f *os.File
+	d *xml.Decoder
+	// raw holds the input from offset base on, the items are decoded from it
+	raw  bytes.Buffer
+	base int64
+	item int
+}
+
+func newXMLReader(path string, l *Layout) (Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open import file: %s", err)
+	}
+
+	decode, err := decoder(l.Encoding)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	x := &xmlReader{f: f}
+	x.d = xml.NewDecoder(io.TeeReader(decode(f), &x.raw))
+	x.d.Strict = false
+	// the input is converted by the encoding of the layout already
+	x.d.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return x, nil
+}
+
+func (x *xmlReader) Read() (*pb.Product, error) {
+	for {
+		x.discard(x.d.InputOffset())
+		token, err := x.d.Token()
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read import file: %s", err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || (start.Name.Local != "item" && start.Name.Local != "entry") {
+			continue
+		}
+		x.item++
+
+		from := x.base
+		err = x.d.Skip()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read item %d: %s", x.item, err)
+		}
+		raw := x.raw.Bytes()[:x.d.InputOffset()-from]
+
+		item := feedItem{}
+		err = xml.NewDecoder(bytes.NewReader(raw)).Decode(&item)
+		if err != nil {
+			return nil, &RecordError{Pos: x.item, Record: []string{string(raw)}, Reasons: []string{err.Error()}}
+		}
+
+		row := item.row()
+		return parse(x.item, row, row)
+	}
+}
+
+// discard drops the buffered input before offset
+func (x *xmlReader) discard(offset int64) {
+	x.raw.Next(int(offset - x.base))
+	x.base = offset
+}
+
+// row converts the item into schema order
+func (i feedItem) row() []string {
+	largeImageURL := ""
+	if len(i.AdditionalImageLinks) > 0 {
+		largeImageURL = strings.TrimSpace(i.AdditionalImageLinks[0])
+	}
+
+	return []string{
+		strings.TrimSpace(i.ID),
+		strings.TrimSpace(i.Title),
+		strings.TrimSpace(i.Description),
+		"",
+		category(i.ProductType),
+		strings.TrimSpace(i.ImageLink),
+		largeImageURL,
+		amount(i.Price),
+		currency(i.Price),
+	}
+}
+
+// category turns a product type like "Home & Garden > Kitchen" into "Home & Garden/Kitchen"
+func category(productType string) string {
+	parts := strings.Split(productType, ">")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
+
+// amount strips the currency of a price like "15.00 USD"
+func amount(price string) string {
+	fields := strings.Fields(price)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// currency returns the currency of a price like "15.00 USD"
+func currency(price string) string {
+	fields := strings.Fields(price)
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+func (x *xmlReader) Pos() int {
+	return x.item
+}
+
+func (x *xmlReader) Close() error {
+	return x.f.Close()
+}
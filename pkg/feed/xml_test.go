@@ -0,0 +1,142 @@
+package feed
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const rss = `<?xml version="1.0" encoding="UTF-8"?>
+<!-- product feed -->
+<rss version="2.0" xmlns:g="http://base.google.com/ns/1.0">
+<channel>
+<title>Shop</title>
+<item>
+  <g:id>4c61efbc-4f73-43f6-ba88-cab234b10f63</g:id>
+  <title>Lamp &amp; shade</title>
+  <g:product_type>Home &gt; Light</g:product_type>
+  <g:price>19.99 EUR</g:price>
+</item>
+<item>
+  <g:id>7d3a8c1e-1f0e-4b8e-9a63-2d1c2f3e4b5a</g:id>
+  <title>Table &euro;</title>
+  <g:product_type>Home</g:product_type>
+  <g:price>12.00 EUR</g:price>
+</item>
+<item type="product">
+  <g:id>0e0b1c9a-3f5d-4a7e-8c2b-1d9e8f7a6b5c</g:id>
+  <title>Desk</title>
+  <description>width > depth</description>
+  <g:product_type>Office</g:product_type>
+  <g:price>99.00 USD</g:price>
+</item>
+</channel>
+</rss>
+`
+
+func openXML(t *testing.T, feed string) Reader {
+	dir, err := ioutil.TempDir("", "feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "products.xml")
+	err = ioutil.WriteFile(path, []byte(feed), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Open("xml", path, DefaultLayout(), "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func TestXMLRejectsMalformedItems(t *testing.T) {
+	rejected := []*RecordError{}
+	products, total, err := Load(openXML(t, rss), func(e *RecordError) error {
+		rejected = append(rejected, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if total != 3 || len(products) != 2 {
+		t.Fatalf("expected 2 of 3 items to load, got %d of %d", len(products), total)
+	}
+	if len(rejected) != 1 || rejected[0].Pos != 2 || !strings.Contains(rejected[0].Record[0], "Table") {
+		t.Fatalf("expected the item with the unknown entity to be rejected, got %v", rejected)
+	}
+
+	lamp := products["4c61efbc-4f73-43f6-ba88-cab234b10f63"]
+	if lamp.Title != "Lamp & shade" || lamp.Category != "Home/Light" || lamp.Price.Units != 1999 {
+		t.Fatalf("unexpected lamp %v", lamp)
+	}
+	desk := products["0e0b1c9a-3f5d-4a7e-8c2b-1d9e8f7a6b5c"]
+	if desk.Description != "width > depth" || desk.Price.Currency != "USD" {
+		t.Fatalf("unexpected desk %v", desk)
+	}
+}
+
+func TestXMLTruncatedFeed(t *testing.T) {
+	tests := []struct {
+		name string
+		feed string
+	}{
+		{"between items", rss[:strings.Index(rss, "<item type")]},
+		{"within an item", rss[:strings.Index(rss, "<description>")]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Load(openXML(t, tt.feed), func(e *RecordError) error {
+				return nil
+			})
+			if err == nil {
+				t.Fatal("expected a truncated feed to fail, the missing items would be deleted")
+			}
+		})
+	}
+}
+
+func TestXMLMarkupInsideItems(t *testing.T) {
+	feed := `<?xml version="1.0" encoding="UTF-8"?>
+<?xml-stylesheet href="feed.xsl" type="text/xsl"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:g="http://base.google.com/ns/1.0">
+<!-- a comment with <entry> and > inside -->
+<entry>
+  <g:id>4c61efbc-4f73-43f6-ba88-cab234b10f63</g:id>
+  <?generator version > 2?>
+  <title><![CDATA[Lamp </entry> <b>bold</b>]]></title>
+  <!-- </entry> -->
+  <g:product_type>Home &gt; Light</g:product_type>
+  <g:price>19.99 EUR</g:price>
+</entry>
+<entry>
+  <g:id>7d3a8c1e-1f0e-4b8e-9a63-2d1c2f3e4b5a</g:id>
+  <title>Table</title>
+  <g:product_type>Home</g:product_type>
+  <g:price>12.00 EUR</g:price>
+</entry>
+</feed>
+`
+	products, total, err := Load(openXML(t, feed), func(e *RecordError) error {
+		t.Errorf("unexpected reject of entry %d: %v", e.Pos, e.Reasons)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || len(products) != 2 {
+		t.Fatalf("expected 2 entries, got %d of %d", len(products), total)
+	}
+	lamp := products["4c61efbc-4f73-43f6-ba88-cab234b10f63"]
+	if lamp.Title != "Lamp </entry> <b>bold</b>" || lamp.Category != "Home/Light" {
+		t.Fatalf("unexpected lamp %v", lamp)
+	}
+}
@@ -0,0 +1,4 @@
+package pb
+
+// ImportRunHeader is the kafka record header carrying the import run id of a ProductUpdate
+const ImportRunHeader = "importRun"
@@ -0,0 +1,360 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: pkg/pb/imports.proto
+
+package pb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type ImportStarted struct {
+	RunID                string   `protobuf:"bytes,1,opt,name=runID,proto3" json:"runID,omitempty"`
+	CurrentPath          string   `protobuf:"bytes,2,opt,name=currentPath,proto3" json:"currentPath,omitempty"`
+	CurrentChecksum      string   `protobuf:"bytes,3,opt,name=currentChecksum,proto3" json:"currentChecksum,omitempty"`
+	PreviousPath         string   `protobuf:"bytes,4,opt,name=previousPath,proto3" json:"previousPath,omitempty"`
+	PreviousChecksum     string   `protobuf:"bytes,5,opt,name=previousChecksum,proto3" json:"previousChecksum,omitempty"`
+	StartedAt            int64    `protobuf:"varint,6,opt,name=startedAt,proto3" json:"startedAt,omitempty"`
+	Tenant               string   `protobuf:"bytes,7,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ImportStarted) Reset()         { *m = ImportStarted{} }
+func (m *ImportStarted) String() string { return proto.CompactTextString(m) }
+func (*ImportStarted) ProtoMessage()    {}
+func (*ImportStarted) Descriptor() ([]byte, []int) {
+	return fileDescriptor_imports_44915244a6c055cb, []int{0}
+}
+func (m *ImportStarted) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportStarted.Unmarshal(m, b)
+}
+func (m *ImportStarted) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportStarted.Marshal(b, m, deterministic)
+}
+func (dst *ImportStarted) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportStarted.Merge(dst, src)
+}
+func (m *ImportStarted) XXX_Size() int {
+	return xxx_messageInfo_ImportStarted.Size(m)
+}
+func (m *ImportStarted) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportStarted.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportStarted proto.InternalMessageInfo
+
+func (m *ImportStarted) GetRunID() string {
+	if m != nil {
+		return m.RunID
+	}
+	return ""
+}
+
+func (m *ImportStarted) GetCurrentPath() string {
+	if m != nil {
+		return m.CurrentPath
+	}
+	return ""
+}
+
+func (m *ImportStarted) GetCurrentChecksum() string {
+	if m != nil {
+		return m.CurrentChecksum
+	}
+	return ""
+}
+
+func (m *ImportStarted) GetPreviousPath() string {
+	if m != nil {
+		return m.PreviousPath
+	}
+	return ""
+}
+
+func (m *ImportStarted) GetPreviousChecksum() string {
+	if m != nil {
+		return m.PreviousChecksum
+	}
+	return ""
+}
+
+func (m *ImportStarted) GetStartedAt() int64 {
+	if m != nil {
+		return m.StartedAt
+	}
+	return 0
+}
+
+func (m *ImportStarted) GetTenant() string {
+	if m != nil {
+		return m.Tenant
+	}
+	return ""
+}
+
+type ImportCompleted struct {
+	RunID                string   `protobuf:"bytes,1,opt,name=runID,proto3" json:"runID,omitempty"`
+	Inserts              int64    `protobuf:"varint,2,opt,name=inserts,proto3" json:"inserts,omitempty"`
+	Updates              int64    `protobuf:"varint,3,opt,name=updates,proto3" json:"updates,omitempty"`
+	Deletes              int64    `protobuf:"varint,4,opt,name=deletes,proto3" json:"deletes,omitempty"`
+	CompletedAt          int64    `protobuf:"varint,5,opt,name=completedAt,proto3" json:"completedAt,omitempty"`
+	Tenant               string   `protobuf:"bytes,6,opt,name=tenant,proto3" json:"tenant,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ImportCompleted) Reset()         { *m = ImportCompleted{} }
+func (m *ImportCompleted) String() string { return proto.CompactTextString(m) }
+func (*ImportCompleted) ProtoMessage()    {}
+func (*ImportCompleted) Descriptor() ([]byte, []int) {
+	return fileDescriptor_imports_44915244a6c055cb, []int{1}
+}
+func (m *ImportCompleted) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportCompleted.Unmarshal(m, b)
+}
+func (m *ImportCompleted) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportCompleted.Marshal(b, m, deterministic)
+}
+func (dst *ImportCompleted) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportCompleted.Merge(dst, src)
+}
+func (m *ImportCompleted) XXX_Size() int {
+	return xxx_messageInfo_ImportCompleted.Size(m)
+}
+func (m *ImportCompleted) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportCompleted.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportCompleted proto.InternalMessageInfo
+
+func (m *ImportCompleted) GetRunID() string {
+	if m != nil {
+		return m.RunID
+	}
+	return ""
+}
+
+func (m *ImportCompleted) GetInserts() int64 {
+	if m != nil {
+		return m.Inserts
+	}
+	return 0
+}
+
+func (m *ImportCompleted) GetUpdates() int64 {
+	if m != nil {
+		return m.Updates
+	}
+	return 0
+}
+
+func (m *ImportCompleted) GetDeletes() int64 {
+	if m != nil {
+		return m.Deletes
+	}
+	return 0
+}
+
+func (m *ImportCompleted) GetCompletedAt() int64 {
+	if m != nil {
+		return m.CompletedAt
+	}
+	return 0
+}
+
+func (m *ImportCompleted) GetTenant() string {
+	if m != nil {
+		return m.Tenant
+	}
+	return ""
+}
+
+type ImportEvent struct {
+	// Types that are valid to be assigned to Event:
+	//	*ImportEvent_Started
+	//	*ImportEvent_Completed
+	Event                isImportEvent_Event `protobuf_oneof:"event"`
+	XXX_NoUnkeyedLiteral struct{}            `json:"-"`
+	XXX_unrecognized     []byte              `json:"-"`
+	XXX_sizecache        int32               `json:"-"`
+}
+
+func (m *ImportEvent) Reset()         { *m = ImportEvent{} }
+func (m *ImportEvent) String() string { return proto.CompactTextString(m) }
+func (*ImportEvent) ProtoMessage()    {}
+func (*ImportEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_imports_44915244a6c055cb, []int{2}
+}
+func (m *ImportEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ImportEvent.Unmarshal(m, b)
+}
+func (m *ImportEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ImportEvent.Marshal(b, m, deterministic)
+}
+func (dst *ImportEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ImportEvent.Merge(dst, src)
+}
+func (m *ImportEvent) XXX_Size() int {
+	return xxx_messageInfo_ImportEvent.Size(m)
+}
+func (m *ImportEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_ImportEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ImportEvent proto.InternalMessageInfo
+
+type isImportEvent_Event interface {
+	isImportEvent_Event()
+}
+
+type ImportEvent_Started struct {
+	Started *ImportStarted `protobuf:"bytes,1,opt,name=started,proto3,oneof"`
+}
+
+type ImportEvent_Completed struct {
+	Completed *ImportCompleted `protobuf:"bytes,2,opt,name=completed,proto3,oneof"`
+}
+
+func (*ImportEvent_Started) isImportEvent_Event() {}
+
+func (*ImportEvent_Completed) isImportEvent_Event() {}
+
+func (m *ImportEvent) GetEvent() isImportEvent_Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+func (m *ImportEvent) GetStarted() *ImportStarted {
+	if x, ok := m.GetEvent().(*ImportEvent_Started); ok {
+		return x.Started
+	}
+	return nil
+}
+
+func (m *ImportEvent) GetCompleted() *ImportCompleted {
+	if x, ok := m.GetEvent().(*ImportEvent_Completed); ok {
+		return x.Completed
+	}
+	return nil
+}
+
+// XXX_OneofFuncs is for the internal use of the proto package.
+func (*ImportEvent) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
+	return _ImportEvent_OneofMarshaler, _ImportEvent_OneofUnmarshaler, _ImportEvent_OneofSizer, []interface{}{
+		(*ImportEvent_Started)(nil),
+		(*ImportEvent_Completed)(nil),
+	}
+}
+
+func _ImportEvent_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
+	m := msg.(*ImportEvent)
+	// event
+	switch x := m.Event.(type) {
+	case *ImportEvent_Started:
+		b.EncodeVarint(1<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Started); err != nil {
+			return err
+		}
+	case *ImportEvent_Completed:
+		b.EncodeVarint(2<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Completed); err != nil {
+			return err
+		}
+	case nil:
+	default:
+		return fmt.Errorf("ImportEvent.Event has unexpected type %T", x)
+	}
+	return nil
+}
+
+func _ImportEvent_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
+	m := msg.(*ImportEvent)
+	switch tag {
+	case 1: // event.started
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(ImportStarted)
+		err := b.DecodeMessage(msg)
+		m.Event = &ImportEvent_Started{msg}
+		return true, err
+	case 2: // event.completed
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(ImportCompleted)
+		err := b.DecodeMessage(msg)
+		m.Event = &ImportEvent_Completed{msg}
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+func _ImportEvent_OneofSizer(msg proto.Message) (n int) {
+	m := msg.(*ImportEvent)
+	// event
+	switch x := m.Event.(type) {
+	case *ImportEvent_Started:
+		s := proto.Size(x.Started)
+		n += 1 // tag and wire
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *ImportEvent_Completed:
+		s := proto.Size(x.Completed)
+		n += 1 // tag and wire
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case nil:
+	default:
+		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
+	}
+	return n
+}
+
+func init() {
+	proto.RegisterType((*ImportStarted)(nil), "pb.ImportStarted")
+	proto.RegisterType((*ImportCompleted)(nil), "pb.ImportCompleted")
+	proto.RegisterType((*ImportEvent)(nil), "pb.ImportEvent")
+}
+
+func init() { proto.RegisterFile("pkg/pb/imports.proto", fileDescriptor_imports_44915244a6c055cb) }
+
+var fileDescriptor_imports_44915244a6c055cb = []byte{
+	// 312 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x74, 0x52, 0xc1, 0x4e, 0xc2, 0x40,
+	0x10, 0xa5, 0xac, 0xb4, 0x61, 0xaa, 0x41, 0x57, 0x62, 0xf6, 0xe0, 0x81, 0x70, 0x22, 0x26, 0x42,
+	0x02, 0x5f, 0x80, 0x68, 0x02, 0x37, 0xb3, 0x7e, 0x41, 0x0b, 0x1b, 0x21, 0xc8, 0x76, 0xb3, 0x3b,
+	0xe5, 0xe0, 0x4f, 0xf9, 0x79, 0x5e, 0x4d, 0xa7, 0x2c, 0x6d, 0x35, 0x1e, 0xdf, 0x9b, 0x37, 0x33,
+	0xef, 0xcd, 0x2e, 0xf4, 0xcd, 0xfe, 0x7d, 0x62, 0xd2, 0xc9, 0xee, 0x60, 0x32, 0x8b, 0x6e, 0x6c,
+	0x6c, 0x86, 0x19, 0x6f, 0x9b, 0x74, 0xf8, 0x1d, 0xc0, 0xd5, 0x8a, 0xd8, 0x37, 0x4c, 0x2c, 0xaa,
+	0x0d, 0xef, 0x43, 0xc7, 0xe6, 0x7a, 0xf5, 0x2c, 0x82, 0x41, 0x30, 0xea, 0xca, 0x12, 0xf0, 0x01,
+	0xc4, 0xeb, 0xdc, 0x5a, 0xa5, 0xf1, 0x35, 0xc1, 0xad, 0x68, 0x53, 0xad, 0x4e, 0xf1, 0x11, 0xf4,
+	0x4e, 0x70, 0xb1, 0x55, 0xeb, 0xbd, 0xcb, 0x0f, 0x82, 0x91, 0xea, 0x37, 0xcd, 0x87, 0x70, 0x69,
+	0xac, 0x3a, 0xee, 0xb2, 0xdc, 0xd1, 0xb0, 0x0b, 0x92, 0x35, 0x38, 0xfe, 0x00, 0xd7, 0x1e, 0x9f,
+	0xc7, 0x75, 0x48, 0xf7, 0x87, 0xe7, 0xf7, 0xd0, 0x75, 0xa5, 0xf9, 0x39, 0x8a, 0x70, 0x10, 0x8c,
+	0x98, 0xac, 0x08, 0x7e, 0x07, 0x21, 0x2a, 0x9d, 0x68, 0x14, 0x11, 0xf5, 0x9f, 0xd0, 0xf0, 0x2b,
+	0x80, 0x5e, 0x99, 0x7c, 0x91, 0x1d, 0xcc, 0x87, 0xfa, 0x3f, 0xbb, 0x80, 0x68, 0xa7, 0x9d, 0xb2,
+	0xe8, 0x28, 0x37, 0x93, 0x1e, 0x16, 0x95, 0xdc, 0x6c, 0x12, 0x54, 0x8e, 0xb2, 0x32, 0xe9, 0x61,
+	0x51, 0xd9, 0xa8, 0x62, 0xa8, 0xa3, 0x78, 0x4c, 0x7a, 0x48, 0x97, 0xf4, 0x0b, 0xe7, 0x48, 0xa1,
+	0x98, 0xac, 0x53, 0x35, 0xc7, 0x61, 0xc3, 0xf1, 0x27, 0xc4, 0xa5, 0xe1, 0x97, 0xa3, 0xd2, 0xc8,
+	0x1f, 0x21, 0x3a, 0xa5, 0x24, 0xbb, 0xf1, 0xf4, 0x66, 0x6c, 0xd2, 0x71, 0xe3, 0x31, 0x97, 0x2d,
+	0xe9, 0x35, 0x7c, 0x06, 0xdd, 0xf3, 0x12, 0xca, 0x11, 0x4f, 0x6f, 0xab, 0x86, 0xf3, 0x0d, 0x96,
+	0x2d, 0x59, 0xe9, 0x9e, 0x22, 0xe8, 0xa8, 0x62, 0x59, 0x1a, 0xd2, 0x97, 0x99, 0xfd, 0x0c, 0x00,
+	0x08, 0xbc, 0xb7, 0x59, 0x4a, 0x02, 0x00, 0x00,
+}
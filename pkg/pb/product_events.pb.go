@@ -0,0 +1,316 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: pkg/pb/product_events.proto
+
+package pb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type ProductCreated struct {
+	Product              *Product `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProductCreated) Reset()         { *m = ProductCreated{} }
+func (m *ProductCreated) String() string { return proto.CompactTextString(m) }
+func (*ProductCreated) ProtoMessage()    {}
+func (*ProductCreated) Descriptor() ([]byte, []int) {
+	return fileDescriptor_product_events_036b5cd4c7bd4946, []int{0}
+}
+func (m *ProductCreated) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProductCreated.Unmarshal(m, b)
+}
+func (m *ProductCreated) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProductCreated.Marshal(b, m, deterministic)
+}
+func (dst *ProductCreated) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProductCreated.Merge(dst, src)
+}
+func (m *ProductCreated) XXX_Size() int {
+	return xxx_messageInfo_ProductCreated.Size(m)
+}
+func (m *ProductCreated) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProductCreated.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProductCreated proto.InternalMessageInfo
+
+func (m *ProductCreated) GetProduct() *Product {
+	if m != nil {
+		return m.Product
+	}
+	return nil
+}
+
+type ProductDetailsChanged struct {
+	Title                string   `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Description          string   `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	Longtext             string   `protobuf:"bytes,3,opt,name=longtext,proto3" json:"longtext,omitempty"`
+	SmallImageURL        string   `protobuf:"bytes,4,opt,name=smallImageURL,proto3" json:"smallImageURL,omitempty"`
+	LargeImageURL        string   `protobuf:"bytes,5,opt,name=largeImageURL,proto3" json:"largeImageURL,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProductDetailsChanged) Reset()         { *m = ProductDetailsChanged{} }
+func (m *ProductDetailsChanged) String() string { return proto.CompactTextString(m) }
+func (*ProductDetailsChanged) ProtoMessage()    {}
+func (*ProductDetailsChanged) Descriptor() ([]byte, []int) {
+	return fileDescriptor_product_events_036b5cd4c7bd4946, []int{1}
+}
+func (m *ProductDetailsChanged) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProductDetailsChanged.Unmarshal(m, b)
+}
+func (m *ProductDetailsChanged) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProductDetailsChanged.Marshal(b, m, deterministic)
+}
+func (dst *ProductDetailsChanged) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProductDetailsChanged.Merge(dst, src)
+}
+func (m *ProductDetailsChanged) XXX_Size() int {
+	return xxx_messageInfo_ProductDetailsChanged.Size(m)
+}
+func (m *ProductDetailsChanged) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProductDetailsChanged.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProductDetailsChanged proto.InternalMessageInfo
+
+func (m *ProductDetailsChanged) GetTitle() string {
+	if m != nil {
+		return m.Title
+	}
+	return ""
+}
+
+func (m *ProductDetailsChanged) GetDescription() string {
+	if m != nil {
+		return m.Description
+	}
+	return ""
+}
+
+func (m *ProductDetailsChanged) GetLongtext() string {
+	if m != nil {
+		return m.Longtext
+	}
+	return ""
+}
+
+func (m *ProductDetailsChanged) GetSmallImageURL() string {
+	if m != nil {
+		return m.SmallImageURL
+	}
+	return ""
+}
+
+func (m *ProductDetailsChanged) GetLargeImageURL() string {
+	if m != nil {
+		return m.LargeImageURL
+	}
+	return ""
+}
+
+type ProductRepriced struct {
+	Price                *Money   `protobuf:"bytes,1,opt,name=price,proto3" json:"price,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProductRepriced) Reset()         { *m = ProductRepriced{} }
+func (m *ProductRepriced) String() string { return proto.CompactTextString(m) }
+func (*ProductRepriced) ProtoMessage()    {}
+func (*ProductRepriced) Descriptor() ([]byte, []int) {
+	return fileDescriptor_product_events_036b5cd4c7bd4946, []int{2}
+}
+func (m *ProductRepriced) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProductRepriced.Unmarshal(m, b)
+}
+func (m *ProductRepriced) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProductRepriced.Marshal(b, m, deterministic)
+}
+func (dst *ProductRepriced) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProductRepriced.Merge(dst, src)
+}
+func (m *ProductRepriced) XXX_Size() int {
+	return xxx_messageInfo_ProductRepriced.Size(m)
+}
+func (m *ProductRepriced) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProductRepriced.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProductRepriced proto.InternalMessageInfo
+
+func (m *ProductRepriced) GetPrice() *Money {
+	if m != nil {
+		return m.Price
+	}
+	return nil
+}
+
+type ProductRecategorized struct {
+	Category             string   `protobuf:"bytes,1,opt,name=category,proto3" json:"category,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProductRecategorized) Reset()         { *m = ProductRecategorized{} }
+func (m *ProductRecategorized) String() string { return proto.CompactTextString(m) }
+func (*ProductRecategorized) ProtoMessage()    {}
+func (*ProductRecategorized) Descriptor() ([]byte, []int) {
+	return fileDescriptor_product_events_036b5cd4c7bd4946, []int{3}
+}
+func (m *ProductRecategorized) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProductRecategorized.Unmarshal(m, b)
+}
+func (m *ProductRecategorized) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProductRecategorized.Marshal(b, m, deterministic)
+}
+func (dst *ProductRecategorized) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProductRecategorized.Merge(dst, src)
+}
+func (m *ProductRecategorized) XXX_Size() int {
+	return xxx_messageInfo_ProductRecategorized.Size(m)
+}
+func (m *ProductRecategorized) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProductRecategorized.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProductRecategorized proto.InternalMessageInfo
+
+func (m *ProductRecategorized) GetCategory() string {
+	if m != nil {
+		return m.Category
+	}
+	return ""
+}
+
+type ProductDeleted struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProductDeleted) Reset()         { *m = ProductDeleted{} }
+func (m *ProductDeleted) String() string { return proto.CompactTextString(m) }
+func (*ProductDeleted) ProtoMessage()    {}
+func (*ProductDeleted) Descriptor() ([]byte, []int) {
+	return fileDescriptor_product_events_036b5cd4c7bd4946, []int{4}
+}
+func (m *ProductDeleted) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProductDeleted.Unmarshal(m, b)
+}
+func (m *ProductDeleted) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProductDeleted.Marshal(b, m, deterministic)
+}
+func (dst *ProductDeleted) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProductDeleted.Merge(dst, src)
+}
+func (m *ProductDeleted) XXX_Size() int {
+	return xxx_messageInfo_ProductDeleted.Size(m)
+}
+func (m *ProductDeleted) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProductDeleted.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProductDeleted proto.InternalMessageInfo
+
+// ProductState is the snapshot of a product aggregate
+type ProductState struct {
+	Product              *Product `protobuf:"bytes,1,opt,name=product,proto3" json:"product,omitempty"`
+	Deleted              bool     `protobuf:"varint,2,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ProductState) Reset()         { *m = ProductState{} }
+func (m *ProductState) String() string { return proto.CompactTextString(m) }
+func (*ProductState) ProtoMessage()    {}
+func (*ProductState) Descriptor() ([]byte, []int) {
+	return fileDescriptor_product_events_036b5cd4c7bd4946, []int{5}
+}
+func (m *ProductState) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProductState.Unmarshal(m, b)
+}
+func (m *ProductState) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProductState.Marshal(b, m, deterministic)
+}
+func (dst *ProductState) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProductState.Merge(dst, src)
+}
+func (m *ProductState) XXX_Size() int {
+	return xxx_messageInfo_ProductState.Size(m)
+}
+func (m *ProductState) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProductState.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProductState proto.InternalMessageInfo
+
+func (m *ProductState) GetProduct() *Product {
+	if m != nil {
+		return m.Product
+	}
+	return nil
+}
+
+func (m *ProductState) GetDeleted() bool {
+	if m != nil {
+		return m.Deleted
+	}
+	return false
+}
+
+func init() {
+	proto.RegisterType((*ProductCreated)(nil), "pb.ProductCreated")
+	proto.RegisterType((*ProductDetailsChanged)(nil), "pb.ProductDetailsChanged")
+	proto.RegisterType((*ProductRepriced)(nil), "pb.ProductRepriced")
+	proto.RegisterType((*ProductRecategorized)(nil), "pb.ProductRecategorized")
+	proto.RegisterType((*ProductDeleted)(nil), "pb.ProductDeleted")
+	proto.RegisterType((*ProductState)(nil), "pb.ProductState")
+}
+
+func init() {
+	proto.RegisterFile("pkg/pb/product_events.proto", fileDescriptor_product_events_036b5cd4c7bd4946)
+}
+
+var fileDescriptor_product_events_036b5cd4c7bd4946 = []byte{
+	// 293 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x91, 0xcf, 0x4a, 0x33, 0x31,
+	0x14, 0xc5, 0x69, 0xbf, 0xaf, 0xb6, 0xbd, 0xf5, 0x1f, 0xa1, 0x85, 0xa1, 0x2e, 0x2c, 0x41, 0xc1,
+	0x55, 0x0b, 0x75, 0xe1, 0x03, 0xb4, 0x1b, 0x41, 0x51, 0x46, 0x5c, 0x4b, 0x66, 0x72, 0x89, 0xc1,
+	0x74, 0x12, 0x32, 0x57, 0xb1, 0xbe, 0x98, 0xaf, 0x27, 0x93, 0xc9, 0x0c, 0xce, 0xce, 0x5d, 0xce,
+	0x39, 0xbf, 0x1b, 0x72, 0x6e, 0xe0, 0xcc, 0xbd, 0xa9, 0x95, 0xcb, 0x56, 0xce, 0x5b, 0xf9, 0x9e,
+	0xd3, 0x0b, 0x7e, 0x60, 0x41, 0xe5, 0xd2, 0x79, 0x4b, 0x96, 0xf5, 0x5d, 0x36, 0x9f, 0x75, 0x81,
+	0x18, 0xf1, 0x1b, 0x38, 0x7e, 0xac, 0x9d, 0x8d, 0x47, 0x41, 0x28, 0xd9, 0x25, 0x0c, 0x23, 0x93,
+	0xf4, 0x16, 0xbd, 0xab, 0xc9, 0x7a, 0xb2, 0x74, 0xd9, 0x32, 0x42, 0x69, 0x93, 0xf1, 0xef, 0x1e,
+	0xcc, 0xa2, 0xb9, 0x45, 0x12, 0xda, 0x94, 0x9b, 0x57, 0x51, 0x28, 0x94, 0x6c, 0x0a, 0x03, 0xd2,
+	0x64, 0x30, 0x8c, 0x8f, 0xd3, 0x5a, 0xb0, 0x05, 0x4c, 0x24, 0x96, 0xb9, 0xd7, 0x8e, 0xb4, 0x2d,
+	0x92, 0x7e, 0xc8, 0x7e, 0x5b, 0x6c, 0x0e, 0x23, 0x63, 0x0b, 0x45, 0xf8, 0x49, 0xc9, 0xbf, 0x10,
+	0xb7, 0x9a, 0x5d, 0xc0, 0x51, 0xb9, 0x13, 0xc6, 0xdc, 0xee, 0x84, 0xc2, 0xe7, 0xf4, 0x2e, 0xf9,
+	0x1f, 0x80, 0xae, 0x59, 0x51, 0x46, 0x78, 0x85, 0x2d, 0x35, 0xa8, 0xa9, 0x8e, 0xc9, 0xd7, 0x70,
+	0xd2, 0xb4, 0x41, 0xe7, 0x75, 0x8e, 0x92, 0x9d, 0xc3, 0x20, 0x9c, 0x62, 0xe3, 0x71, 0xd5, 0xf8,
+	0xde, 0x16, 0xb8, 0x4f, 0x6b, 0x9f, 0xaf, 0x61, 0xda, 0xce, 0xe4, 0x82, 0x50, 0x59, 0xaf, 0xbf,
+	0x50, 0x56, 0x6f, 0x8e, 0x72, 0x1f, 0xeb, 0xb6, 0x9a, 0x9f, 0xb6, 0xab, 0xdd, 0xa2, 0x41, 0x42,
+	0xc9, 0x1f, 0xe0, 0x30, 0x3a, 0x4f, 0x24, 0x08, 0xff, 0xb8, 0x6a, 0x96, 0xc0, 0x50, 0xd6, 0x37,
+	0x84, 0xb5, 0x8d, 0xd2, 0x46, 0x66, 0x07, 0xe1, 0x13, 0xaf, 0x7f, 0x06, 0x00, 0xb1, 0x87, 0xf6,
+	0xef, 0xfe, 0x01, 0x00, 0x00,
+}
@@ -18,6 +18,54 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
+type Money struct {
+	// amount in minor units of the currency, e.g. cents
+	Units int64 `protobuf:"varint,1,opt,name=units,proto3" json:"units,omitempty"`
+	// ISO 4217 currency code
+	Currency             string   `protobuf:"bytes,2,opt,name=currency,proto3" json:"currency,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Money) Reset()         { *m = Money{} }
+func (m *Money) String() string { return proto.CompactTextString(m) }
+func (*Money) ProtoMessage()    {}
+func (*Money) Descriptor() ([]byte, []int) {
+	return fileDescriptor_products_0c34519fb912584b, []int{0}
+}
+func (m *Money) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Money.Unmarshal(m, b)
+}
+func (m *Money) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Money.Marshal(b, m, deterministic)
+}
+func (dst *Money) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Money.Merge(dst, src)
+}
+func (m *Money) XXX_Size() int {
+	return xxx_messageInfo_Money.Size(m)
+}
+func (m *Money) XXX_DiscardUnknown() {
+	xxx_messageInfo_Money.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Money proto.InternalMessageInfo
+
+func (m *Money) GetUnits() int64 {
+	if m != nil {
+		return m.Units
+	}
+	return 0
+}
+
+func (m *Money) GetCurrency() string {
+	if m != nil {
+		return m.Currency
+	}
+	return ""
+}
+
 type Product struct {
 	Uuid                 string   `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
 	Title                string   `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
@@ -26,7 +74,8 @@ type Product struct {
 	Category             string   `protobuf:"bytes,8,opt,name=category,proto3" json:"category,omitempty"`
 	SmallImageURL        string   `protobuf:"bytes,5,opt,name=smallImageURL,proto3" json:"smallImageURL,omitempty"`
 	LargeImageURL        string   `protobuf:"bytes,6,opt,name=largeImageURL,proto3" json:"largeImageURL,omitempty"`
-	Price                float32  `protobuf:"fixed32,7,opt,name=price,proto3" json:"price,omitempty"`
+	LegacyPrice          float32  `protobuf:"fixed32,7,opt,name=legacyPrice,proto3" json:"legacyPrice,omitempty"` // Deprecated: Do not use.
+	Price                *Money   `protobuf:"bytes,9,opt,name=price,proto3" json:"price,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -36,7 +85,7 @@ func (m *Product) Reset()         { *m = Product{} }
 func (m *Product) String() string { return proto.CompactTextString(m) }
 func (*Product) ProtoMessage()    {}
 func (*Product) Descriptor() ([]byte, []int) {
-	return fileDescriptor_products_0810480f01a355b0, []int{0}
+	return fileDescriptor_products_0c34519fb912584b, []int{1}
 }
 func (m *Product) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Product.Unmarshal(m, b)
@@ -105,16 +154,26 @@ func (m *Product) GetLargeImageURL() string {
 	return ""
 }
 
-func (m *Product) GetPrice() float32 {
+// Deprecated: Do not use.
+func (m *Product) GetLegacyPrice() float32 {
 	if m != nil {
-		return m.Price
+		return m.LegacyPrice
 	}
 	return 0
 }
 
+func (m *Product) GetPrice() *Money {
+	if m != nil {
+		return m.Price
+	}
+	return nil
+}
+
 type ProductUpdate struct {
-	Old                  *Product `protobuf:"bytes,1,opt,name=old,proto3" json:"old,omitempty"`
-	New                  *Product `protobuf:"bytes,2,opt,name=new,proto3" json:"new,omitempty"`
+	Old *Product `protobuf:"bytes,1,opt,name=old,proto3" json:"old,omitempty"`
+	New *Product `protobuf:"bytes,2,opt,name=new,proto3" json:"new,omitempty"`
+	// catalogue the product belongs to, empty for the default catalogue
+	Tenant               string   `protobuf:"bytes,3,opt,name=tenant,proto3" json:"tenant,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -124,7 +183,7 @@ func (m *ProductUpdate) Reset()         { *m = ProductUpdate{} }
 func (m *ProductUpdate) String() string { return proto.CompactTextString(m) }
 func (*ProductUpdate) ProtoMessage()    {}
 func (*ProductUpdate) Descriptor() ([]byte, []int) {
-	return fileDescriptor_products_0810480f01a355b0, []int{1}
+	return fileDescriptor_products_0c34519fb912584b, []int{2}
 }
 func (m *ProductUpdate) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ProductUpdate.Unmarshal(m, b)
@@ -158,28 +217,40 @@ func (m *ProductUpdate) GetNew() *Product {
 	return nil
 }
 
+func (m *ProductUpdate) GetTenant() string {
+	if m != nil {
+		return m.Tenant
+	}
+	return ""
+}
+
 func init() {
+	proto.RegisterType((*Money)(nil), "pb.Money")
 	proto.RegisterType((*Product)(nil), "pb.Product")
 	proto.RegisterType((*ProductUpdate)(nil), "pb.ProductUpdate")
 }
 
-func init() { proto.RegisterFile("pkg/pb/products.proto", fileDescriptor_products_0810480f01a355b0) }
-
-var fileDescriptor_products_0810480f01a355b0 = []byte{
-	// 237 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0x90, 0xdf, 0x4a, 0xc3, 0x30,
-	0x14, 0xc6, 0x69, 0xf7, 0xd7, 0x53, 0x76, 0x13, 0x14, 0x82, 0x20, 0x94, 0xe1, 0xc5, 0xae, 0x3a,
-	0xd0, 0xa7, 0x10, 0x14, 0xa4, 0xb0, 0x07, 0x48, 0xdb, 0x43, 0x08, 0x66, 0xc9, 0x21, 0x3d, 0x65,
-	0xfa, 0xd4, 0xbe, 0x82, 0x24, 0x99, 0xc3, 0x79, 0x97, 0xef, 0xf7, 0xfb, 0x2e, 0xf2, 0x1d, 0xb8,
-	0xa3, 0x0f, 0xbd, 0xa7, 0x6e, 0x4f, 0xc1, 0x0f, 0x53, 0xcf, 0x63, 0x43, 0xc1, 0xb3, 0x17, 0x25,
-	0x75, 0xdb, 0xef, 0x02, 0x56, 0xef, 0x19, 0x0b, 0x01, 0xf3, 0x69, 0x32, 0x83, 0x2c, 0xea, 0x62,
-	0x77, 0xd3, 0xa6, 0xb7, 0xb8, 0x85, 0x05, 0x1b, 0xb6, 0x28, 0xcb, 0x04, 0x73, 0x10, 0x35, 0x54,
-	0x03, 0x8e, 0x7d, 0x30, 0xc4, 0xc6, 0x3b, 0x39, 0x4b, 0xee, 0x2f, 0x12, 0xf7, 0xb0, 0xb6, 0xde,
-	0x69, 0xc6, 0x4f, 0x96, 0xf3, 0xa4, 0x2f, 0x39, 0xba, 0x5e, 0x31, 0x6a, 0x1f, 0xbe, 0xe4, 0x3a,
-	0xbb, 0xdf, 0x2c, 0x1e, 0x61, 0x33, 0x1e, 0x95, 0xb5, 0x2f, 0x47, 0xa5, 0xf1, 0xd0, 0xbe, 0xca,
-	0x45, 0x2a, 0x5c, 0xc3, 0xd8, 0xb2, 0x2a, 0x68, 0xbc, 0xb4, 0x96, 0xb9, 0x75, 0x05, 0xe3, 0xdf,
-	0x29, 0x98, 0x1e, 0xe5, 0xaa, 0x2e, 0x76, 0x65, 0x9b, 0xc3, 0xf6, 0x0d, 0x36, 0xe7, 0xc1, 0x07,
-	0x1a, 0x14, 0xa3, 0x78, 0x80, 0x99, 0xb7, 0x79, 0x75, 0xf5, 0x54, 0x35, 0xd4, 0x35, 0x67, 0xdf,
-	0x46, 0x1e, 0xb5, 0xc3, 0x53, 0xda, 0xff, 0x5f, 0x3b, 0x3c, 0x75, 0xcb, 0x74, 0xcb, 0xe7, 0x9f,
-	0x00, 0x00, 0x00, 0xff, 0xff, 0xf8, 0x59, 0xd5, 0xef, 0x64, 0x01, 0x00, 0x00,
+func init() { proto.RegisterFile("pkg/pb/products.proto", fileDescriptor_products_0c34519fb912584b) }
+
+var fileDescriptor_products_0c34519fb912584b = []byte{
+	// 304 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x91, 0xc1, 0x6a, 0xc3, 0x30,
+	0x10, 0x44, 0xb1, 0x13, 0x27, 0xf1, 0x9a, 0x5c, 0x44, 0x5b, 0x44, 0xa1, 0xd4, 0x84, 0x1c, 0x7c,
+	0x72, 0x20, 0x3d, 0xf5, 0xda, 0x5b, 0xa1, 0x85, 0x20, 0xc8, 0x07, 0xc8, 0xf6, 0x62, 0x4c, 0x15,
+	0x49, 0xc8, 0x32, 0xa9, 0xff, 0xa6, 0x9f, 0x5a, 0x2c, 0xa9, 0x21, 0x81, 0xde, 0x3c, 0x6f, 0x46,
+	0xbb, 0xcb, 0x18, 0xee, 0xf5, 0x57, 0xbb, 0xd3, 0xd5, 0x4e, 0x1b, 0xd5, 0x0c, 0xb5, 0xed, 0x4b,
+	0x6d, 0x94, 0x55, 0x24, 0xd6, 0xd5, 0xe6, 0x15, 0x92, 0x4f, 0x25, 0x71, 0x24, 0x77, 0x90, 0x0c,
+	0xb2, 0xb3, 0x3d, 0x8d, 0xf2, 0xa8, 0x98, 0x31, 0x2f, 0xc8, 0x23, 0xac, 0xea, 0xc1, 0x18, 0x94,
+	0xf5, 0x48, 0xe3, 0x3c, 0x2a, 0x52, 0x76, 0xd1, 0x9b, 0x9f, 0x18, 0x96, 0x07, 0x3f, 0x91, 0x10,
+	0x98, 0x0f, 0x43, 0xd7, 0xb8, 0xc7, 0x29, 0x73, 0xdf, 0xd3, 0x44, 0xdb, 0x59, 0x81, 0xe1, 0xa1,
+	0x17, 0x24, 0x87, 0xac, 0xc1, 0xbe, 0x36, 0x9d, 0xb6, 0x9d, 0x92, 0x74, 0xe6, 0xbc, 0x6b, 0x34,
+	0xed, 0x14, 0x4a, 0xb6, 0x16, 0xbf, 0x2d, 0x9d, 0xfb, 0x9d, 0x7f, 0xda, 0xdd, 0xc3, 0x2d, 0xb6,
+	0xca, 0x8c, 0x74, 0x15, 0xee, 0x09, 0x9a, 0x6c, 0x61, 0xdd, 0x9f, 0xb8, 0x10, 0xef, 0x27, 0xde,
+	0xe2, 0x91, 0x7d, 0xd0, 0xc4, 0x05, 0x6e, 0xe1, 0x94, 0x12, 0xdc, 0xb4, 0x78, 0x49, 0x2d, 0x7c,
+	0xea, 0x06, 0x92, 0x2d, 0x64, 0x02, 0x5b, 0x5e, 0x8f, 0x07, 0xd3, 0xd5, 0x48, 0x97, 0x79, 0x54,
+	0xc4, 0x6f, 0x31, 0x8d, 0xd8, 0x35, 0x26, 0xcf, 0x90, 0x68, 0xe7, 0xa7, 0x79, 0x54, 0x64, 0xfb,
+	0xb4, 0xd4, 0x55, 0xe9, 0xda, 0x64, 0x9e, 0x6f, 0x10, 0xd6, 0xa1, 0xa1, 0xa3, 0x6e, 0xb8, 0x45,
+	0xf2, 0x04, 0x33, 0x25, 0x7c, 0x4d, 0xd9, 0x3e, 0x9b, 0xf2, 0xc1, 0x67, 0x13, 0x9f, 0x6c, 0x89,
+	0x67, 0x1a, 0xff, 0x63, 0x4b, 0x3c, 0x93, 0x07, 0x58, 0x58, 0x94, 0x5c, 0xda, 0x50, 0x5b, 0x50,
+	0xd5, 0xc2, 0xfd, 0xcf, 0x97, 0xdf, 0x01, 0x00, 0x95, 0x01, 0x35, 0x64, 0xe8, 0x01, 0x00, 0x00,
 }
@@ -0,0 +1,201 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: pkg/pb/snapshot.proto
+
+package pb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type PartitionOffset struct {
+	Topic                string   `protobuf:"bytes,1,opt,name=topic,proto3" json:"topic,omitempty"`
+	Partition            int32    `protobuf:"varint,2,opt,name=partition,proto3" json:"partition,omitempty"`
+	Offset               int64    `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PartitionOffset) Reset()         { *m = PartitionOffset{} }
+func (m *PartitionOffset) String() string { return proto.CompactTextString(m) }
+func (*PartitionOffset) ProtoMessage()    {}
+func (*PartitionOffset) Descriptor() ([]byte, []int) {
+	return fileDescriptor_snapshot_6b75c7b2998d60ff, []int{0}
+}
+func (m *PartitionOffset) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PartitionOffset.Unmarshal(m, b)
+}
+func (m *PartitionOffset) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PartitionOffset.Marshal(b, m, deterministic)
+}
+func (dst *PartitionOffset) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PartitionOffset.Merge(dst, src)
+}
+func (m *PartitionOffset) XXX_Size() int {
+	return xxx_messageInfo_PartitionOffset.Size(m)
+}
+func (m *PartitionOffset) XXX_DiscardUnknown() {
+	xxx_messageInfo_PartitionOffset.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PartitionOffset proto.InternalMessageInfo
+
+func (m *PartitionOffset) GetTopic() string {
+	if m != nil {
+		return m.Topic
+	}
+	return ""
+}
+
+func (m *PartitionOffset) GetPartition() int32 {
+	if m != nil {
+		return m.Partition
+	}
+	return 0
+}
+
+func (m *PartitionOffset) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+// ProjectionSnapshot starts a snapshot file, the offsets are the next messages to replay
+type ProjectionSnapshot struct {
+	Group                string             `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	CreatedAt            int64              `protobuf:"varint,2,opt,name=createdAt,proto3" json:"createdAt,omitempty"`
+	Offsets              []*PartitionOffset `protobuf:"bytes,3,rep,name=offsets,proto3" json:"offsets,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *ProjectionSnapshot) Reset()         { *m = ProjectionSnapshot{} }
+func (m *ProjectionSnapshot) String() string { return proto.CompactTextString(m) }
+func (*ProjectionSnapshot) ProtoMessage()    {}
+func (*ProjectionSnapshot) Descriptor() ([]byte, []int) {
+	return fileDescriptor_snapshot_6b75c7b2998d60ff, []int{1}
+}
+func (m *ProjectionSnapshot) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ProjectionSnapshot.Unmarshal(m, b)
+}
+func (m *ProjectionSnapshot) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ProjectionSnapshot.Marshal(b, m, deterministic)
+}
+func (dst *ProjectionSnapshot) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ProjectionSnapshot.Merge(dst, src)
+}
+func (m *ProjectionSnapshot) XXX_Size() int {
+	return xxx_messageInfo_ProjectionSnapshot.Size(m)
+}
+func (m *ProjectionSnapshot) XXX_DiscardUnknown() {
+	xxx_messageInfo_ProjectionSnapshot.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ProjectionSnapshot proto.InternalMessageInfo
+
+func (m *ProjectionSnapshot) GetGroup() string {
+	if m != nil {
+		return m.Group
+	}
+	return ""
+}
+
+func (m *ProjectionSnapshot) GetCreatedAt() int64 {
+	if m != nil {
+		return m.CreatedAt
+	}
+	return 0
+}
+
+func (m *ProjectionSnapshot) GetOffsets() []*PartitionOffset {
+	if m != nil {
+		return m.Offsets
+	}
+	return nil
+}
+
+// SnapshotEntry is a redis key with its value in DUMP format
+type SnapshotEntry struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value                []byte   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SnapshotEntry) Reset()         { *m = SnapshotEntry{} }
+func (m *SnapshotEntry) String() string { return proto.CompactTextString(m) }
+func (*SnapshotEntry) ProtoMessage()    {}
+func (*SnapshotEntry) Descriptor() ([]byte, []int) {
+	return fileDescriptor_snapshot_6b75c7b2998d60ff, []int{2}
+}
+func (m *SnapshotEntry) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SnapshotEntry.Unmarshal(m, b)
+}
+func (m *SnapshotEntry) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SnapshotEntry.Marshal(b, m, deterministic)
+}
+func (dst *SnapshotEntry) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SnapshotEntry.Merge(dst, src)
+}
+func (m *SnapshotEntry) XXX_Size() int {
+	return xxx_messageInfo_SnapshotEntry.Size(m)
+}
+func (m *SnapshotEntry) XXX_DiscardUnknown() {
+	xxx_messageInfo_SnapshotEntry.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SnapshotEntry proto.InternalMessageInfo
+
+func (m *SnapshotEntry) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *SnapshotEntry) GetValue() []byte {
+	if m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*PartitionOffset)(nil), "pb.PartitionOffset")
+	proto.RegisterType((*ProjectionSnapshot)(nil), "pb.ProjectionSnapshot")
+	proto.RegisterType((*SnapshotEntry)(nil), "pb.SnapshotEntry")
+}
+
+func init() { proto.RegisterFile("pkg/pb/snapshot.proto", fileDescriptor_snapshot_6b75c7b2998d60ff) }
+
+var fileDescriptor_snapshot_6b75c7b2998d60ff = []byte{
+	// 221 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x5c, 0xd0, 0x3f, 0x4f, 0xc3, 0x30,
+	0x10, 0x05, 0x70, 0xa5, 0x56, 0x8b, 0x7a, 0x80, 0x40, 0xe6, 0x8f, 0x32, 0x30, 0x58, 0x99, 0xbc,
+	0x90, 0x4a, 0x30, 0x30, 0x33, 0x30, 0x53, 0x99, 0x99, 0xc1, 0x0e, 0x6e, 0x09, 0x45, 0xb9, 0x93,
+	0x7d, 0x05, 0xf5, 0xdb, 0x23, 0x3b, 0xb1, 0x2a, 0xb1, 0xf9, 0x9d, 0x9e, 0xfd, 0x3b, 0x19, 0x6e,
+	0x68, 0xb7, 0x5d, 0x91, 0x5b, 0xc5, 0xc1, 0x52, 0xfc, 0x44, 0x6e, 0x29, 0x20, 0xa3, 0x9c, 0x91,
+	0x6b, 0xde, 0xe1, 0x62, 0x6d, 0x03, 0xf7, 0xdc, 0xe3, 0xf0, 0xba, 0xd9, 0x44, 0xcf, 0xf2, 0x1a,
+	0xe6, 0x8c, 0xd4, 0x77, 0x75, 0xa5, 0x2a, 0xbd, 0x34, 0x63, 0x90, 0x77, 0xb0, 0xa4, 0x52, 0xac,
+	0x67, 0xaa, 0xd2, 0x73, 0x73, 0x1c, 0xc8, 0x5b, 0x58, 0x60, 0xbe, 0x5d, 0x0b, 0x55, 0x69, 0x61,
+	0xa6, 0xd4, 0xfc, 0x82, 0x5c, 0x07, 0xfc, 0xf2, 0x5d, 0x6a, 0xbd, 0x4d, 0x7c, 0x12, 0xb6, 0x01,
+	0xf7, 0x54, 0x84, 0x1c, 0x92, 0xd0, 0x05, 0x6f, 0xd9, 0x7f, 0x3c, 0x73, 0x16, 0x84, 0x39, 0x0e,
+	0xe4, 0x3d, 0x9c, 0x8c, 0x6f, 0xc6, 0x5a, 0x28, 0xa1, 0x4f, 0x1f, 0xae, 0x5a, 0x72, 0xed, 0xbf,
+	0xdd, 0x4d, 0xe9, 0x34, 0x4f, 0x70, 0x5e, 0xb8, 0x97, 0x81, 0xc3, 0x41, 0x5e, 0x82, 0xd8, 0xf9,
+	0xc3, 0x24, 0xa6, 0x63, 0xda, 0xe2, 0xc7, 0x7e, 0xef, 0x7d, 0xb6, 0xce, 0xcc, 0x18, 0xdc, 0x22,
+	0xff, 0xcd, 0xe3, 0xdf, 0x00, 0x4b, 0xd3, 0x17, 0xfc, 0x34, 0x01, 0x00, 0x00,
+}
@@ -0,0 +1,531 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: pkg/pb/stock.proto
+
+package pb
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type StockReceived struct {
+	Quantity             int64    `protobuf:"varint,1,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	Reference            string   `protobuf:"bytes,2,opt,name=reference,proto3" json:"reference,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StockReceived) Reset()         { *m = StockReceived{} }
+func (m *StockReceived) String() string { return proto.CompactTextString(m) }
+func (*StockReceived) ProtoMessage()    {}
+func (*StockReceived) Descriptor() ([]byte, []int) {
+	return fileDescriptor_stock_417e6298474302be, []int{0}
+}
+func (m *StockReceived) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StockReceived.Unmarshal(m, b)
+}
+func (m *StockReceived) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StockReceived.Marshal(b, m, deterministic)
+}
+func (dst *StockReceived) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StockReceived.Merge(dst, src)
+}
+func (m *StockReceived) XXX_Size() int {
+	return xxx_messageInfo_StockReceived.Size(m)
+}
+func (m *StockReceived) XXX_DiscardUnknown() {
+	xxx_messageInfo_StockReceived.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StockReceived proto.InternalMessageInfo
+
+func (m *StockReceived) GetQuantity() int64 {
+	if m != nil {
+		return m.Quantity
+	}
+	return 0
+}
+
+func (m *StockReceived) GetReference() string {
+	if m != nil {
+		return m.Reference
+	}
+	return ""
+}
+
+type StockReserved struct {
+	Quantity             int64    `protobuf:"varint,1,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	ReservationID        string   `protobuf:"bytes,2,opt,name=reservationID,proto3" json:"reservationID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StockReserved) Reset()         { *m = StockReserved{} }
+func (m *StockReserved) String() string { return proto.CompactTextString(m) }
+func (*StockReserved) ProtoMessage()    {}
+func (*StockReserved) Descriptor() ([]byte, []int) {
+	return fileDescriptor_stock_417e6298474302be, []int{1}
+}
+func (m *StockReserved) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StockReserved.Unmarshal(m, b)
+}
+func (m *StockReserved) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StockReserved.Marshal(b, m, deterministic)
+}
+func (dst *StockReserved) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StockReserved.Merge(dst, src)
+}
+func (m *StockReserved) XXX_Size() int {
+	return xxx_messageInfo_StockReserved.Size(m)
+}
+func (m *StockReserved) XXX_DiscardUnknown() {
+	xxx_messageInfo_StockReserved.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StockReserved proto.InternalMessageInfo
+
+func (m *StockReserved) GetQuantity() int64 {
+	if m != nil {
+		return m.Quantity
+	}
+	return 0
+}
+
+func (m *StockReserved) GetReservationID() string {
+	if m != nil {
+		return m.ReservationID
+	}
+	return ""
+}
+
+type StockReleased struct {
+	Quantity             int64    `protobuf:"varint,1,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	ReservationID        string   `protobuf:"bytes,2,opt,name=reservationID,proto3" json:"reservationID,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StockReleased) Reset()         { *m = StockReleased{} }
+func (m *StockReleased) String() string { return proto.CompactTextString(m) }
+func (*StockReleased) ProtoMessage()    {}
+func (*StockReleased) Descriptor() ([]byte, []int) {
+	return fileDescriptor_stock_417e6298474302be, []int{2}
+}
+func (m *StockReleased) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StockReleased.Unmarshal(m, b)
+}
+func (m *StockReleased) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StockReleased.Marshal(b, m, deterministic)
+}
+func (dst *StockReleased) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StockReleased.Merge(dst, src)
+}
+func (m *StockReleased) XXX_Size() int {
+	return xxx_messageInfo_StockReleased.Size(m)
+}
+func (m *StockReleased) XXX_DiscardUnknown() {
+	xxx_messageInfo_StockReleased.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StockReleased proto.InternalMessageInfo
+
+func (m *StockReleased) GetQuantity() int64 {
+	if m != nil {
+		return m.Quantity
+	}
+	return 0
+}
+
+func (m *StockReleased) GetReservationID() string {
+	if m != nil {
+		return m.ReservationID
+	}
+	return ""
+}
+
+// StockAdjusted corrects the quantity on hand, e.g. after a stocktaking
+type StockAdjusted struct {
+	Delta                int64    `protobuf:"varint,1,opt,name=delta,proto3" json:"delta,omitempty"`
+	Reason               string   `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StockAdjusted) Reset()         { *m = StockAdjusted{} }
+func (m *StockAdjusted) String() string { return proto.CompactTextString(m) }
+func (*StockAdjusted) ProtoMessage()    {}
+func (*StockAdjusted) Descriptor() ([]byte, []int) {
+	return fileDescriptor_stock_417e6298474302be, []int{3}
+}
+func (m *StockAdjusted) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StockAdjusted.Unmarshal(m, b)
+}
+func (m *StockAdjusted) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StockAdjusted.Marshal(b, m, deterministic)
+}
+func (dst *StockAdjusted) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StockAdjusted.Merge(dst, src)
+}
+func (m *StockAdjusted) XXX_Size() int {
+	return xxx_messageInfo_StockAdjusted.Size(m)
+}
+func (m *StockAdjusted) XXX_DiscardUnknown() {
+	xxx_messageInfo_StockAdjusted.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StockAdjusted proto.InternalMessageInfo
+
+func (m *StockAdjusted) GetDelta() int64 {
+	if m != nil {
+		return m.Delta
+	}
+	return 0
+}
+
+func (m *StockAdjusted) GetReason() string {
+	if m != nil {
+		return m.Reason
+	}
+	return ""
+}
+
+// StockLevel is the state of a product after an event
+type StockLevel struct {
+	OnHand               int64    `protobuf:"varint,1,opt,name=onHand,proto3" json:"onHand,omitempty"`
+	Reserved             int64    `protobuf:"varint,2,opt,name=reserved,proto3" json:"reserved,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StockLevel) Reset()         { *m = StockLevel{} }
+func (m *StockLevel) String() string { return proto.CompactTextString(m) }
+func (*StockLevel) ProtoMessage()    {}
+func (*StockLevel) Descriptor() ([]byte, []int) {
+	return fileDescriptor_stock_417e6298474302be, []int{4}
+}
+func (m *StockLevel) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StockLevel.Unmarshal(m, b)
+}
+func (m *StockLevel) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StockLevel.Marshal(b, m, deterministic)
+}
+func (dst *StockLevel) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StockLevel.Merge(dst, src)
+}
+func (m *StockLevel) XXX_Size() int {
+	return xxx_messageInfo_StockLevel.Size(m)
+}
+func (m *StockLevel) XXX_DiscardUnknown() {
+	xxx_messageInfo_StockLevel.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StockLevel proto.InternalMessageInfo
+
+func (m *StockLevel) GetOnHand() int64 {
+	if m != nil {
+		return m.OnHand
+	}
+	return 0
+}
+
+func (m *StockLevel) GetReserved() int64 {
+	if m != nil {
+		return m.Reserved
+	}
+	return 0
+}
+
+type StockEvent struct {
+	Uuid       string      `protobuf:"bytes,1,opt,name=uuid,proto3" json:"uuid,omitempty"`
+	Version    int64       `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	OccurredAt int64       `protobuf:"varint,3,opt,name=occurredAt,proto3" json:"occurredAt,omitempty"`
+	Level      *StockLevel `protobuf:"bytes,4,opt,name=level,proto3" json:"level,omitempty"`
+	// Types that are valid to be assigned to Event:
+	//	*StockEvent_Received
+	//	*StockEvent_Reserved
+	//	*StockEvent_Released
+	//	*StockEvent_Adjusted
+	Event                isStockEvent_Event `protobuf_oneof:"event"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *StockEvent) Reset()         { *m = StockEvent{} }
+func (m *StockEvent) String() string { return proto.CompactTextString(m) }
+func (*StockEvent) ProtoMessage()    {}
+func (*StockEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_stock_417e6298474302be, []int{5}
+}
+func (m *StockEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StockEvent.Unmarshal(m, b)
+}
+func (m *StockEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StockEvent.Marshal(b, m, deterministic)
+}
+func (dst *StockEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StockEvent.Merge(dst, src)
+}
+func (m *StockEvent) XXX_Size() int {
+	return xxx_messageInfo_StockEvent.Size(m)
+}
+func (m *StockEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_StockEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StockEvent proto.InternalMessageInfo
+
+func (m *StockEvent) GetUuid() string {
+	if m != nil {
+		return m.Uuid
+	}
+	return ""
+}
+
+func (m *StockEvent) GetVersion() int64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *StockEvent) GetOccurredAt() int64 {
+	if m != nil {
+		return m.OccurredAt
+	}
+	return 0
+}
+
+func (m *StockEvent) GetLevel() *StockLevel {
+	if m != nil {
+		return m.Level
+	}
+	return nil
+}
+
+type isStockEvent_Event interface {
+	isStockEvent_Event()
+}
+
+type StockEvent_Received struct {
+	Received *StockReceived `protobuf:"bytes,10,opt,name=received,proto3,oneof"`
+}
+
+type StockEvent_Reserved struct {
+	Reserved *StockReserved `protobuf:"bytes,11,opt,name=reserved,proto3,oneof"`
+}
+
+type StockEvent_Released struct {
+	Released *StockReleased `protobuf:"bytes,12,opt,name=released,proto3,oneof"`
+}
+
+type StockEvent_Adjusted struct {
+	Adjusted *StockAdjusted `protobuf:"bytes,13,opt,name=adjusted,proto3,oneof"`
+}
+
+func (*StockEvent_Received) isStockEvent_Event() {}
+
+func (*StockEvent_Reserved) isStockEvent_Event() {}
+
+func (*StockEvent_Released) isStockEvent_Event() {}
+
+func (*StockEvent_Adjusted) isStockEvent_Event() {}
+
+func (m *StockEvent) GetEvent() isStockEvent_Event {
+	if m != nil {
+		return m.Event
+	}
+	return nil
+}
+
+func (m *StockEvent) GetReceived() *StockReceived {
+	if x, ok := m.GetEvent().(*StockEvent_Received); ok {
+		return x.Received
+	}
+	return nil
+}
+
+func (m *StockEvent) GetReserved() *StockReserved {
+	if x, ok := m.GetEvent().(*StockEvent_Reserved); ok {
+		return x.Reserved
+	}
+	return nil
+}
+
+func (m *StockEvent) GetReleased() *StockReleased {
+	if x, ok := m.GetEvent().(*StockEvent_Released); ok {
+		return x.Released
+	}
+	return nil
+}
+
+func (m *StockEvent) GetAdjusted() *StockAdjusted {
+	if x, ok := m.GetEvent().(*StockEvent_Adjusted); ok {
+		return x.Adjusted
+	}
+	return nil
+}
+
+// XXX_OneofFuncs is for the internal use of the proto package.
+func (*StockEvent) XXX_OneofFuncs() (func(msg proto.Message, b *proto.Buffer) error, func(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error), func(msg proto.Message) (n int), []interface{}) {
+	return _StockEvent_OneofMarshaler, _StockEvent_OneofUnmarshaler, _StockEvent_OneofSizer, []interface{}{
+		(*StockEvent_Received)(nil),
+		(*StockEvent_Reserved)(nil),
+		(*StockEvent_Released)(nil),
+		(*StockEvent_Adjusted)(nil),
+	}
+}
+
+func _StockEvent_OneofMarshaler(msg proto.Message, b *proto.Buffer) error {
+	m := msg.(*StockEvent)
+	// event
+	switch x := m.Event.(type) {
+	case *StockEvent_Received:
+		b.EncodeVarint(10<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Received); err != nil {
+			return err
+		}
+	case *StockEvent_Reserved:
+		b.EncodeVarint(11<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Reserved); err != nil {
+			return err
+		}
+	case *StockEvent_Released:
+		b.EncodeVarint(12<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Released); err != nil {
+			return err
+		}
+	case *StockEvent_Adjusted:
+		b.EncodeVarint(13<<3 | proto.WireBytes)
+		if err := b.EncodeMessage(x.Adjusted); err != nil {
+			return err
+		}
+	case nil:
+	default:
+		return fmt.Errorf("StockEvent.Event has unexpected type %T", x)
+	}
+	return nil
+}
+
+func _StockEvent_OneofUnmarshaler(msg proto.Message, tag, wire int, b *proto.Buffer) (bool, error) {
+	m := msg.(*StockEvent)
+	switch tag {
+	case 10: // event.received
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(StockReceived)
+		err := b.DecodeMessage(msg)
+		m.Event = &StockEvent_Received{msg}
+		return true, err
+	case 11: // event.reserved
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(StockReserved)
+		err := b.DecodeMessage(msg)
+		m.Event = &StockEvent_Reserved{msg}
+		return true, err
+	case 12: // event.released
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(StockReleased)
+		err := b.DecodeMessage(msg)
+		m.Event = &StockEvent_Released{msg}
+		return true, err
+	case 13: // event.adjusted
+		if wire != proto.WireBytes {
+			return true, proto.ErrInternalBadWireType
+		}
+		msg := new(StockAdjusted)
+		err := b.DecodeMessage(msg)
+		m.Event = &StockEvent_Adjusted{msg}
+		return true, err
+	default:
+		return false, nil
+	}
+}
+
+func _StockEvent_OneofSizer(msg proto.Message) (n int) {
+	m := msg.(*StockEvent)
+	// event
+	switch x := m.Event.(type) {
+	case *StockEvent_Received:
+		s := proto.Size(x.Received)
+		n += 1 // tag and wire
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *StockEvent_Reserved:
+		s := proto.Size(x.Reserved)
+		n += 1 // tag and wire
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *StockEvent_Released:
+		s := proto.Size(x.Released)
+		n += 1 // tag and wire
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case *StockEvent_Adjusted:
+		s := proto.Size(x.Adjusted)
+		n += 1 // tag and wire
+		n += proto.SizeVarint(uint64(s))
+		n += s
+	case nil:
+	default:
+		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
+	}
+	return n
+}
+
+func init() {
+	proto.RegisterType((*StockReceived)(nil), "pb.StockReceived")
+	proto.RegisterType((*StockReserved)(nil), "pb.StockReserved")
+	proto.RegisterType((*StockReleased)(nil), "pb.StockReleased")
+	proto.RegisterType((*StockAdjusted)(nil), "pb.StockAdjusted")
+	proto.RegisterType((*StockLevel)(nil), "pb.StockLevel")
+	proto.RegisterType((*StockEvent)(nil), "pb.StockEvent")
+}
+
+func init() { proto.RegisterFile("pkg/pb/stock.proto", fileDescriptor_stock_417e6298474302be) }
+
+var fileDescriptor_stock_417e6298474302be = []byte{
+	// 345 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x92, 0x4d, 0x4b, 0xfb, 0x40,
+	0x10, 0xc6, 0xff, 0x69, 0xfa, 0xf2, 0xef, 0xd4, 0x0a, 0x2e, 0x22, 0x8b, 0x88, 0x94, 0xd0, 0x43,
+	0x4f, 0x0d, 0xe8, 0x59, 0xb0, 0xa2, 0xd0, 0x82, 0x17, 0xd7, 0x4f, 0xb0, 0x49, 0x46, 0x89, 0x0d,
+	0xbb, 0x71, 0xb3, 0x09, 0xf8, 0x21, 0xfd, 0x4e, 0xb2, 0x2f, 0x49, 0xab, 0x3d, 0x78, 0xf1, 0xb6,
+	0xcf, 0xcc, 0xf3, 0x7b, 0x32, 0xd9, 0x59, 0x20, 0xe5, 0xf6, 0x35, 0x2e, 0x93, 0xb8, 0xd2, 0x32,
+	0xdd, 0x2e, 0x4b, 0x25, 0xb5, 0x24, 0xbd, 0x32, 0x89, 0x36, 0x30, 0x7d, 0x36, 0x25, 0x86, 0x29,
+	0xe6, 0x0d, 0x66, 0xe4, 0x1c, 0xfe, 0xbf, 0xd7, 0x5c, 0xe8, 0x5c, 0x7f, 0xd0, 0x60, 0x16, 0x2c,
+	0x42, 0xd6, 0x69, 0x72, 0x01, 0x63, 0x85, 0x2f, 0xa8, 0x50, 0xa4, 0x48, 0x7b, 0xb3, 0x60, 0x31,
+	0x66, 0xbb, 0x42, 0xf4, 0xd4, 0x45, 0x55, 0xa8, 0x7e, 0x8b, 0x9a, 0xc3, 0x54, 0x59, 0x1f, 0xd7,
+	0xb9, 0x14, 0x9b, 0x7b, 0x1f, 0xf7, 0xbd, 0xb8, 0x17, 0x59, 0x20, 0xaf, 0xfe, 0x24, 0xf2, 0xc6,
+	0x47, 0xae, 0xb2, 0xb7, 0xba, 0xd2, 0x98, 0x91, 0x53, 0x18, 0x64, 0x58, 0x68, 0xee, 0xf3, 0x9c,
+	0x20, 0x67, 0x30, 0x54, 0xc8, 0x2b, 0x29, 0x7c, 0x8a, 0x57, 0xd1, 0x2d, 0x80, 0xc5, 0x1f, 0xb1,
+	0xc1, 0xc2, 0xb8, 0xa4, 0x58, 0x73, 0x91, 0x79, 0xd8, 0x2b, 0x33, 0xa6, 0xf2, 0xb7, 0x60, 0xf9,
+	0x90, 0x75, 0x3a, 0xfa, 0xec, 0xf9, 0x88, 0x87, 0x06, 0x85, 0x26, 0x04, 0xfa, 0x75, 0x9d, 0xbb,
+	0x80, 0x31, 0xb3, 0x67, 0x42, 0x61, 0xd4, 0xa0, 0xaa, 0x72, 0xff, 0xf5, 0x90, 0xb5, 0x92, 0x5c,
+	0x02, 0xc8, 0x34, 0xad, 0x95, 0xc2, 0x6c, 0xa5, 0x69, 0x68, 0x9b, 0x7b, 0x15, 0x32, 0x87, 0x41,
+	0x61, 0x26, 0xa3, 0xfd, 0x59, 0xb0, 0x98, 0x5c, 0x1d, 0x2f, 0xcb, 0x64, 0xb9, 0x9b, 0x97, 0xb9,
+	0x26, 0x89, 0xcd, 0x78, 0x6e, 0xdf, 0x14, 0xac, 0xf1, 0xa4, 0x33, 0xb6, 0x0f, 0x61, 0xfd, 0x8f,
+	0x75, 0x26, 0x07, 0xf8, 0xff, 0x99, 0x1c, 0x00, 0xae, 0xe1, 0x00, 0x77, 0x76, 0x80, 0xdb, 0x19,
+	0x3d, 0x3a, 0x00, 0x5c, 0xc3, 0x01, 0xee, 0x6c, 0x00, 0xee, 0x37, 0x42, 0xa7, 0x3f, 0x80, 0x76,
+	0x55, 0x06, 0x68, 0x4d, 0x77, 0x23, 0x18, 0xa0, 0xb9, 0xc0, 0x64, 0x68, 0x1f, 0xf3, 0xf5, 0xd7,
+	0x00, 0xd4, 0xad, 0x50, 0xde, 0xe2, 0x02, 0x00, 0x00,
+}
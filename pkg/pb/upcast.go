@@ -0,0 +1,35 @@
+package pb
+
+import (
+	"fmt"
+	"math"
+)
+
+// LegacyCurrency is the currency of prices that got published as float before Money existed,
+// consumers set it from their --legacyCurrency flag
+var LegacyCurrency string
+
+// UpcastProduct converts a legacy float price into Money, it fails if LegacyCurrency is unset
+func UpcastProduct(p *Product) error {
+	if p == nil || p.Price != nil {
+		return nil
+	}
+	if LegacyCurrency == "" {
+		return fmt.Errorf("product %s has a legacy float price and the legacy currency is not set", p.Uuid)
+	}
+	p.Price = &Money{
+		Units:    int64(math.Round(float64(p.LegacyPrice) * 100)),
+		Currency: LegacyCurrency,
+	}
+	p.LegacyPrice = 0
+	return nil
+}
+
+// UpcastProductUpdate converts the legacy float prices of both product versions into Money
+func UpcastProductUpdate(u *ProductUpdate) error {
+	err := UpcastProduct(u.Old)
+	if err != nil {
+		return err
+	}
+	return UpcastProduct(u.New)
+}
@@ -0,0 +1,28 @@
+package pb
+
+import "testing"
+
+func TestUpcastProduct(t *testing.T) {
+	defer func(c string) { LegacyCurrency = c }(LegacyCurrency)
+
+	LegacyCurrency = ""
+	p := &Product{Uuid: "4c61efbc-4f73-43f6-ba88-cab234b10f63", LegacyPrice: 19.99}
+	err := UpcastProduct(p)
+	if err == nil || p.Price != nil {
+		t.Fatalf("expected a legacy price without legacy currency to fail, got %v", p.Price)
+	}
+
+	err = UpcastProduct(&Product{Price: &Money{Units: 1999, Currency: "USD"}})
+	if err != nil {
+		t.Fatalf("expected a product with money to need no legacy currency, got %s", err)
+	}
+
+	LegacyCurrency = "CHF"
+	err = UpcastProductUpdate(&ProductUpdate{New: p})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Price.Units != 1999 || p.Price.Currency != "CHF" || p.LegacyPrice != 0 {
+		t.Fatalf("expected 1999 CHF, got %v", p)
+	}
+}
@@ -0,0 +1,51 @@
+package product
+
+import (
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Counts sums up the updates of a diff
+type Counts struct {
+	Inserts int64
+	Updates int64
+	Deletes int64
+}
+
+// Diff calls fn with an update for every product that got inserted or changed between two versions
+// of a catalogue and afterwards for every product that got removed, unchanged products are skipped
+func Diff(prev, curr map[string]*pb.Product, fn func(uuid string, u *pb.ProductUpdate) error) (Counts, error) {
+	c := Counts{}
+
+	for UUID, p := range curr {
+		old, found := prev[UUID]
+		if found && proto.Equal(old, p) {
+			continue
+		}
+
+		if found {
+			c.Updates++
+		} else {
+			c.Inserts++
+		}
+
+		err := fn(UUID, &pb.ProductUpdate{Old: old, New: p})
+		if err != nil {
+			return c, err
+		}
+	}
+
+	for UUID, old := range prev {
+		if _, found := curr[UUID]; found {
+			continue
+		}
+
+		c.Deletes++
+		err := fn(UUID, &pb.ProductUpdate{Old: old})
+		if err != nil {
+			return c, err
+		}
+	}
+
+	return c, nil
+}
@@ -0,0 +1,83 @@
+package product
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/damoon/eventstore-example/pkg/fake"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// catalogues returns two versions of a catalogue, a tenth of the products is changed, removed or added in the second one
+func catalogues(tb testing.TB, size int) (map[string]*pb.Product, map[string]*pb.Product) {
+	g, err := fake.New(0, fake.Options{Categories: 500, Zipf: 1.2, Prices: "lognormal", MeanPrice: 2500, TextSize: "small"})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	prev := make(map[string]*pb.Product, size)
+	curr := make(map[string]*pb.Product, size)
+	for i := 0; i < size; i++ {
+		p := g.Product("EUR")
+		prev[p.Uuid] = p
+		curr[p.Uuid] = p
+	}
+
+	i := 0
+	for UUID, p := range prev {
+		if i >= size/10 {
+			break
+		}
+		switch i % 3 {
+		case 0:
+			changed := proto.Clone(p).(*pb.Product)
+			changed.Price = &pb.Money{Units: p.Price.Units + 1, Currency: p.Price.Currency}
+			curr[UUID] = changed
+		case 1:
+			delete(curr, UUID)
+		case 2:
+			added := g.Product("EUR")
+			curr[added.Uuid] = added
+		}
+		i++
+	}
+	return prev, curr
+}
+
+func TestDiff(t *testing.T) {
+	prev, curr := catalogues(t, 300)
+
+	updates := map[string]*pb.ProductUpdate{}
+	c, err := Diff(prev, curr, func(UUID string, u *pb.ProductUpdate) error {
+		updates[UUID] = u
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c != (Counts{Inserts: 10, Updates: 10, Deletes: 10}) {
+		t.Fatalf("expected 10 inserts, updates and deletes, got %+v", c)
+	}
+	for UUID, u := range updates {
+		if u.Old != prev[UUID] || u.New != curr[UUID] {
+			t.Fatalf("update of %s does not match the catalogues", UUID)
+		}
+	}
+}
+
+func BenchmarkDiff(b *testing.B) {
+	for _, size := range []int{10000, 100000} {
+		prev, curr := catalogues(b, size)
+		b.Run(fmt.Sprintf("%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_, err := Diff(prev, curr, func(string, *pb.ProductUpdate) error { return nil })
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package product
+
+import (
+	"fmt"
+
+	"github.com/damoon/eventstore-example/pkg/aggregate"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Product is the aggregate of the master data of one product
+type Product struct {
+	root    aggregate.Root
+	state   *pb.Product
+	deleted bool
+}
+
+// New constructs an empty product aggregate
+func New(uuid string) aggregate.Aggregate {
+	return &Product{
+		root: aggregate.NewRoot(uuid),
+	}
+}
+
+// Root returns the bookkeeping of version and unsaved events
+func (p *Product) Root() *aggregate.Root {
+	return &p.root
+}
+
+// State returns the current product, nil if it does not exist
+func (p *Product) State() *pb.Product {
+	if p.deleted {
+		return nil
+	}
+	return p.state
+}
+
+// Create records a new product
+func (p *Product) Create(product *pb.Product) error {
+	if p.state != nil && !p.deleted {
+		return fmt.Errorf("product %s exists already", p.root.ID())
+	}
+	if product.Uuid != p.root.ID() {
+		return fmt.Errorf("uuid %s does not match product %s", product.Uuid, p.root.ID())
+	}
+	err := validate(product)
+	if err != nil {
+		return err
+	}
+	return aggregate.Record(p, &pb.ProductCreated{Product: proto.Clone(product).(*pb.Product)})
+}
+
+// Change records the events needed to turn the product into the given one
+func (p *Product) Change(product *pb.Product) error {
+	if p.State() == nil {
+		return fmt.Errorf("product %s does not exist", p.root.ID())
+	}
+	if product.Uuid != p.root.ID() {
+		return fmt.Errorf("uuid %s does not match product %s", product.Uuid, p.root.ID())
+	}
+	err := validate(product)
+	if err != nil {
+		return err
+	}
+
+	current := p.state
+	if current.Title != product.Title ||
+		current.Description != product.Description ||
+		current.Longtext != product.Longtext ||
+		current.SmallImageURL != product.SmallImageURL ||
+		current.LargeImageURL != product.LargeImageURL {
+		err := aggregate.Record(p, &pb.ProductDetailsChanged{
+			Title:         product.Title,
+			Description:   product.Description,
+			Longtext:      product.Longtext,
+			SmallImageURL: product.SmallImageURL,
+			LargeImageURL: product.LargeImageURL,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !proto.Equal(current.Price, product.Price) {
+		err := aggregate.Record(p, &pb.ProductRepriced{Price: proto.Clone(product.Price).(*pb.Money)})
+		if err != nil {
+			return err
+		}
+	}
+	if current.Category != product.Category {
+		err := aggregate.Record(p, &pb.ProductRecategorized{Category: product.Category})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete records the removal of the product
+func (p *Product) Delete() error {
+	if p.State() == nil {
+		return fmt.Errorf("product %s does not exist", p.root.ID())
+	}
+	return aggregate.Record(p, &pb.ProductDeleted{})
+}
+
+// Apply changes the state according to an event that already happened
+func (p *Product) Apply(event proto.Message) error {
+	if _, ok := event.(*pb.ProductCreated); !ok && p.state == nil {
+		return fmt.Errorf("product %s does not exist, it can not apply %T", p.root.ID(), event)
+	}
+
+	switch e := event.(type) {
+	case *pb.ProductCreated:
+		p.state = proto.Clone(e.Product).(*pb.Product)
+		p.deleted = false
+	case *pb.ProductDetailsChanged:
+		p.state.Title = e.Title
+		p.state.Description = e.Description
+		p.state.Longtext = e.Longtext
+		p.state.SmallImageURL = e.SmallImageURL
+		p.state.LargeImageURL = e.LargeImageURL
+	case *pb.ProductRepriced:
+		p.state.Price = proto.Clone(e.Price).(*pb.Money)
+	case *pb.ProductRecategorized:
+		p.state.Category = e.Category
+	case *pb.ProductDeleted:
+		p.deleted = true
+	default:
+		return fmt.Errorf("unknown product event %T", event)
+	}
+	return nil
+}
+
+// Snapshot captures a copy of the current state
+func (p *Product) Snapshot() (proto.Message, error) {
+	if p.state == nil {
+		return nil, fmt.Errorf("product %s does not exist, there is no state to snapshot", p.root.ID())
+	}
+	return &pb.ProductState{Product: proto.Clone(p.state).(*pb.Product), Deleted: p.deleted}, nil
+}
+
+// Restore replaces the state by a snapshot
+func (p *Product) Restore(snapshot proto.Message) error {
+	s, ok := snapshot.(*pb.ProductState)
+	if !ok {
+		return fmt.Errorf("unexpected snapshot %T", snapshot)
+	}
+	if s.Product == nil {
+		return fmt.Errorf("snapshot of product %s holds no product", p.root.ID())
+	}
+	p.state = proto.Clone(s.Product).(*pb.Product)
+	p.deleted = s.Deleted
+	return nil
+}
+
+func validate(product *pb.Product) error {
+	if product.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if product.Category == "" {
+		return fmt.Errorf("category is required")
+	}
+	if product.Price == nil {
+		return fmt.Errorf("price is required")
+	}
+	if product.Price.Units < 0 {
+		return fmt.Errorf("price must not be negative")
+	}
+	if product.Price.Currency == "" {
+		return fmt.Errorf("currency is required")
+	}
+	return nil
+}
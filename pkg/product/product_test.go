@@ -0,0 +1,258 @@
+package product
+
+import (
+	"testing"
+
+	"github.com/damoon/eventstore-example/pkg/aggregate"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/golang/protobuf/proto"
+)
+
+const lampID = "4c61efbc-4f73-43f6-ba88-cab234b10f63"
+
+// loads records the first version of every read of an event stream
+type loads struct {
+	aggregate.Store
+	from []int64
+}
+
+func (l *loads) Load(id string, from int64) ([]aggregate.Event, error) {
+	l.from = append(l.from, from)
+	return l.Store.Load(id, from)
+}
+
+func lamp() *pb.Product {
+	return &pb.Product{
+		Uuid:     lampID,
+		Title:    "Lamp",
+		Category: "Home/Light",
+		Price:    &pb.Money{Units: 1999, Currency: "EUR"},
+	}
+}
+
+func load(t *testing.T, r *aggregate.Repository) *Product {
+	t.Helper()
+	a, err := r.Load(lampID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a.(*Product)
+}
+
+func save(t *testing.T, r *aggregate.Repository, p *Product, expectedVersion int64) {
+	t.Helper()
+	err := r.Save(p, expectedVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVersionConflict(t *testing.T) {
+	r := aggregate.NewRepository(aggregate.NewMemoryStore(), nil, 0, New)
+
+	p := load(t, r)
+	err := p.Create(lamp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	save(t, r, p, 0)
+
+	// two writers change the same version
+	a, b := load(t, r), load(t, r)
+	repriced := lamp()
+	repriced.Price.Units = 2499
+	err = a.Change(repriced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	save(t, r, a, 1)
+
+	renamed := lamp()
+	renamed.Title = "Desk lamp"
+	err = b.Change(renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Save(b, 1)
+	conflict, ok := err.(aggregate.ConflictError)
+	if !ok || conflict.Expected != 1 || conflict.Actual != 2 {
+		t.Fatalf("expected a conflict at version 2, got %v", err)
+	}
+
+	// the loser keeps its changes, it can retry after loading again
+	if len(b.Root().Changes()) != 1 {
+		t.Fatalf("expected the rejected change to stay unsaved, got %v", b.Root().Changes())
+	}
+	p = load(t, r)
+	if p.Root().Version() != 2 || p.State().Price.Units != 2499 || p.State().Title != "Lamp" {
+		t.Fatalf("expected only the first change at version 2, got %v at version %d", p.State(), p.Root().Version())
+	}
+
+	err = r.Save(p, 1)
+	if _, ok := err.(aggregate.ConflictError); !ok {
+		t.Fatalf("expected a conflict for an outdated expected version, got %v", err)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	store := &loads{Store: aggregate.NewMemoryStore()}
+	snapshots := aggregate.NewMemorySnapshotStore()
+	r := aggregate.NewRepository(store, snapshots, 2, New)
+
+	p := load(t, r)
+	err := p.Create(lamp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	save(t, r, p, 0)
+	s, err := snapshots.LoadSnapshot(lampID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != nil {
+		t.Fatalf("expected no snapshot before version 2, got version %d", s.Version)
+	}
+
+	changed := lamp()
+	changed.Price.Units = 2499
+	changed.Category = "Home/Office"
+	err = p.Change(changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	save(t, r, p, 1)
+	if p.Root().Version() != 3 {
+		t.Fatalf("expected a reprice and a recategorization, got version %d", p.Root().Version())
+	}
+	s, err = snapshots.LoadSnapshot(lampID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s == nil || s.Version != 3 || s.Type != proto.MessageName(&pb.ProductState{}) {
+		t.Fatalf("expected a product state snapshot at version 3, got %+v", s)
+	}
+
+	// only the events after the snapshot are read
+	store.from = nil
+	p = load(t, r)
+	if len(store.from) != 1 || store.from[0] != 4 {
+		t.Fatalf("expected to read the events from version 4, read from %v", store.from)
+	}
+	if p.Root().Version() != 3 || !proto.Equal(p.State(), changed) {
+		t.Fatalf("expected %v at version 3, got %v at version %d", changed, p.State(), p.Root().Version())
+	}
+}
+
+func TestReplayAfterSnapshot(t *testing.T) {
+	events := aggregate.NewMemoryStore()
+	snapshots := aggregate.NewMemorySnapshotStore()
+	r := aggregate.NewRepository(events, snapshots, 2, New)
+
+	p := load(t, r)
+	err := p.Create(lamp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	renamed := lamp()
+	renamed.Title = "Desk lamp"
+	err = p.Change(renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	save(t, r, p, 0)
+
+	// events 3 and 4 are saved without taking another snapshot
+	repriced := proto.Clone(renamed).(*pb.Product)
+	repriced.Price.Units = 2499
+	err = p.Change(repriced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	save(t, r, p, 2)
+	p = load(t, r)
+	err = p.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = aggregate.NewRepository(events, nil, 0, New).Save(p, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := snapshots.LoadSnapshot(lampID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s == nil || s.Version != 2 {
+		t.Fatalf("expected the snapshot at version 2, got %+v", s)
+	}
+
+	p = load(t, r)
+	if p.Root().Version() != 4 || p.State() != nil {
+		t.Fatalf("expected the deleted product at version 4, got %v at version %d", p.State(), p.Root().Version())
+	}
+
+	// a product can be created again after the replayed delete
+	err = p.Create(repriced)
+	if err != nil {
+		t.Fatal(err)
+	}
+	save(t, r, p, 4)
+	p = load(t, r)
+	if p.Root().Version() != 5 || !proto.Equal(p.State(), repriced) {
+		t.Fatalf("expected %v at version 5, got %v at version %d", repriced, p.State(), p.Root().Version())
+	}
+}
+
+func TestApplyWithoutCreate(t *testing.T) {
+	p := New(lampID).(*Product)
+	for _, e := range []proto.Message{
+		&pb.ProductDetailsChanged{Title: "Lamp"},
+		&pb.ProductRepriced{Price: &pb.Money{Units: 1999, Currency: "EUR"}},
+		&pb.ProductRecategorized{Category: "Home/Light"},
+		&pb.ProductDeleted{},
+	} {
+		err := p.Apply(e)
+		if err == nil {
+			t.Fatalf("expected %T to fail on a product that was never created", e)
+		}
+	}
+	_, err := p.Snapshot()
+	if err == nil {
+		t.Fatal("expected no snapshot of a product that was never created")
+	}
+}
+
+func TestSnapshotIsACopy(t *testing.T) {
+	p := New(lampID).(*Product)
+	err := p.Apply(&pb.ProductCreated{Product: lamp()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := p.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// later events do not change a taken snapshot and a restored state does not share the snapshot
+	err = p.Apply(&pb.ProductRecategorized{Category: "Home/Office"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.(*pb.ProductState).Product.Category != "Home/Light" {
+		t.Fatalf("expected the snapshot to keep the category, got %v", s)
+	}
+
+	restored := New(lampID).(*Product)
+	err = restored.Restore(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = restored.Apply(&pb.ProductRepriced{Price: &pb.Money{Units: 2499, Currency: "EUR"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.(*pb.ProductState).Product.Price.Units != 1999 || restored.State().Price.Units != 2499 {
+		t.Fatalf("expected the restored product to change on its own, got %v and %v", s, restored.State())
+	}
+}
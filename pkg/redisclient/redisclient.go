@@ -0,0 +1,68 @@
+package redisclient
+
+import (
+	"fmt"
+
+	"github.com/go-redis/redis"
+)
+
+// Options select a standalone redis, a sentinel monitored master or a redis cluster
+type Options struct {
+	// Addresses of the redis server, the sentinels or the cluster nodes
+	Addresses []string
+	// MasterName enables sentinel failover for the master with this name
+	MasterName string
+	// Cluster enables the cluster client
+	Cluster  bool
+	Password string
+	Database int
+}
+
+// New constructs the client matching the options
+func New(o Options) (redis.UniversalClient, error) {
+	if len(o.Addresses) == 0 {
+		return nil, fmt.Errorf("no redis address given")
+	}
+
+	switch {
+	case o.MasterName != "" && o.Cluster:
+		return nil, fmt.Errorf("sentinel and cluster mode exclude each other")
+
+	case o.MasterName != "":
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    o.MasterName,
+			SentinelAddrs: o.Addresses,
+			Password:      o.Password,
+			DB:            o.Database,
+		}), nil
+
+	case o.Cluster:
+		if o.Database != 0 {
+			return nil, fmt.Errorf("redis cluster only supports database 0")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:    o.Addresses,
+			Password: o.Password,
+		}), nil
+	}
+
+	if len(o.Addresses) > 1 {
+		return nil, fmt.Errorf("multiple redis addresses need sentinel or cluster mode")
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:     o.Addresses[0],
+		Password: o.Password,
+		DB:       o.Database,
+	}), nil
+}
+
+// ForEachMaster calls fn for every node holding a share of the keys, scans need to visit all of them
+func ForEachMaster(c redis.UniversalClient, fn func(c *redis.Client) error) error {
+	switch client := c.(type) {
+	case *redis.ClusterClient:
+		return client.ForEachMaster(fn)
+	case *redis.Client:
+		return fn(client)
+	}
+	return fmt.Errorf("unsupported redis client %T", c)
+}
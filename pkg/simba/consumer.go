@@ -1,103 +1,256 @@
 package simba
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	"github.com/Shopify/sarama"
-	cluster "github.com/bsm/sarama-cluster"
 )
 
 const msgBuffer = 10000
 const maxOffsetDelay = 5 * time.Second
+const maxBatchSize = 1000
+
+// Source delivers the messages of a fixed assignment to a Consumer, like a subscription of the embedded event store.
+// Kafka partitions move between the members of a group, they are consumed with Run.
+type Source interface {
+	Messages() <-chan *sarama.ConsumerMessage
+	Errors() <-chan error
+	MarkOffset(msg *sarama.ConsumerMessage, metadata string)
+	Close() error
+}
 
 // Consumer fetches messages from kafka and calls the view function to update itself
 type Consumer struct {
-	doneCh   chan struct{}
-	consumer *cluster.Consumer
+	consumer Source
 	view     func(msg *sarama.ConsumerMessage) error
-	msgs     chan *sarama.ConsumerMessage
-	wg       *sync.WaitGroup
-	mux      *sync.Mutex
+	batch    func(msgs []*sarama.ConsumerMessage) error
+	offsets  *offsetTracker
+	ordered  bool
+	workers  map[topicPartition]chan *sarama.ConsumerMessage
+	wmux     sync.Mutex
+	inFlight *inFlight
+
+	onAssigned func(partitions map[string][]int32) error
+	onRevoked  func(partitions map[string][]int32) error
+
+	// ctx ends the loops of Start and Run on Stop, running counts them so Stop can wait
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mux     sync.Mutex
+	running sync.WaitGroup
 }
 
-// NewConsumer constructs a startable Consumer
-func NewConsumer(consumer *cluster.Consumer, view func(msg *sarama.ConsumerMessage) error) *Consumer {
+// NewConsumer constructs a startable Consumer, the source is only used by Start
+// and can be nil for consumers that Run a sarama consumer group
+func NewConsumer(consumer Source, view func(msg *sarama.ConsumerMessage) error) *Consumer {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Consumer{
 		consumer: consumer,
-		doneCh:   make(chan struct{}),
 		view:     view,
-		msgs:     make(chan *sarama.ConsumerMessage, msgBuffer),
-		wg:       &sync.WaitGroup{},
-		mux:      &sync.Mutex{},
+		offsets:  newOffsetTracker(),
+		inFlight: newInFlight(DefaultLimits),
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 }
 
-// Stop ends eventloop
+// NewOrderedConsumer constructs a startable Consumer that calls the view function
+// for the messages of a partition one after another in offset order
+func NewOrderedConsumer(consumer Source, view func(msg *sarama.ConsumerMessage) error) *Consumer {
+	c := NewConsumer(consumer, view)
+	c.ordered = true
+	c.workers = make(map[topicPartition]chan *sarama.ConsumerMessage)
+	return c
+}
+
+// NewBatchConsumer constructs a startable Consumer that calls the view function with the queued
+// messages of a partition in offset order, at most maxBatchSize at a time
+func NewBatchConsumer(consumer Source, view func(msgs []*sarama.ConsumerMessage) error) *Consumer {
+	c := NewOrderedConsumer(consumer, nil)
+	c.batch = view
+	return c
+}
+
+// SetLimits replaces the DefaultLimits, it has to be called before Start
+func (c *Consumer) SetLimits(l Limits) {
+	c.inFlight = newInFlight(l)
+}
+
+// OnAssigned sets a callback that runs when partitions got assigned, before their messages are incorporated
+func (c *Consumer) OnAssigned(f func(partitions map[string][]int32) error) {
+	c.onAssigned = f
+}
+
+// OnRevoked sets a callback that runs when partitions get revoked, after their messages are incorporated
+func (c *Consumer) OnRevoked(f func(partitions map[string][]int32) error) {
+	c.onRevoked = f
+}
+
+// Stop ends Start and Run and waits for them to return, a stopped Consumer does not start again
 func (c *Consumer) Stop() {
-	c.doneCh <- struct{}{}
+	c.mux.Lock()
+	c.cancel()
+	c.mux.Unlock()
+	c.running.Wait()
 }
 
-// Start listens for events from kafka
-func (c *Consumer) Start() {
+// begin counts a loop of Start or Run, it is false once the Consumer is stopped
+func (c *Consumer) begin() bool {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.ctx.Err() != nil {
+		return false
+	}
+	c.running.Add(1)
+	return true
+}
+
+// Start consumes the source until Stop is called
+func (c *Consumer) Start() error {
+	if c.consumer == nil {
+		return fmt.Errorf("no source to start, consumer groups are consumed with Run")
+	}
+	if !c.begin() {
+		return nil
+	}
+	defer c.running.Done()
 
-	saveOffset := time.NewTimer(5 * time.Second)
+	saveOffset := time.NewTicker(maxOffsetDelay)
+	defer saveOffset.Stop()
 
+	// held is a fetched message that waits for the limits, it is the next one of its partition
+	var held *sarama.ConsumerMessage
 	for {
+		if held != nil && c.inFlight.acquire(held) {
+			c.dispatch(held)
+			held = nil
+		}
+
+		// a nil channel blocks, this pauses consumption until work completes
+		messages := c.consumer.Messages()
+		if held != nil {
+			c.inFlight.pause()
+			messages = nil
+		} else {
+			c.inFlight.resume()
+		}
+
 		select {
 		case err := <-c.consumer.Errors():
 			log.Panicf("failure from kafka consumer: %s", err)
 
-		case ntf := <-c.consumer.Notifications():
-			log.Printf("Rebalanced: %+v\n", ntf)
-
-		case msg := <-c.consumer.Messages():
-			c.wg.Add(1)
-			c.msgs <- msg
-			go func(wg *sync.WaitGroup) {
-				defer wg.Done()
-				err := c.view(msg)
-				if err != nil {
-					log.Panicf("failed to incorporate msg into view: %s", err)
-				}
-			}(c.wg)
-			if len(c.msgs) == msgBuffer {
-				saveOffset.Stop()
-				c.persistOffset()
-				saveOffset = time.NewTimer(maxOffsetDelay)
+		case <-c.inFlight.released:
+
+		case msg := <-messages:
+			if c.inFlight.acquire(msg) {
+				c.dispatch(msg)
+			} else {
+				held = msg
 			}
 
 		case <-saveOffset.C:
 			c.persistOffset()
-			saveOffset = time.NewTimer(maxOffsetDelay)
 
-		case <-c.doneCh:
-			log.Print("interrupt is detected")
-			saveOffset.Stop()
+		case <-c.ctx.Done():
+			log.Print("stop is requested")
 			c.persistOffset()
 			c.consumer.Close()
-			return
+			c.wmux.Lock()
+			for _, w := range c.workers {
+				close(w)
+			}
+			c.wmux.Unlock()
+			return nil
 		}
 	}
 }
 
-func (c *Consumer) persistOffset() {
-	if len(c.msgs) == 0 {
-		return
+// dispatch hands an acquired message to the worker of its partition or to a goroutine
+func (c *Consumer) dispatch(msg *sarama.ConsumerMessage) {
+	c.offsets.add(msg)
+	if c.ordered {
+		c.worker(msg) <- msg
+	} else {
+		go c.incorporate(msg)
 	}
+}
 
-	c.mux.Lock()
-	go func(wg *sync.WaitGroup, msgs chan *sarama.ConsumerMessage) {
-		defer c.mux.Unlock()
-		wg.Wait()
-		close(msgs)
-		log.Printf("processed %d messages", len(msgs))
-		for msg := range msgs {
-			c.consumer.MarkOffset(msg, "")
+func (c *Consumer) incorporate(msg *sarama.ConsumerMessage) {
+	err := c.view(msg)
+	if err != nil {
+		log.Panicf("failed to incorporate msg into view: %s", err)
+	}
+	c.complete(msg)
+}
+
+func (c *Consumer) incorporateBatch(msgs []*sarama.ConsumerMessage) {
+	err := c.batch(msgs)
+	if err != nil {
+		log.Panicf("failed to incorporate msgs into view: %s", err)
+	}
+	for _, msg := range msgs {
+		c.complete(msg)
+	}
+}
+
+// complete releases the message and allows to commit its offset once its predecessors completed
+func (c *Consumer) complete(msg *sarama.ConsumerMessage) {
+	c.offsets.complete(msg)
+	c.inFlight.release(msg)
+}
+
+// drain adds the messages that are queued already to the batch
+func drain(w chan *sarama.ConsumerMessage, batch []*sarama.ConsumerMessage) []*sarama.ConsumerMessage {
+	for len(batch) < maxBatchSize {
+		select {
+		case msg, ok := <-w:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, msg)
+		default:
+			return batch
 		}
-	}(c.wg, c.msgs)
+	}
+	return batch
+}
+
+// worker returns the queue of the partition of the message
+func (c *Consumer) worker(msg *sarama.ConsumerMessage) chan *sarama.ConsumerMessage {
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
 
-	c.wg = &sync.WaitGroup{}
-	c.msgs = make(chan *sarama.ConsumerMessage, msgBuffer)
+	tp := topicPartition{topic: msg.Topic, partition: msg.Partition}
+	w, ok := c.workers[tp]
+	if !ok {
+		// the limits keep the messages in flight below the capacity, sending never blocks
+		w = make(chan *sarama.ConsumerMessage, c.inFlight.limits.Messages)
+		c.workers[tp] = w
+		go func() {
+			for msg := range w {
+				if c.batch == nil {
+					c.incorporate(msg)
+					continue
+				}
+				c.incorporateBatch(drain(w, []*sarama.ConsumerMessage{msg}))
+			}
+		}()
+	}
+	return w
+}
+
+// persistOffset marks the completion watermark of every partition
+func (c *Consumer) persistOffset() {
+	marks, count := c.offsets.watermarks()
+	if count == 0 {
+		return
+	}
+	log.Printf("processed %d messages", count)
+	for _, msg := range marks {
+		c.consumer.MarkOffset(msg, "")
+	}
 }
@@ -0,0 +1,83 @@
+package simba
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// group blocks in Consume until its context is done, like a member without claims
+type group struct {
+	errs chan error
+}
+
+func (g *group) Consume(ctx context.Context, topics []string, handler sarama.ConsumerGroupHandler) error {
+	<-ctx.Done()
+	return nil
+}
+func (g *group) Errors() <-chan error { return g.errs }
+func (g *group) Close() error         { return nil }
+
+// stopped fails the test if stop does not return in time
+func stopped(t *testing.T, stop func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop did not return")
+	}
+}
+
+func TestStartWithoutSource(t *testing.T) {
+	c := NewBatchConsumer(nil, func(msgs []*sarama.ConsumerMessage) error { return nil })
+	err := c.Start()
+	if err == nil {
+		t.Fatal("expected Start without source to fail")
+	}
+	stopped(t, c.Stop)
+}
+
+func TestStopEndsStart(t *testing.T) {
+	src := &source{msgs: make(chan *sarama.ConsumerMessage), errs: make(chan error)}
+	c := NewConsumer(src, func(msg *sarama.ConsumerMessage) error { return nil })
+
+	result := make(chan error, 1)
+	go func() { result <- c.Start() }()
+	time.Sleep(10 * time.Millisecond)
+	stopped(t, c.Stop)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// a second Stop and a Start after Stop return right away
+	stopped(t, c.Stop)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStopEndsRun(t *testing.T) {
+	c := NewBatchConsumer(nil, func(msgs []*sarama.ConsumerMessage) error { return nil })
+	g := &group{errs: make(chan error)}
+	defer close(g.errs)
+
+	result := make(chan error, 1)
+	go func() { result <- c.Run(context.Background(), g, []string{"products"}) }()
+	time.Sleep(10 * time.Millisecond)
+	stopped(t, c.Stop)
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+	}
+}
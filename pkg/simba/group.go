@@ -0,0 +1,108 @@
+package simba
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Run joins the consumer group and incorporates the claimed partitions until the context is done or Stop is called.
+// The Consumer is the sarama.ConsumerGroupHandler of every session.
+func (c *Consumer) Run(ctx context.Context, group sarama.ConsumerGroup, topics []string) error {
+	if !c.begin() {
+		return nil
+	}
+	defer c.running.Done()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	go func() {
+		for err := range group.Errors() {
+			log.Panicf("failure from kafka consumer group: %s", err)
+		}
+	}()
+
+	for {
+		err := group.Consume(ctx, topics, c)
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// Setup runs the OnAssigned callback before the claims of a session get consumed
+func (c *Consumer) Setup(sess sarama.ConsumerGroupSession) error {
+	log.Printf("assigned: %v, generation %d", sess.Claims(), sess.GenerationID())
+	if c.onAssigned == nil {
+		return nil
+	}
+	return c.onAssigned(sess.Claims())
+}
+
+// Cleanup runs the OnRevoked callback after every claim of a session is drained and marked,
+// sarama commits the marked offsets afterwards
+func (c *Consumer) Cleanup(sess sarama.ConsumerGroupSession) error {
+	log.Printf("revoked: %v, generation %d", sess.Claims(), sess.GenerationID())
+	if c.onRevoked == nil {
+		return nil
+	}
+	return c.onRevoked(sess.Claims())
+}
+
+// ConsumeClaim incorporates the messages of one partition. Reaching the in flight limits
+// pauses the claim, when the claim ends its messages are drained and the last one is marked.
+func (c *Consumer) ConsumeClaim(sess sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	tp := topicPartition{topic: claim.Topic(), partition: claim.Partition()}
+
+	saveOffset := time.NewTicker(maxOffsetDelay)
+	defer saveOffset.Stop()
+
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				c.release(sess, tp)
+				return nil
+			}
+			c.inFlight.admit(msg)
+			c.dispatch(msg)
+
+		case <-saveOffset.C:
+			mark, count := c.offsets.watermark(tp)
+			if count > 0 {
+				log.Printf("processed %d messages of %s/%d", count, tp.topic, tp.partition)
+				sess.MarkMessage(mark, "")
+			}
+		}
+	}
+}
+
+// release waits for the messages of a partition that ends its claim, marks the last one and stops its worker
+func (c *Consumer) release(sess sarama.ConsumerGroupSession, tp topicPartition) {
+	mark, count := c.offsets.drain(tp)
+	if count > 0 {
+		log.Printf("processed %d messages of %s/%d", count, tp.topic, tp.partition)
+		sess.MarkMessage(mark, "")
+	}
+
+	c.wmux.Lock()
+	defer c.wmux.Unlock()
+	w, ok := c.workers[tp]
+	if ok {
+		close(w)
+		delete(c.workers, tp)
+	}
+}
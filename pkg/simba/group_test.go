@@ -0,0 +1,171 @@
+package simba
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// committer keeps the committed offsets of a group like the kafka offset manager
+type committer struct {
+	mux     sync.Mutex
+	offsets map[topicPartition]int64
+}
+
+// session records the marked offsets of one member and commits them on close, like sarama after Cleanup
+type session struct {
+	committer *committer
+	claims    map[string][]int32
+	mux       sync.Mutex
+	marked    map[topicPartition]int64
+}
+
+func newSession(c *committer, claims map[string][]int32) *session {
+	return &session{committer: c, claims: claims, marked: make(map[topicPartition]int64)}
+}
+
+func (s *session) Claims() map[string][]int32 { return s.claims }
+func (s *session) MemberID() string           { return "member" }
+func (s *session) GenerationID() int32        { return 1 }
+func (s *session) Context() context.Context   { return context.Background() }
+
+func (s *session) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.marked[topicPartition{topic: topic, partition: partition}] = offset
+}
+
+func (s *session) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+	s.MarkOffset(topic, partition, offset, metadata)
+}
+
+func (s *session) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
+}
+
+func (s *session) commit() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.committer.mux.Lock()
+	defer s.committer.mux.Unlock()
+	for tp, offset := range s.marked {
+		s.committer.offsets[tp] = offset
+	}
+}
+
+type claim struct {
+	topic     string
+	partition int32
+	offset    int64
+	msgs      chan *sarama.ConsumerMessage
+}
+
+func (c *claim) Topic() string                            { return c.topic }
+func (c *claim) Partition() int32                         { return c.partition }
+func (c *claim) InitialOffset() int64                     { return c.offset }
+func (c *claim) HighWaterMarkOffset() int64               { return c.offset + int64(len(c.msgs)) }
+func (c *claim) Messages() <-chan *sarama.ConsumerMessage { return c.msgs }
+
+// newClaim queues the messages from offset to end and closes the claim, like a revoked partition
+func newClaim(topic string, partition int32, offset, end int64) *claim {
+	c := &claim{topic: topic, partition: partition, offset: offset, msgs: make(chan *sarama.ConsumerMessage, end-offset)}
+	for o := offset; o < end; o++ {
+		c.msgs <- &sarama.ConsumerMessage{Topic: topic, Partition: partition, Offset: o}
+	}
+	close(c.msgs)
+	return c
+}
+
+func TestPartitionMovesMidBatch(t *testing.T) {
+	const topic = "products"
+	tp := topicPartition{topic: topic, partition: 0}
+	claims := map[string][]int32{topic: {0}}
+	offsets := &committer{offsets: make(map[topicPartition]int64)}
+
+	var mux sync.Mutex
+	processed := map[int64]int{}
+	record := func(msgs []*sarama.ConsumerMessage) {
+		mux.Lock()
+		defer mux.Unlock()
+		for _, msg := range msgs {
+			processed[msg.Offset]++
+		}
+	}
+
+	// the first member is still incorporating a batch when its partition gets revoked
+	gate := make(chan struct{})
+	first := NewBatchConsumer(nil, func(msgs []*sarama.ConsumerMessage) error {
+		<-gate
+		record(msgs)
+		return nil
+	})
+	sess := newSession(offsets, claims)
+	revoked := int64(-1)
+	first.OnRevoked(func(partitions map[string][]int32) error {
+		sess.mux.Lock()
+		defer sess.mux.Unlock()
+		revoked = sess.marked[tp]
+		return nil
+	})
+
+	err := first.Setup(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	released := make(chan error)
+	go func() {
+		released <- first.ConsumeClaim(sess, newClaim(topic, 0, 0, 10))
+	}()
+
+	select {
+	case <-released:
+		t.Fatal("claim was released while its batch was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(gate)
+	select {
+	case err := <-released:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("claim was not released once its batch completed")
+	}
+	err = first.Cleanup(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked != 10 {
+		t.Fatalf("expected offset 10 to be marked before the partition was revoked, got %d", revoked)
+	}
+	sess.commit()
+
+	// the second member continues at the committed offset
+	second := NewBatchConsumer(nil, func(msgs []*sarama.ConsumerMessage) error {
+		record(msgs)
+		return nil
+	})
+	sess = newSession(offsets, claims)
+	err = second.Setup(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = second.ConsumeClaim(sess, newClaim(topic, 0, offsets.offsets[tp], 15))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess.commit()
+
+	if offsets.offsets[tp] != 15 {
+		t.Fatalf("expected offset 15 to be committed, got %d", offsets.offsets[tp])
+	}
+	for o := int64(0); o < 15; o++ {
+		if processed[o] != 1 {
+			t.Fatalf("offset %d was processed %d times", o, processed[o])
+		}
+	}
+}
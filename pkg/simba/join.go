@@ -0,0 +1,123 @@
+package simba
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// CheckCopartitioned makes sure the topics have the same number of partitions,
+// messages with the same key then end up in partitions with the same number
+func CheckCopartitioned(client sarama.Client, topics ...string) (int32, error) {
+	count := int32(-1)
+	for _, topic := range topics {
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list partitions of topic %s: %s", topic, err)
+		}
+		if count >= 0 && int32(len(partitions)) != count {
+			return 0, fmt.Errorf("topics %v are not co-partitioned, %s has %d partitions instead of %d", topics, topic, len(partitions), count)
+		}
+		count = int32(len(partitions))
+	}
+	return count, nil
+}
+
+// join materializes the table topic into a state store per partition
+// and incorporates the stream topic with the current row of its key
+type join struct {
+	stores *Stores
+	stream string
+	table  string
+	view   func(msg *sarama.ConsumerMessage, row []byte) error
+	mux    sync.RWMutex
+	rows   map[int32]*Store
+}
+
+// NewJoinConsumer constructs a Consumer for a stream-table join, it has to consume both topics.
+// The row passed to the view is the latest value of the message key in the table topic, nil if there is none.
+// Both topics need the same keys and partition count, the range balance strategy of sarama
+// assigns partitions with the same number to the same member. The join uses the OnAssigned and OnRevoked hooks.
+func NewJoinConsumer(stores *Stores, stream, table string, view func(msg *sarama.ConsumerMessage, row []byte) error) (*Consumer, error) {
+	partitions, err := CheckCopartitioned(stores.Client(), stream, table)
+	if err != nil {
+		return nil, err
+	}
+	err = stores.EnsureChangelog(table, partitions)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &join{
+		stores: stores,
+		stream: stream,
+		table:  table,
+		view:   view,
+		rows:   make(map[int32]*Store),
+	}
+	c := NewOrderedConsumer(nil, j.incorporate)
+	c.OnAssigned(j.assign)
+	c.OnRevoked(j.revoke)
+	return c, nil
+}
+
+func (j *join) assign(partitions map[string][]int32) error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	for _, partition := range partitions[j.table] {
+		store, err := j.stores.Open(j.table, partition)
+		if err != nil {
+			return err
+		}
+		j.rows[partition] = store
+		log.Printf("opened state of %s/%d", j.table, partition)
+	}
+	return nil
+}
+
+func (j *join) revoke(partitions map[string][]int32) error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	for _, partition := range partitions[j.table] {
+		store, ok := j.rows[partition]
+		if !ok {
+			continue
+		}
+		delete(j.rows, partition)
+		err := store.Close()
+		if err != nil {
+			return fmt.Errorf("failed to close state of %s/%d: %s", j.table, partition, err)
+		}
+	}
+	return nil
+}
+
+func (j *join) incorporate(msg *sarama.ConsumerMessage) error {
+	j.mux.RLock()
+	store, ok := j.rows[msg.Partition]
+	j.mux.RUnlock()
+	if !ok {
+		return fmt.Errorf("partition %d of table %s is not assigned with %s/%d, the topics are not co-partitioned",
+			msg.Partition, j.table, msg.Topic, msg.Partition)
+	}
+
+	switch msg.Topic {
+	case j.table:
+		if msg.Value == nil {
+			return store.Delete(msg.Key)
+		}
+		return store.Put(msg.Key, msg.Value)
+
+	case j.stream:
+		row, err := store.Get(msg.Key)
+		if err != nil {
+			return fmt.Errorf("failed to read row %s of table %s: %s", msg.Key, j.table, err)
+		}
+		return j.view(msg, row)
+	}
+	return fmt.Errorf("unexpected message of topic %s", msg.Topic)
+}
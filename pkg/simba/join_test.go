@@ -0,0 +1,92 @@
+package simba
+
+import (
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+// joined records the rows the view of a join received per stream offset
+type joined map[int64]string
+
+func newJoin(t *testing.T, k *kafka, rows joined) (*Consumer, *session) {
+	c, err := NewJoinConsumer(newStores(t, k), "orders", "products", func(msg *sarama.ConsumerMessage, row []byte) error {
+		rows[msg.Offset] = string(row)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := newSession(&committer{offsets: make(map[topicPartition]int64)}, map[string][]int32{"orders": {0}, "products": {0}})
+	err = c.Setup(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, sess
+}
+
+func incorporate(t *testing.T, c *Consumer, topic string, offset int64, key, value string) {
+	t.Helper()
+	msg := &sarama.ConsumerMessage{Topic: topic, Offset: offset, Key: []byte(key)}
+	if value != "" {
+		msg.Value = []byte(value)
+	}
+	err := c.view(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJoinFollowsTableUpdates(t *testing.T) {
+	k := newKafka(map[string]int32{"orders": 1, "products": 1, "group-products-changelog": 1})
+	rows := joined{}
+	c, sess := newJoin(t, k, rows)
+	defer c.Cleanup(sess)
+
+	incorporate(t, c, "orders", 0, "p1", "order")
+	incorporate(t, c, "products", 0, "p1", "v1")
+	incorporate(t, c, "orders", 1, "p1", "order")
+	incorporate(t, c, "products", 1, "p1", "v2")
+	incorporate(t, c, "orders", 2, "p1", "order")
+	incorporate(t, c, "products", 2, "p1", "")
+	incorporate(t, c, "orders", 3, "p1", "order")
+
+	expected := joined{0: "", 1: "v1", 2: "v2", 3: ""}
+	for offset, row := range expected {
+		got, ok := rows[offset]
+		if !ok || got != row {
+			t.Fatalf("expected order %d to join row %q, got %q", offset, row, got)
+		}
+	}
+}
+
+func TestJoinRestoresTableFromChangelog(t *testing.T) {
+	k := newKafka(map[string]int32{"orders": 1, "products": 1, "group-products-changelog": 1})
+	c, sess := newJoin(t, k, joined{})
+	incorporate(t, c, "products", 0, "p1", "v1")
+	incorporate(t, c, "products", 1, "p2", "v1")
+	incorporate(t, c, "products", 2, "p1", "v2")
+	incorporate(t, c, "products", 3, "p2", "")
+	incorporate(t, c, "products", 4, "p3", "v1")
+	err := c.Cleanup(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.compact("group-products-changelog", 0)
+
+	// the partitions moved to a member without local state
+	rows := joined{}
+	c, sess = newJoin(t, k, rows)
+	defer c.Cleanup(sess)
+	incorporate(t, c, "orders", 0, "p1", "order")
+	incorporate(t, c, "orders", 1, "p2", "order")
+	incorporate(t, c, "orders", 2, "p3", "order")
+
+	expected := joined{0: "v2", 1: "", 2: "v1"}
+	for offset, row := range expected {
+		got, ok := rows[offset]
+		if !ok || got != row {
+			t.Fatalf("expected order %d to join row %q, got %q", offset, row, got)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package simba
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+var metrics = expvar.NewMap("simba")
+
+// Limits bound the messages that are fetched but not yet incorporated into the view.
+// Consumption pauses while the next message exceeds a limit, zero messages means msgBuffer and zero bytes unlimited.
+// The queue of every partition holds up to Messages.
+type Limits struct {
+	Messages int
+	Bytes    int64
+}
+
+// DefaultLimits keep at most msgBuffer messages and 64 MiB in flight
+var DefaultLimits = Limits{
+	Messages: msgBuffer,
+	Bytes:    64 << 20,
+}
+
+// inFlight counts the messages between fetch and view
+type inFlight struct {
+	limits   Limits
+	mux      sync.Mutex
+	cond     *sync.Cond
+	messages int
+	bytes    int64
+	released chan struct{}
+	paused   time.Time
+}
+
+func newInFlight(l Limits) *inFlight {
+	if l.Messages <= 0 {
+		l.Messages = msgBuffer
+	}
+	f := &inFlight{
+		limits:   l,
+		released: make(chan struct{}, 1),
+	}
+	f.cond = sync.NewCond(&f.mux)
+	return f
+}
+
+func size(msg *sarama.ConsumerMessage) int64 {
+	return int64(len(msg.Key) + len(msg.Value))
+}
+
+// acquire adds the message if the limits allow it
+func (f *inFlight) acquire(msg *sarama.ConsumerMessage) bool {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if !f.fits(msg) {
+		return false
+	}
+	f.add(msg)
+	return true
+}
+
+// admit blocks until the limits allow the message, used by the claims of a group session
+func (f *inFlight) admit(msg *sarama.ConsumerMessage) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if !f.fits(msg) {
+		metrics.Add("pauses", 1)
+		paused := time.Now()
+		for !f.fits(msg) {
+			f.cond.Wait()
+		}
+		metrics.AddFloat("pausedSeconds", time.Since(paused).Seconds())
+	}
+	f.add(msg)
+}
+
+func (f *inFlight) add(msg *sarama.ConsumerMessage) {
+	f.messages++
+	f.bytes += size(msg)
+	metrics.Add("inFlightMessages", 1)
+	metrics.Add("inFlightBytes", size(msg))
+}
+
+func (f *inFlight) release(msg *sarama.ConsumerMessage) {
+	f.mux.Lock()
+	f.messages--
+	f.bytes -= size(msg)
+	f.cond.Broadcast()
+	f.mux.Unlock()
+	metrics.Add("inFlightMessages", -1)
+	metrics.Add("inFlightBytes", -size(msg))
+
+	select {
+	case f.released <- struct{}{}:
+	default:
+	}
+}
+
+// fits tells if the message stays within the limits, a single message is always admitted
+func (f *inFlight) fits(msg *sarama.ConsumerMessage) bool {
+	if f.messages == 0 {
+		return true
+	}
+	return f.messages < f.limits.Messages && (f.limits.Bytes <= 0 || f.bytes+size(msg) <= f.limits.Bytes)
+}
+
+// pause records when consumption stopped
+func (f *inFlight) pause() {
+	if !f.paused.IsZero() {
+		return
+	}
+	f.paused = time.Now()
+	metrics.Add("pauses", 1)
+}
+
+func (f *inFlight) resume() {
+	if f.paused.IsZero() {
+		return
+	}
+	metrics.AddFloat("pausedSeconds", time.Since(f.paused).Seconds())
+	f.paused = time.Time{}
+}
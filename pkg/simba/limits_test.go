@@ -0,0 +1,108 @@
+package simba
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// source delivers queued messages to Start
+type source struct {
+	msgs chan *sarama.ConsumerMessage
+	errs chan error
+}
+
+func (s *source) Messages() <-chan *sarama.ConsumerMessage                { return s.msgs }
+func (s *source) Errors() <-chan error                                    { return s.errs }
+func (s *source) MarkOffset(msg *sarama.ConsumerMessage, metadata string) {}
+func (s *source) Close() error                                            { return nil }
+
+func TestSlowViewStaysWithinLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		ordered bool
+		limits  Limits
+		value   int
+	}{
+		{"messages", false, Limits{Messages: 5}, 10},
+		{"bytes", false, Limits{Messages: 100, Bytes: 100}, 30},
+		{"ordered messages", true, Limits{Messages: 5}, 10},
+		{"ordered bytes", true, Limits{Messages: 100, Bytes: 100}, 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const total = 60
+			src := &source{msgs: make(chan *sarama.ConsumerMessage, total), errs: make(chan error)}
+			for i := 0; i < total; i++ {
+				src.msgs <- &sarama.ConsumerMessage{Topic: "products", Partition: int32(i % 3), Offset: int64(i / 3), Value: make([]byte, tt.value)}
+			}
+
+			var c *Consumer
+			var mux sync.Mutex
+			maxMessages, maxBytes, processed := 0, int64(0), 0
+			done := make(chan struct{})
+			view := func(msg *sarama.ConsumerMessage) error {
+				c.inFlight.mux.Lock()
+				messages, bytes := c.inFlight.messages, c.inFlight.bytes
+				c.inFlight.mux.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mux.Lock()
+				defer mux.Unlock()
+				if messages > maxMessages {
+					maxMessages = messages
+				}
+				if bytes > maxBytes {
+					maxBytes = bytes
+				}
+				processed++
+				if processed == total {
+					close(done)
+				}
+				return nil
+			}
+
+			if tt.ordered {
+				c = NewOrderedConsumer(src, view)
+			} else {
+				c = NewConsumer(src, view)
+			}
+			c.SetLimits(tt.limits)
+			go c.Start()
+			defer c.Stop()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+			}
+
+			mux.Lock()
+			defer mux.Unlock()
+			if processed != total {
+				t.Fatalf("processed %d of %d messages", processed, total)
+			}
+			if maxMessages > tt.limits.Messages {
+				t.Errorf("%d messages in flight exceed the limit of %d", maxMessages, tt.limits.Messages)
+			}
+			if tt.limits.Bytes > 0 && maxBytes > tt.limits.Bytes {
+				t.Errorf("%d bytes in flight exceed the limit of %d", maxBytes, tt.limits.Bytes)
+			}
+			if maxMessages < 2 {
+				t.Errorf("expected messages to be processed concurrently, got at most %d in flight", maxMessages)
+			}
+		})
+	}
+}
+
+func TestWorkerQueueFollowsLimits(t *testing.T) {
+	c := NewOrderedConsumer(nil, func(*sarama.ConsumerMessage) error { return nil })
+	c.SetLimits(Limits{Messages: 2 * msgBuffer})
+	w := c.worker(&sarama.ConsumerMessage{Topic: "products"})
+	if cap(w) != 2*msgBuffer {
+		t.Fatalf("expected a queue for %d messages, got %d", 2*msgBuffer, cap(w))
+	}
+}
@@ -0,0 +1,119 @@
+package simba
+
+import (
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+// partitionOffsets holds the fetched messages of a partition in offset order
+// and the offsets whose view completed
+type partitionOffsets struct {
+	pending   []*sarama.ConsumerMessage
+	completed map[int64]bool
+}
+
+// offsetTracker keeps a completion watermark per partition,
+// a slow message only holds back the commits of its own partition
+type offsetTracker struct {
+	mux        sync.Mutex
+	cond       *sync.Cond
+	partitions map[topicPartition]*partitionOffsets
+}
+
+func newOffsetTracker() *offsetTracker {
+	t := &offsetTracker{
+		partitions: make(map[topicPartition]*partitionOffsets),
+	}
+	t.cond = sync.NewCond(&t.mux)
+	return t
+}
+
+// add registers a fetched message, messages of a partition arrive in offset order
+func (t *offsetTracker) add(msg *sarama.ConsumerMessage) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	tp := topicPartition{topic: msg.Topic, partition: msg.Partition}
+	p, ok := t.partitions[tp]
+	if !ok {
+		p = &partitionOffsets{completed: make(map[int64]bool)}
+		t.partitions[tp] = p
+	}
+	p.pending = append(p.pending, msg)
+}
+
+// complete records that the view incorporated the message, in any order
+func (t *offsetTracker) complete(msg *sarama.ConsumerMessage) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	p, ok := t.partitions[topicPartition{topic: msg.Topic, partition: msg.Partition}]
+	if !ok {
+		return
+	}
+	p.completed[msg.Offset] = true
+	t.cond.Broadcast()
+}
+
+// watermarks removes the completed prefix of every partition and returns the last message
+// of each prefix, committing it never skips a message that is still in flight
+func (t *offsetTracker) watermarks() ([]*sarama.ConsumerMessage, int) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	marks := []*sarama.ConsumerMessage{}
+	count := 0
+	for _, p := range t.partitions {
+		mark, n := p.watermark()
+		if n == 0 {
+			continue
+		}
+		marks = append(marks, mark)
+		count += n
+	}
+	return marks, count
+}
+
+// watermark removes the completed prefix of one partition and returns its last message
+func (t *offsetTracker) watermark(tp topicPartition) (*sarama.ConsumerMessage, int) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	p, ok := t.partitions[tp]
+	if !ok {
+		return nil, 0
+	}
+	return p.watermark()
+}
+
+func (p *partitionOffsets) watermark() (*sarama.ConsumerMessage, int) {
+	i := 0
+	for i < len(p.pending) && p.completed[p.pending[i].Offset] {
+		delete(p.completed, p.pending[i].Offset)
+		i++
+	}
+	if i == 0 {
+		return nil, 0
+	}
+	mark := p.pending[i-1]
+	p.pending = p.pending[i:]
+	return mark, i
+}
+
+// drain waits until every fetched message of the partition completed and forgets the partition,
+// it returns the last message
+func (t *offsetTracker) drain(tp topicPartition) (*sarama.ConsumerMessage, int) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	p, ok := t.partitions[tp]
+	if !ok {
+		return nil, 0
+	}
+	for len(p.completed) < len(p.pending) {
+		t.cond.Wait()
+	}
+	delete(t.partitions, tp)
+	return p.watermark()
+}
@@ -0,0 +1,120 @@
+package simba
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func messages(topic string, partition int32, offsets ...int64) []*sarama.ConsumerMessage {
+	msgs := make([]*sarama.ConsumerMessage, len(offsets))
+	for i, offset := range offsets {
+		msgs[i] = &sarama.ConsumerMessage{Topic: topic, Partition: partition, Offset: offset}
+	}
+	return msgs
+}
+
+func TestOffsetTrackerReverseCompletion(t *testing.T) {
+	tracker := newOffsetTracker()
+	msgs := messages("products", 0, 10, 11, 12, 13)
+	for _, msg := range msgs {
+		tracker.add(msg)
+	}
+
+	for i := len(msgs) - 1; i > 0; i-- {
+		tracker.complete(msgs[i])
+		marks, count := tracker.watermarks()
+		if len(marks) != 0 || count != 0 {
+			t.Fatalf("offset %d completed before offset 10, got watermark %v of %d messages", msgs[i].Offset, marks, count)
+		}
+	}
+
+	tracker.complete(msgs[0])
+	marks, count := tracker.watermarks()
+	if len(marks) != 1 || marks[0].Offset != 13 || count != 4 {
+		t.Fatalf("expected watermark at offset 13 for 4 messages, got %v of %d messages", marks, count)
+	}
+
+	marks, count = tracker.watermarks()
+	if len(marks) != 0 || count != 0 {
+		t.Fatalf("expected no watermark once the prefix was removed, got %v of %d messages", marks, count)
+	}
+}
+
+func TestOffsetTrackerStalledPartition(t *testing.T) {
+	tracker := newOffsetTracker()
+	stalled := messages("products", 0, 0, 1, 2)
+	moving := messages("products", 1, 0, 1, 2)
+	for i := range stalled {
+		tracker.add(stalled[i])
+		tracker.add(moving[i])
+	}
+
+	// the first message of partition 0 is still in flight
+	for _, msg := range stalled[1:] {
+		tracker.complete(msg)
+	}
+	for _, msg := range moving {
+		tracker.complete(msg)
+	}
+
+	marks, count := tracker.watermarks()
+	if len(marks) != 1 || marks[0].Partition != 1 || marks[0].Offset != 2 || count != 3 {
+		t.Fatalf("expected only partition 1 to advance to offset 2, got %v of %d messages", marks, count)
+	}
+
+	mark, count := tracker.watermark(topicPartition{topic: "products", partition: 0})
+	if mark != nil || count != 0 {
+		t.Fatalf("expected partition 0 to be held back, got %v of %d messages", mark, count)
+	}
+
+	tracker.complete(stalled[0])
+	mark, count = tracker.watermark(topicPartition{topic: "products", partition: 0})
+	if mark == nil || mark.Offset != 2 || count != 3 {
+		t.Fatalf("expected partition 0 to advance to offset 2 for 3 messages, got %v of %d messages", mark, count)
+	}
+}
+
+func TestOffsetTrackerDrain(t *testing.T) {
+	tracker := newOffsetTracker()
+	tp := topicPartition{topic: "products", partition: 0}
+	msgs := messages("products", 0, 5, 6, 7)
+	for _, msg := range msgs {
+		tracker.add(msg)
+	}
+	tracker.complete(msgs[0])
+	tracker.complete(msgs[2])
+
+	type drained struct {
+		mark  *sarama.ConsumerMessage
+		count int
+	}
+	done := make(chan drained)
+	go func() {
+		mark, count := tracker.drain(tp)
+		done <- drained{mark, count}
+	}()
+
+	select {
+	case d := <-done:
+		t.Fatalf("drain returned offset %v while offset 6 was in flight", d.mark)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	tracker.complete(msgs[1])
+
+	select {
+	case d := <-done:
+		if d.mark == nil || d.mark.Offset != 7 || d.count != 3 {
+			t.Fatalf("expected drain to return offset 7 for 3 messages, got %v of %d messages", d.mark, d.count)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("drain did not return once all messages completed")
+	}
+
+	mark, count := tracker.watermark(tp)
+	if mark != nil || count != 0 {
+		t.Fatalf("expected the drained partition to be forgotten, got %v of %d messages", mark, count)
+	}
+}
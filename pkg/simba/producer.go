@@ -0,0 +1,301 @@
+package simba
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+const txnBatchSize = 500
+const txnTimeout = 5 * time.Minute
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// TransactionalProducer publishes messages idempotently inside of one kafka transaction
+type TransactionalProducer struct {
+	client        sarama.Client
+	config        *sarama.Config
+	id            string
+	partitioner   sarama.Partitioner
+	coordinator   *sarama.Broker
+	producerID    int64
+	producerEpoch int16
+	inTxn         bool
+	partitions    map[topicPartition]bool
+	sequences     map[topicPartition]int32
+	batches       map[topicPartition]*sarama.RecordBatch
+}
+
+// NewTransactionalProducer registers the transactional id with its coordinator.
+// Any unfinished transaction of a previous producer with the same id gets aborted.
+func NewTransactionalProducer(client sarama.Client, transactionalID string) (*TransactionalProducer, error) {
+	config := client.Config()
+	if !config.Version.IsAtLeast(sarama.V0_11_0_0) {
+		return nil, fmt.Errorf("transactions require kafka version 0.11 or later, configured is %s", config.Version)
+	}
+
+	p := &TransactionalProducer{
+		client:      client,
+		config:      config,
+		id:          transactionalID,
+		partitioner: sarama.NewHashPartitioner(""),
+		partitions:  make(map[topicPartition]bool),
+		sequences:   make(map[topicPartition]int32),
+		batches:     make(map[topicPartition]*sarama.RecordBatch),
+	}
+
+	coordinator, err := p.findCoordinator()
+	if err != nil {
+		return nil, err
+	}
+	p.coordinator = coordinator
+
+	res, err := coordinator.InitProducerID(&sarama.InitProducerIDRequest{
+		TransactionalID:    &p.id,
+		TransactionTimeout: txnTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to init producer id: %s", err)
+	}
+	if res.Err != sarama.ErrNoError {
+		return nil, fmt.Errorf("failed to init producer id: %s", res.Err)
+	}
+	p.producerID = res.ProducerID
+	p.producerEpoch = res.ProducerEpoch
+
+	return p, nil
+}
+
+func (p *TransactionalProducer) findCoordinator() (*sarama.Broker, error) {
+	for _, broker := range p.client.Brokers() {
+		if err := openBroker(broker, p.config); err != nil {
+			continue
+		}
+		// version 0 knows only group coordinators
+		res, err := broker.FindCoordinator(&sarama.FindCoordinatorRequest{
+			Version:         1,
+			CoordinatorKey:  p.id,
+			CoordinatorType: sarama.CoordinatorTransaction,
+		})
+		if err != nil {
+			continue
+		}
+		if res.Err != sarama.ErrNoError {
+			return nil, fmt.Errorf("failed to find transaction coordinator: %s", res.Err)
+		}
+		if err := openBroker(res.Coordinator, p.config); err != nil {
+			return nil, fmt.Errorf("failed to connect to transaction coordinator: %s", err)
+		}
+		return res.Coordinator, nil
+	}
+	return nil, fmt.Errorf("failed to find transaction coordinator: no broker available")
+}
+
+func openBroker(broker *sarama.Broker, config *sarama.Config) error {
+	err := broker.Open(config)
+	if err != nil && err != sarama.ErrAlreadyConnected {
+		return err
+	}
+	_, err = broker.Connected()
+	return err
+}
+
+// Begin starts a new transaction
+func (p *TransactionalProducer) Begin() error {
+	if p.inTxn {
+		return fmt.Errorf("transaction already in progress")
+	}
+	p.inTxn = true
+	p.partitions = make(map[topicPartition]bool)
+	return nil
+}
+
+// Send adds the message to the current transaction
+func (p *TransactionalProducer) Send(msg *sarama.ProducerMessage) error {
+	if !p.inTxn {
+		return fmt.Errorf("no transaction in progress")
+	}
+
+	tp, err := p.partition(msg)
+	if err != nil {
+		return err
+	}
+
+	if !p.partitions[tp] {
+		err := p.addPartition(tp)
+		if err != nil {
+			return err
+		}
+	}
+
+	var key, value []byte
+	if msg.Key != nil {
+		if key, err = msg.Key.Encode(); err != nil {
+			return fmt.Errorf("failed to encode key: %s", err)
+		}
+	}
+	if msg.Value != nil {
+		if value, err = msg.Value.Encode(); err != nil {
+			return fmt.Errorf("failed to encode value: %s", err)
+		}
+	}
+
+	timestamp := msg.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	batch, ok := p.batches[tp]
+	if !ok {
+		batch = &sarama.RecordBatch{
+			Version:         2,
+			FirstTimestamp:  timestamp,
+			MaxTimestamp:    timestamp,
+			ProducerID:      p.producerID,
+			ProducerEpoch:   p.producerEpoch,
+			FirstSequence:   p.sequences[tp],
+			IsTransactional: true,
+		}
+		p.batches[tp] = batch
+	}
+	if timestamp.After(batch.MaxTimestamp) {
+		batch.MaxTimestamp = timestamp
+	}
+	headers := make([]*sarama.RecordHeader, len(msg.Headers))
+	for i := range msg.Headers {
+		headers[i] = &msg.Headers[i]
+	}
+	batch.Records = append(batch.Records, &sarama.Record{
+		Key:            key,
+		Value:          value,
+		Headers:        headers,
+		TimestampDelta: timestamp.Sub(batch.FirstTimestamp),
+		OffsetDelta:    int64(len(batch.Records)),
+	})
+	batch.LastOffsetDelta = int32(len(batch.Records) - 1)
+
+	if len(batch.Records) >= txnBatchSize {
+		return p.flush(tp)
+	}
+	return nil
+}
+
+func (p *TransactionalProducer) partition(msg *sarama.ProducerMessage) (topicPartition, error) {
+	partitions, err := p.client.Partitions(msg.Topic)
+	if err != nil {
+		return topicPartition{}, fmt.Errorf("failed to list partitions of topic %s: %s", msg.Topic, err)
+	}
+	choice, err := p.partitioner.Partition(msg, int32(len(partitions)))
+	if err != nil {
+		return topicPartition{}, fmt.Errorf("failed to partition message: %s", err)
+	}
+	return topicPartition{topic: msg.Topic, partition: partitions[choice]}, nil
+}
+
+func (p *TransactionalProducer) addPartition(tp topicPartition) error {
+	res, err := p.coordinator.AddPartitionsToTxn(&sarama.AddPartitionsToTxnRequest{
+		TransactionalID: p.id,
+		ProducerID:      p.producerID,
+		ProducerEpoch:   p.producerEpoch,
+		TopicPartitions: map[string][]int32{tp.topic: {tp.partition}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add partition %s/%d to transaction: %s", tp.topic, tp.partition, err)
+	}
+	for _, errs := range res.Errors {
+		for _, e := range errs {
+			if e.Err != sarama.ErrNoError {
+				return fmt.Errorf("failed to add partition %s/%d to transaction: %s", tp.topic, e.Partition, e.Err)
+			}
+		}
+	}
+	p.partitions[tp] = true
+	return nil
+}
+
+func (p *TransactionalProducer) flush(tp topicPartition) error {
+	batch, ok := p.batches[tp]
+	if !ok {
+		return nil
+	}
+	delete(p.batches, tp)
+
+	leader, err := p.client.Leader(tp.topic, tp.partition)
+	if err != nil {
+		return fmt.Errorf("failed to find leader for %s/%d: %s", tp.topic, tp.partition, err)
+	}
+
+	req := &sarama.ProduceRequest{
+		TransactionalID: &p.id,
+		RequiredAcks:    sarama.WaitForAll,
+		Timeout:         int32(p.config.Producer.Timeout / time.Millisecond),
+		Version:         3,
+	}
+	req.AddBatch(tp.topic, tp.partition, batch)
+
+	res, err := leader.Produce(req)
+	if err != nil {
+		return fmt.Errorf("failed to produce to %s/%d: %s", tp.topic, tp.partition, err)
+	}
+	block := res.GetBlock(tp.topic, tp.partition)
+	if block == nil {
+		return fmt.Errorf("missing produce response for %s/%d", tp.topic, tp.partition)
+	}
+	if block.Err != sarama.ErrNoError {
+		return fmt.Errorf("failed to produce to %s/%d: %s", tp.topic, tp.partition, block.Err)
+	}
+
+	p.sequences[tp] += int32(len(batch.Records))
+	return nil
+}
+
+// Commit flushes all buffered messages and makes the transaction visible to read_committed consumers
+func (p *TransactionalProducer) Commit() error {
+	for tp := range p.batches {
+		err := p.flush(tp)
+		if err != nil {
+			return err
+		}
+	}
+	return p.end(true)
+}
+
+// Abort discards all messages of the current transaction
+func (p *TransactionalProducer) Abort() error {
+	p.batches = make(map[topicPartition]*sarama.RecordBatch)
+	return p.end(false)
+}
+
+func (p *TransactionalProducer) end(commit bool) error {
+	if !p.inTxn {
+		return fmt.Errorf("no transaction in progress")
+	}
+	p.inTxn = false
+
+	if len(p.partitions) == 0 {
+		return nil
+	}
+
+	res, err := p.coordinator.EndTxn(&sarama.EndTxnRequest{
+		TransactionalID:   p.id,
+		ProducerID:        p.producerID,
+		ProducerEpoch:     p.producerEpoch,
+		TransactionResult: commit,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to end transaction: %s", err)
+	}
+	if res.Err != sarama.ErrNoError {
+		return fmt.Errorf("failed to end transaction: %s", res.Err)
+	}
+	return nil
+}
+
+// Close releases the connection to the kafka cluster
+func (p *TransactionalProducer) Close() error {
+	return p.client.Close()
+}
@@ -0,0 +1,248 @@
+package simba
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// newMockProducer starts a broker that leads the products topic and coordinates the transactions
+func newMockProducer(t *testing.T) (*TransactionalProducer, *sarama.MockBroker) {
+	broker := sarama.NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("products", 0, broker.BrokerID()),
+		// the mock answers find coordinator in version 0 only
+		"FindCoordinatorRequest": sarama.NewMockWrapper(&sarama.FindCoordinatorResponse{
+			Version:     1,
+			Coordinator: sarama.NewBroker(broker.Addr()),
+		}),
+		"InitProducerIDRequest":     sarama.NewMockWrapper(&sarama.InitProducerIDResponse{ProducerID: 7, ProducerEpoch: 1}),
+		"AddPartitionsToTxnRequest": sarama.NewMockWrapper(&sarama.AddPartitionsToTxnResponse{}),
+		"ProduceRequest":            sarama.NewMockProduceResponse(t).SetVersion(3),
+		"EndTxnRequest":             sarama.NewMockWrapper(&sarama.EndTxnResponse{}),
+	})
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	client, err := sarama.NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewTransactionalProducer(client, "import")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p, broker
+}
+
+func send(t *testing.T, p *TransactionalProducer, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		err := p.Send(&sarama.ProducerMessage{
+			Topic:     "products",
+			Key:       sarama.StringEncoder(fmt.Sprintf("key-%d", i)),
+			Value:     sarama.StringEncoder(fmt.Sprintf("value-%d", i)),
+			Headers:   []sarama.RecordHeader{{Key: []byte("tenant"), Value: []byte("shop-a")}},
+			Timestamp: time.Unix(int64(i), 0),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// endings returns the results of the EndTxn requests the broker received, true for commits
+func endings(broker *sarama.MockBroker) []bool {
+	results := []bool{}
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.EndTxnRequest); ok {
+			results = append(results, req.TransactionResult)
+		}
+	}
+	return results
+}
+
+func TestTransactionalProducerBatch(t *testing.T) {
+	p, broker := newMockProducer(t)
+	defer broker.Close()
+	defer p.Close()
+	tp := topicPartition{topic: "products", partition: 0}
+
+	err := p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(t, p, 3)
+
+	batch := p.batches[tp]
+	if batch == nil || len(batch.Records) != 3 {
+		t.Fatalf("expected a batch of 3 records, got %+v", batch)
+	}
+	if batch.ProducerID != 7 || batch.ProducerEpoch != 1 || !batch.IsTransactional || batch.FirstSequence != 0 {
+		t.Fatalf("expected a transactional batch of producer 7/1 from sequence 0, got %+v", batch)
+	}
+	if batch.LastOffsetDelta != 2 || !batch.MaxTimestamp.Equal(time.Unix(2, 0)) {
+		t.Fatalf("expected the last offset delta 2 and the max timestamp of the last record, got %d and %s", batch.LastOffsetDelta, batch.MaxTimestamp)
+	}
+	for i, r := range batch.Records {
+		if r.OffsetDelta != int64(i) || r.TimestampDelta != time.Duration(i)*time.Second {
+			t.Fatalf("record %d has offset delta %d and timestamp delta %s", i, r.OffsetDelta, r.TimestampDelta)
+		}
+		if string(r.Key) != fmt.Sprintf("key-%d", i) || string(r.Value) != fmt.Sprintf("value-%d", i) {
+			t.Fatalf("record %d has key %s and value %s", i, r.Key, r.Value)
+		}
+		if len(r.Headers) != 1 || string(r.Headers[0].Value) != "shop-a" {
+			t.Fatalf("record %d has headers %v", i, r.Headers)
+		}
+	}
+
+	err = p.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.batches) != 0 || p.sequences[tp] != 3 {
+		t.Fatalf("expected the commit to flush 3 records, %d batches left at sequence %d", len(p.batches), p.sequences[tp])
+	}
+	if e := endings(broker); len(e) != 1 || !e[0] {
+		t.Fatalf("expected one committed transaction, got %v", e)
+	}
+}
+
+func TestTransactionalProducerSequences(t *testing.T) {
+	p, broker := newMockProducer(t)
+	defer broker.Close()
+	defer p.Close()
+	tp := topicPartition{topic: "products", partition: 0}
+
+	err := p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(t, p, txnBatchSize+2)
+
+	// a full batch is flushed while sending, the next one continues its sequence
+	if p.sequences[tp] != txnBatchSize {
+		t.Fatalf("expected sequence %d after a full batch, got %d", txnBatchSize, p.sequences[tp])
+	}
+	batch := p.batches[tp]
+	if batch == nil || batch.FirstSequence != txnBatchSize || len(batch.Records) != 2 {
+		t.Fatalf("expected a batch of 2 records from sequence %d, got %+v", txnBatchSize, batch)
+	}
+
+	// aborting drops the buffered records, the flushed ones keep their sequence numbers
+	err = p.Abort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.batches) != 0 || p.sequences[tp] != txnBatchSize {
+		t.Fatalf("expected no batches at sequence %d after the abort, got %d batches at sequence %d", txnBatchSize, len(p.batches), p.sequences[tp])
+	}
+
+	err = p.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(t, p, 1)
+	if p.batches[tp].FirstSequence != txnBatchSize {
+		t.Fatalf("expected the next transaction to continue at sequence %d, got %d", txnBatchSize, p.batches[tp].FirstSequence)
+	}
+	err = p.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.sequences[tp] != txnBatchSize+1 {
+		t.Fatalf("expected sequence %d, got %d", txnBatchSize+1, p.sequences[tp])
+	}
+
+	if e := endings(broker); len(e) != 2 || e[0] || !e[1] {
+		t.Fatalf("expected an aborted and a committed transaction, got %v", e)
+	}
+}
+
+// TestTransactionalProducerAbortOnBroker needs a kafka broker at KAFKA_BROKERS,
+// only the committed transaction is visible to read committed consumers
+func TestTransactionalProducerAbortOnBroker(t *testing.T) {
+	brokers := os.Getenv("KAFKA_BROKERS")
+	if brokers == "" {
+		t.Skip("KAFKA_BROKERS is not set")
+	}
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
+	client, err := sarama.NewClient(strings.Split(brokers, ","), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	topic := fmt.Sprintf("simba-transactions-%d", time.Now().UnixNano())
+	admin, err := sarama.NewClusterAdmin(strings.Split(brokers, ","), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	err = admin.CreateTopic(topic, &sarama.TopicDetail{NumPartitions: 1, ReplicationFactor: 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.DeleteTopic(topic)
+	err = client.RefreshMetadata(topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewTransactionalProducer(client, topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for _, commit := range []bool{false, true} {
+		err = p.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			err = p.Send(&sarama.ProducerMessage{Topic: topic, Key: sarama.StringEncoder("key"), Value: sarama.StringEncoder(fmt.Sprint(commit))})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if commit {
+			err = p.Commit()
+		} else {
+			err = p.Abort()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer consumer.Close()
+	pc, err := consumer.ConsumePartition(topic, 0, sarama.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case msg := <-pc.Messages():
+			if string(msg.Value) != "true" {
+				t.Fatalf("read a message of the aborted transaction at offset %d", msg.Offset)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("read %d of 3 committed messages", i)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package simba
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Shopify/sarama"
+	bolt "go.etcd.io/bbolt"
+)
+
+const restoreBatch = 1000
+
+var (
+	stateBucket     = []byte("state")
+	metaBucket      = []byte("meta")
+	changelogOffset = []byte("changelogOffset")
+)
+
+// Stores keeps the local state of a consumer group, one bbolt file per store and partition.
+// Every write goes to a compacted changelog topic first, a new owner of the partition restores from it.
+type Stores struct {
+	brokers           []string
+	config            *sarama.Config
+	dir               string
+	group             string
+	client            sarama.Client
+	producer          sarama.SyncProducer
+	consumer          sarama.Consumer
+	replicationFactor int16
+}
+
+// NewStores connects to kafka, the state files are kept in dir
+func NewStores(brokers []string, dir, group string, replicationFactor int16) (*Stores, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Return.Successes = true
+	config.Producer.Partitioner = sarama.NewManualPartitioner
+	client, err := sarama.NewClient(brokers, config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to kafka: %s", err)
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to setup changelog producer: %s", err)
+	}
+	consumer, err := sarama.NewConsumerFromClient(client)
+	if err != nil {
+		producer.Close()
+		client.Close()
+		return nil, fmt.Errorf("failed to setup changelog consumer: %s", err)
+	}
+	err = os.MkdirAll(filepath.Join(dir, group), 0755)
+	if err != nil {
+		consumer.Close()
+		producer.Close()
+		client.Close()
+		return nil, fmt.Errorf("failed to create state directory: %s", err)
+	}
+	return &Stores{
+		brokers:           brokers,
+		config:            config,
+		dir:               dir,
+		group:             group,
+		client:            client,
+		producer:          producer,
+		consumer:          consumer,
+		replicationFactor: replicationFactor,
+	}, nil
+}
+
+// Close closes the kafka connections, the stores have to be closed before
+func (s *Stores) Close() error {
+	err := s.consumer.Close()
+	if err != nil {
+		s.producer.Close()
+		s.client.Close()
+		return err
+	}
+	err = s.producer.Close()
+	if err != nil {
+		s.client.Close()
+		return err
+	}
+	return s.client.Close()
+}
+
+// Client returns the kafka client of the stores
+func (s *Stores) Client() sarama.Client {
+	return s.client
+}
+
+func (s *Stores) changelog(name string) string {
+	return fmt.Sprintf("%s-%s-changelog", s.group, name)
+}
+
+// EnsureChangelog creates the compacted changelog topic of a store,
+// it needs as many partitions as the topics the store is built from
+func (s *Stores) EnsureChangelog(name string, partitions int32) error {
+	topic := s.changelog(name)
+
+	existing, err := s.client.Partitions(topic)
+	if err == nil {
+		if int32(len(existing)) != partitions {
+			return fmt.Errorf("changelog %s has %d partitions, expected %d", topic, len(existing), partitions)
+		}
+		return nil
+	}
+	if err != sarama.ErrUnknownTopicOrPartition {
+		return fmt.Errorf("failed to list partitions of changelog %s: %s", topic, err)
+	}
+
+	admin, err := sarama.NewClusterAdmin(s.brokers, s.config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to kafka: %s", err)
+	}
+	defer admin.Close()
+	compact := "compact"
+	err = admin.CreateTopic(topic, &sarama.TopicDetail{
+		NumPartitions:     partitions,
+		ReplicationFactor: s.replicationFactor,
+		ConfigEntries:     map[string]*string{"cleanup.policy": &compact},
+	}, false)
+	if e, ok := err.(*sarama.TopicError); ok && e.Err == sarama.ErrTopicAlreadyExists {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create changelog %s: %s", topic, err)
+	}
+	return s.client.RefreshMetadata(topic)
+}
+
+// Open opens the store of a partition and restores the changelog entries it misses
+func (s *Stores) Open(name string, partition int32) (*Store, error) {
+	path := filepath.Join(s.dir, s.group, fmt.Sprintf("%s-%d.db", name, partition))
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state %s: %s", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{stateBucket, metaBucket} {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets of state %s: %s", path, err)
+	}
+
+	store := &Store{
+		db:        db,
+		changelog: s.changelog(name),
+		partition: partition,
+		producer:  s.producer,
+	}
+	err = store.restore(s.client, s.consumer)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to restore state %s from %s/%d: %s", path, store.changelog, partition, err)
+	}
+	return store, nil
+}
+
+// Store is the local state of one partition
+type Store struct {
+	db        *bolt.DB
+	changelog string
+	partition int32
+	producer  sarama.SyncProducer
+	mux       sync.Mutex
+}
+
+// Get returns the value of a key, nil if it is missing
+func (s *Store) Get(key []byte) ([]byte, error) {
+	var value []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(stateBucket).Get(key)
+		if v != nil {
+			value = append([]byte{}, v...)
+		}
+		return nil
+	})
+	return value, err
+}
+
+// ForEach calls f for every key in order, f must not write to the store
+func (s *Store) ForEach(f func(key, value []byte) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(stateBucket).ForEach(f)
+	})
+}
+
+// Put writes the value to the changelog and then to the local file
+func (s *Store) Put(key, value []byte) error {
+	return s.write(key, value)
+}
+
+// Delete writes a tombstone to the changelog and removes the key from the local file
+func (s *Store) Delete(key []byte) error {
+	return s.write(key, nil)
+}
+
+// write keeps changelog and local file in the same order, writes of a partition are serialized
+func (s *Store) write(key, value []byte) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	msg := &sarama.ProducerMessage{
+		Topic:     s.changelog,
+		Partition: s.partition,
+		Key:       sarama.ByteEncoder(key),
+	}
+	if value != nil {
+		msg.Value = sarama.ByteEncoder(value)
+	}
+	_, offset, err := s.producer.SendMessage(msg)
+	if err != nil {
+		return fmt.Errorf("failed to write changelog %s/%d: %s", s.changelog, s.partition, err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := apply(tx, key, value)
+		if err != nil {
+			return err
+		}
+		return setNextOffset(tx, offset+1)
+	})
+}
+
+// Close closes the local file
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func apply(tx *bolt.Tx, key, value []byte) error {
+	if value == nil {
+		return tx.Bucket(stateBucket).Delete(key)
+	}
+	return tx.Bucket(stateBucket).Put(key, value)
+}
+
+func setNextOffset(tx *bolt.Tx, offset int64) error {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(offset))
+	return tx.Bucket(metaBucket).Put(changelogOffset, b)
+}
+
+func (s *Store) nextOffset() (int64, error) {
+	offset := int64(-1)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(metaBucket).Get(changelogOffset)
+		if b != nil {
+			offset = int64(binary.BigEndian.Uint64(b))
+		}
+		return nil
+	})
+	return offset, err
+}
+
+// restore applies the changelog from the offset the local file stopped at,
+// a file that does not match the changelog is rebuilt from scratch
+func (s *Store) restore(client sarama.Client, consumer sarama.Consumer) error {
+	newest, err := client.GetOffset(s.changelog, s.partition, sarama.OffsetNewest)
+	if err != nil {
+		return err
+	}
+	oldest, err := client.GetOffset(s.changelog, s.partition, sarama.OffsetOldest)
+	if err != nil {
+		return err
+	}
+	next, err := s.nextOffset()
+	if err != nil {
+		return err
+	}
+
+	if next < oldest || next > newest {
+		err = s.db.Update(func(tx *bolt.Tx) error {
+			err := tx.DeleteBucket(stateBucket)
+			if err != nil {
+				return err
+			}
+			_, err = tx.CreateBucket(stateBucket)
+			if err != nil {
+				return err
+			}
+			return setNextOffset(tx, oldest)
+		})
+		if err != nil {
+			return err
+		}
+		next = oldest
+	}
+	if next >= newest {
+		return nil
+	}
+
+	pc, err := consumer.ConsumePartition(s.changelog, s.partition, next)
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+
+	batch := []*sarama.ConsumerMessage{}
+	for msg := range pc.Messages() {
+		batch = append(batch, msg)
+		last := msg.Offset+1 >= newest
+		if len(batch) < restoreBatch && !last {
+			continue
+		}
+		err = s.db.Update(func(tx *bolt.Tx) error {
+			for _, m := range batch {
+				err := apply(tx, m.Key, m.Value)
+				if err != nil {
+					return err
+				}
+			}
+			return setNextOffset(tx, batch[len(batch)-1].Offset+1)
+		})
+		if err != nil {
+			return err
+		}
+		batch = batch[:0]
+		if last {
+			return nil
+		}
+	}
+	return fmt.Errorf("changelog ended before offset %d", newest)
+}
@@ -0,0 +1,246 @@
+package simba
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Shopify/sarama"
+)
+
+// kafka keeps the partitions of the topics in memory, the embedded sarama interfaces
+// panic on the calls the stores do not make
+type kafka struct {
+	sarama.Client
+	mux    sync.Mutex
+	topics map[string]int32
+	logs   map[topicPartition][]*sarama.ConsumerMessage
+	next   map[topicPartition]int64
+}
+
+func newKafka(topics map[string]int32) *kafka {
+	return &kafka{
+		topics: topics,
+		logs:   make(map[topicPartition][]*sarama.ConsumerMessage),
+		next:   make(map[topicPartition]int64),
+	}
+}
+
+func (k *kafka) Partitions(topic string) ([]int32, error) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	count, ok := k.topics[topic]
+	if !ok {
+		return nil, sarama.ErrUnknownTopicOrPartition
+	}
+	partitions := make([]int32, count)
+	for i := range partitions {
+		partitions[i] = int32(i)
+	}
+	return partitions, nil
+}
+
+func (k *kafka) GetOffset(topic string, partition int32, time int64) (int64, error) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	tp := topicPartition{topic: topic, partition: partition}
+	if time == sarama.OffsetOldest && len(k.logs[tp]) > 0 {
+		return k.logs[tp][0].Offset, nil
+	}
+	return k.next[tp], nil
+}
+
+func (k *kafka) append(topic string, partition int32, key, value []byte) int64 {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	tp := topicPartition{topic: topic, partition: partition}
+	offset := k.next[tp]
+	k.logs[tp] = append(k.logs[tp], &sarama.ConsumerMessage{Topic: topic, Partition: partition, Offset: offset, Key: key, Value: value})
+	k.next[tp] = offset + 1
+	return offset
+}
+
+// compact keeps the latest message of every key and drops the tombstones, like the log cleaner once they expired.
+// The last message is in the active segment, it is never cleaned.
+func (k *kafka) compact(topic string, partition int32) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	tp := topicPartition{topic: topic, partition: partition}
+	latest := map[string]int64{}
+	for _, msg := range k.logs[tp] {
+		latest[string(msg.Key)] = msg.Offset
+	}
+	compacted := []*sarama.ConsumerMessage{}
+	for i, msg := range k.logs[tp] {
+		if i == len(k.logs[tp])-1 || latest[string(msg.Key)] == msg.Offset && msg.Value != nil {
+			compacted = append(compacted, msg)
+		}
+	}
+	k.logs[tp] = compacted
+}
+
+func (k *kafka) keys(topic string, partition int32) int {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	return len(k.logs[topicPartition{topic: topic, partition: partition}])
+}
+
+type producer struct {
+	sarama.SyncProducer
+	kafka *kafka
+}
+
+func (p *producer) SendMessage(msg *sarama.ProducerMessage) (int32, int64, error) {
+	key, err := msg.Key.Encode()
+	if err != nil {
+		return 0, 0, err
+	}
+	var value []byte
+	if msg.Value != nil {
+		value, err = msg.Value.Encode()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	return msg.Partition, p.kafka.append(msg.Topic, msg.Partition, key, value), nil
+}
+
+func (p *producer) Close() error { return nil }
+
+type consumer struct {
+	sarama.Consumer
+	kafka *kafka
+}
+
+func (c *consumer) ConsumePartition(topic string, partition int32, offset int64) (sarama.PartitionConsumer, error) {
+	c.kafka.mux.Lock()
+	defer c.kafka.mux.Unlock()
+	log := c.kafka.logs[topicPartition{topic: topic, partition: partition}]
+	pc := &partitionConsumer{msgs: make(chan *sarama.ConsumerMessage, len(log))}
+	for _, msg := range log {
+		if msg.Offset >= offset {
+			pc.msgs <- msg
+		}
+	}
+	return pc, nil
+}
+
+func (c *consumer) Close() error { return nil }
+
+type partitionConsumer struct {
+	sarama.PartitionConsumer
+	msgs chan *sarama.ConsumerMessage
+}
+
+func (pc *partitionConsumer) Messages() <-chan *sarama.ConsumerMessage { return pc.msgs }
+func (pc *partitionConsumer) Close() error                             { return nil }
+
+// newStores keeps the state files in a temporary directory, a new directory is a new owner of the partitions
+func newStores(t *testing.T, k *kafka) *Stores {
+	dir, err := ioutil.TempDir("", "simba-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	err = os.MkdirAll(filepath.Join(dir, "group"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Stores{
+		dir:      dir,
+		group:    "group",
+		client:   k,
+		producer: &producer{kafka: k},
+		consumer: &consumer{kafka: k},
+	}
+}
+
+func expectValue(t *testing.T, s *Store, key, expected string) {
+	t.Helper()
+	value, err := s.Get([]byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected == "" && value != nil {
+		t.Fatalf("expected %s to be missing, got %s", key, value)
+	}
+	if expected != "" && !bytes.Equal(value, []byte(expected)) {
+		t.Fatalf("expected %s to be %s, got %s", key, expected, value)
+	}
+}
+
+func TestStoreRestoreFromCompactedChangelog(t *testing.T) {
+	k := newKafka(map[string]int32{"group-state-changelog": 1})
+	owner, err := newStores(t, k).Open("state", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range []struct{ key, value string }{{"a", "1"}, {"b", "2"}, {"a", "3"}, {"b", ""}, {"c", "4"}} {
+		if w.value == "" {
+			err = owner.Delete([]byte(w.key))
+		} else {
+			err = owner.Put([]byte(w.key), []byte(w.value))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = owner.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k.compact("group-state-changelog", 0)
+	if k.keys("group-state-changelog", 0) != 2 {
+		t.Fatalf("expected the compacted changelog to keep 2 keys, got %d", k.keys("group-state-changelog", 0))
+	}
+
+	restored, err := newStores(t, k).Open("state", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	expectValue(t, restored, "a", "3")
+	expectValue(t, restored, "b", "")
+	expectValue(t, restored, "c", "4")
+
+	next, err := restored.nextOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 5 {
+		t.Fatalf("expected to continue at changelog offset 5, got %d", next)
+	}
+}
+
+func TestStoreRestoreMissedEntries(t *testing.T) {
+	k := newKafka(map[string]int32{"group-state-changelog": 1})
+	stores := newStores(t, k)
+	s, err := stores.Open("state", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Put([]byte("a"), []byte("1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another owner wrote while the partition was away
+	k.append("group-state-changelog", 0, []byte("a"), nil)
+	k.append("group-state-changelog", 0, []byte("b"), []byte("2"))
+
+	s, err = stores.Open("state", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	expectValue(t, s, "a", "")
+	expectValue(t, s, "b", "2")
+}
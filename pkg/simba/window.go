@@ -0,0 +1,467 @@
+package simba
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// Window is the time range [Start, End) of an aggregate, session windows end with their last event
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Windows decide which windows an event time belongs to
+type Windows struct {
+	size    time.Duration
+	advance time.Duration
+	gap     time.Duration
+}
+
+// TumblingWindows are adjacent windows of a fixed size
+func TumblingWindows(size time.Duration) Windows {
+	return Windows{size: size, advance: size}
+}
+
+// HoppingWindows have a fixed size and start every advance, they overlap if advance is smaller than size
+func HoppingWindows(size, advance time.Duration) Windows {
+	return Windows{size: size, advance: advance}
+}
+
+// SessionWindows grow with every event of a key and close after a gap without events
+func SessionWindows(gap time.Duration) Windows {
+	return Windows{gap: gap}
+}
+
+func (w Windows) session() bool {
+	return w.gap > 0
+}
+
+// assign returns the fixed windows that contain t in start order,
+// the last one starts at the latest multiple of advance at or before t
+func (w Windows) assign(t time.Time) []Window {
+	ts := t.UnixNano()
+	size, advance := int64(w.size), int64(w.advance)
+	starts := []int64{}
+	for start := ts - ts%advance; start > ts-size; start -= advance {
+		starts = append(starts, start)
+	}
+	windows := make([]Window, len(starts))
+	for i, start := range starts {
+		windows[len(starts)-1-i] = Window{Start: time.Unix(0, start), End: time.Unix(0, start+size)}
+	}
+	return windows
+}
+
+// closes returns when a window is final, late events are dropped after its grace period
+func (w Windows) closes(window Window, grace time.Duration) time.Time {
+	return window.End.Add(w.gap + grace)
+}
+
+// Aggregation folds the messages of a topic into windows per key
+type Aggregation struct {
+	// Name names the state store and its changelog
+	Name    string
+	Windows Windows
+	// Grace is how long a window accepts late events after it ended, in event time
+	Grace time.Duration
+	// Key groups the messages, messages with an empty key are skipped
+	Key func(msg *sarama.ConsumerMessage) (string, error)
+	// Aggregate adds a message to the aggregate of a window, it is nil for a new window
+	Aggregate func(aggregate []byte, msg *sarama.ConsumerMessage) ([]byte, error)
+	// Merge combines the aggregates of two sessions joined by an event, only needed for session windows
+	Merge func(a, b []byte) ([]byte, error)
+	// Emit receives the final aggregate of a window once its grace period passed
+	Emit func(key string, window Window, aggregate []byte) error
+}
+
+// windowed holds the open windows of one partition in a state store. Stream time is the
+// highest event time seen, it decides which windows are closed and which events are late.
+// Stream time and the offset of the last close are stored before windows get emitted,
+// so a replay after a restart neither reopens emitted windows nor counts late events.
+type windowed struct {
+	store        *Store
+	streamTime   time.Time
+	closedOffset int64
+	open         map[string][]Window
+	nextClose    time.Time
+}
+
+// closedKey stores the close marker of a partition, it is shorter than every window key
+var closedKey = []byte("closed")
+
+type aggregation struct {
+	stores     *Stores
+	topic      string
+	a          Aggregation
+	mux        sync.RWMutex
+	partitions map[int32]*windowed
+}
+
+// NewWindowConsumer constructs a Consumer that aggregates a topic in windows of event time,
+// the timestamps of the kafka records. Aggregates are kept in a state store per partition,
+// each window remembers the last offset it incorporated, replayed messages are not counted twice.
+func NewWindowConsumer(stores *Stores, topic string, a Aggregation) (*Consumer, error) {
+	if a.Windows.session() && a.Merge == nil {
+		return nil, fmt.Errorf("session windows of aggregation %s need a merge function", a.Name)
+	}
+	if !a.Windows.session() && (a.Windows.size <= 0 || a.Windows.advance <= 0 || a.Windows.advance > a.Windows.size) {
+		return nil, fmt.Errorf("invalid windows of aggregation %s", a.Name)
+	}
+	partitions, err := stores.Client().Partitions(topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of topic %s: %s", topic, err)
+	}
+	err = stores.EnsureChangelog(a.Name, int32(len(partitions)))
+	if err != nil {
+		return nil, err
+	}
+
+	g := &aggregation{
+		stores:     stores,
+		topic:      topic,
+		a:          a,
+		partitions: make(map[int32]*windowed),
+	}
+	c := NewOrderedConsumer(nil, g.incorporate)
+	c.OnAssigned(g.assign)
+	c.OnRevoked(g.revoke)
+	return c, nil
+}
+
+// windowKey sorts the windows of a key by start
+func windowKey(key string, w Window) []byte {
+	b := make([]byte, len(key)+16)
+	copy(b, key)
+	binary.BigEndian.PutUint64(b[len(key):], uint64(w.Start.UnixNano()))
+	binary.BigEndian.PutUint64(b[len(key)+8:], uint64(w.End.UnixNano()))
+	return b
+}
+
+func parseWindowKey(b []byte) (string, Window) {
+	n := len(b) - 16
+	return string(b[:n]), Window{
+		Start: time.Unix(0, int64(binary.BigEndian.Uint64(b[n:]))),
+		End:   time.Unix(0, int64(binary.BigEndian.Uint64(b[n+8:]))),
+	}
+}
+
+// the value of a window is the last incorporated offset followed by the aggregate
+func windowValue(offset int64, aggregate []byte) []byte {
+	b := make([]byte, 8+len(aggregate))
+	binary.BigEndian.PutUint64(b, uint64(offset))
+	copy(b[8:], aggregate)
+	return b
+}
+
+func parseWindowValue(b []byte) (int64, []byte) {
+	return int64(binary.BigEndian.Uint64(b)), b[8:]
+}
+
+// the close marker is the stream time in unix nanoseconds followed by the offset of the closing message
+func closedValue(streamTime time.Time, offset int64) []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b, uint64(streamTime.UnixNano()))
+	binary.BigEndian.PutUint64(b[8:], uint64(offset))
+	return b
+}
+
+func parseClosedValue(b []byte) (time.Time, int64) {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(b))), int64(binary.BigEndian.Uint64(b[8:]))
+}
+
+func (g *aggregation) assign(partitions map[string][]int32) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	for _, partition := range partitions[g.topic] {
+		store, err := g.stores.Open(g.a.Name, partition)
+		if err != nil {
+			return err
+		}
+		p := &windowed{store: store, closedOffset: -1, open: make(map[string][]Window)}
+		err = store.ForEach(func(k, v []byte) error {
+			if bytes.Equal(k, closedKey) {
+				p.streamTime, p.closedOffset = parseClosedValue(v)
+				return nil
+			}
+			key, w := parseWindowKey(k)
+			p.open[key] = append(p.open[key], w)
+			g.schedule(p, w)
+			return nil
+		})
+		if err != nil {
+			store.Close()
+			return fmt.Errorf("failed to load windows of %s/%d: %s", g.topic, partition, err)
+		}
+		g.partitions[partition] = p
+	}
+	return nil
+}
+
+func (g *aggregation) revoke(partitions map[string][]int32) error {
+	g.mux.Lock()
+	defer g.mux.Unlock()
+
+	for _, partition := range partitions[g.topic] {
+		p, ok := g.partitions[partition]
+		if !ok {
+			continue
+		}
+		delete(g.partitions, partition)
+		err := p.store.Close()
+		if err != nil {
+			return fmt.Errorf("failed to close windows of %s/%d: %s", g.topic, partition, err)
+		}
+	}
+	return nil
+}
+
+func (g *aggregation) incorporate(msg *sarama.ConsumerMessage) error {
+	g.mux.RLock()
+	p, ok := g.partitions[msg.Partition]
+	g.mux.RUnlock()
+	if !ok {
+		return fmt.Errorf("partition %s/%d is not assigned", msg.Topic, msg.Partition)
+	}
+	if msg.Offset <= p.closedOffset {
+		// incorporated before the last close, its windows are emitted or hold it already
+		metrics.Add("replayedEvents", 1)
+		return nil
+	}
+
+	t := msg.Timestamp
+	if t.IsZero() {
+		t = time.Now()
+	}
+	if t.After(p.streamTime) {
+		p.streamTime = t
+	}
+
+	key, err := g.a.Key(msg)
+	if err != nil {
+		return err
+	}
+	if key != "" {
+		if g.a.Windows.session() {
+			err = g.session(p, key, t, msg)
+		} else {
+			err = g.fixed(p, key, t, msg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return g.close(p, msg.Offset)
+}
+
+func (g *aggregation) late(p *windowed, w Window) bool {
+	if g.a.Windows.closes(w, g.a.Grace).After(p.streamTime) {
+		return false
+	}
+	metrics.Add("lateEvents", 1)
+	return true
+}
+
+func (g *aggregation) fixed(p *windowed, key string, t time.Time, msg *sarama.ConsumerMessage) error {
+	for _, w := range g.a.Windows.assign(t) {
+		if g.late(p, w) {
+			continue
+		}
+		k := windowKey(key, w)
+		stored, err := p.store.Get(k)
+		if err != nil {
+			return err
+		}
+		var aggregate []byte
+		if stored != nil {
+			offset, a := parseWindowValue(stored)
+			if offset >= msg.Offset {
+				continue
+			}
+			aggregate = a
+		} else {
+			p.open[key] = append(p.open[key], w)
+			g.schedule(p, w)
+		}
+		aggregate, err = g.a.Aggregate(aggregate, msg)
+		if err != nil {
+			return err
+		}
+		err = p.store.Put(k, windowValue(msg.Offset, aggregate))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// session merges the sessions of the key that are within the gap of t
+func (g *aggregation) session(p *windowed, key string, t time.Time, msg *sarama.ConsumerMessage) error {
+	gap := g.a.Windows.gap
+	merged := Window{Start: t, End: t}
+	if g.late(p, merged) {
+		return nil
+	}
+
+	var aggregate []byte
+	offset := int64(-1)
+	remaining := []Window{}
+	for _, w := range p.open[key] {
+		if t.Before(w.Start.Add(-gap)) || t.After(w.End.Add(gap)) {
+			remaining = append(remaining, w)
+			continue
+		}
+		k := windowKey(key, w)
+		stored, err := p.store.Get(k)
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			continue
+		}
+		o, a := parseWindowValue(stored)
+		if o > offset {
+			offset = o
+		}
+		if aggregate == nil {
+			aggregate = a
+		} else {
+			aggregate, err = g.a.Merge(aggregate, a)
+			if err != nil {
+				return err
+			}
+		}
+		if w.Start.Before(merged.Start) {
+			merged.Start = w.Start
+		}
+		if w.End.After(merged.End) {
+			merged.End = w.End
+		}
+		err = p.store.Delete(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	if offset < msg.Offset {
+		var err error
+		aggregate, err = g.a.Aggregate(aggregate, msg)
+		if err != nil {
+			return err
+		}
+		offset = msg.Offset
+	}
+	err := p.store.Put(windowKey(key, merged), windowValue(offset, aggregate))
+	if err != nil {
+		return err
+	}
+	p.open[key] = append(remaining, merged)
+	g.schedule(p, merged)
+	return nil
+}
+
+func (g *aggregation) schedule(p *windowed, w Window) {
+	closes := g.a.Windows.closes(w, g.a.Grace)
+	if p.nextClose.IsZero() || closes.Before(p.nextClose) {
+		p.nextClose = closes
+	}
+}
+
+// close emits and removes the windows whose grace period passed in stream time,
+// offset is the last incorporated message of the partition
+func (g *aggregation) close(p *windowed, offset int64) error {
+	if p.nextClose.IsZero() || p.nextClose.After(p.streamTime) {
+		return nil
+	}
+
+	type closed struct {
+		key string
+		w   Window
+	}
+	due := []closed{}
+	p.nextClose = time.Time{}
+	for key, windows := range p.open {
+		remaining := windows[:0]
+		for _, w := range windows {
+			closes := g.a.Windows.closes(w, g.a.Grace)
+			if !closes.After(p.streamTime) {
+				due = append(due, closed{key: key, w: w})
+				continue
+			}
+			remaining = append(remaining, w)
+			if p.nextClose.IsZero() || closes.Before(p.nextClose) {
+				p.nextClose = closes
+			}
+		}
+		if len(remaining) == 0 {
+			delete(p.open, key)
+			continue
+		}
+		p.open[key] = remaining
+	}
+
+	// mark the close before emitting, if emitting fails halfway a replay skips to the next message
+	// and the remaining windows are emitted with it
+	err := p.store.Put(closedKey, closedValue(p.streamTime, offset))
+	if err != nil {
+		return err
+	}
+	p.closedOffset = offset
+
+	// emit in window order, the same key stays in start order
+	sort.Slice(due, func(i, j int) bool {
+		return bytes.Compare(windowKey(due[i].key, due[i].w), windowKey(due[j].key, due[j].w)) < 0
+	})
+	for _, c := range due {
+		k := windowKey(c.key, c.w)
+		stored, err := p.store.Get(k)
+		if err != nil {
+			return err
+		}
+		if stored == nil {
+			continue
+		}
+		_, aggregate := parseWindowValue(stored)
+		err = g.a.Emit(c.key, c.w, aggregate)
+		if err != nil {
+			return fmt.Errorf("failed to emit window %s of %s: %s", c.w.Start.Format(time.RFC3339), c.key, err)
+		}
+		err = p.store.Delete(k)
+		if err != nil {
+			return err
+		}
+		metrics.Add("emittedWindows", 1)
+	}
+	if len(due) > 0 {
+		log.Printf("emitted %d windows of %s", len(due), g.a.Name)
+	}
+	return nil
+}
+
+// TopicEmitter produces the final aggregates to a topic, keyed by the aggregation key
+// with the window in the headers windowStart and windowEnd as unix milliseconds
+func TopicEmitter(producer sarama.SyncProducer, topic string) func(key string, window Window, aggregate []byte) error {
+	return func(key string, window Window, aggregate []byte) error {
+		_, _, err := producer.SendMessage(&sarama.ProducerMessage{
+			Topic: topic,
+			Key:   sarama.StringEncoder(key),
+			Value: sarama.ByteEncoder(aggregate),
+			Headers: []sarama.RecordHeader{
+				{Key: []byte("windowStart"), Value: []byte(strconv.FormatInt(window.Start.UnixNano()/int64(time.Millisecond), 10))},
+				{Key: []byte("windowEnd"), Value: []byte(strconv.FormatInt(window.End.UnixNano()/int64(time.Millisecond), 10))},
+			},
+			Timestamp: window.End,
+		})
+		return err
+	}
+}
@@ -0,0 +1,200 @@
+package simba
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+func at(seconds int) time.Time {
+	return time.Unix(int64(seconds), 0)
+}
+
+func TestAssignWindows(t *testing.T) {
+	tests := []struct {
+		name    string
+		windows Windows
+		t       int
+		starts  []int
+	}{
+		{"tumbling", TumblingWindows(10 * time.Second), 13, []int{10}},
+		{"tumbling at start", TumblingWindows(10 * time.Second), 20, []int{20}},
+		{"hopping", HoppingWindows(10*time.Second, 5*time.Second), 13, []int{5, 10}},
+		{"hopping at start", HoppingWindows(10*time.Second, 5*time.Second), 15, []int{10, 15}},
+		{"size no multiple of advance", HoppingWindows(10*time.Second, 4*time.Second), 13, []int{4, 8, 12}},
+		{"size no multiple of advance at start", HoppingWindows(10*time.Second, 4*time.Second), 16, []int{8, 12, 16}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			windows := tt.windows.assign(at(tt.t))
+			if len(windows) != len(tt.starts) {
+				t.Fatalf("expected %d windows, got %v", len(tt.starts), windows)
+			}
+			for i, w := range windows {
+				if !w.Start.Equal(at(tt.starts[i])) || !w.End.Equal(at(tt.starts[i]).Add(tt.windows.size)) {
+					t.Fatalf("expected window %d to start at %ds, got %v", i, tt.starts[i], w)
+				}
+				if at(tt.t).Before(w.Start) || !at(tt.t).Before(w.End) {
+					t.Fatalf("window %v does not contain %ds", w, tt.t)
+				}
+			}
+		})
+	}
+}
+
+// emitted is a final aggregate in the order of emission
+type emitted struct {
+	key   string
+	start int
+	end   int
+	count int
+}
+
+// newCounts counts the messages per key, the count is the decimal aggregate
+func newCounts(t *testing.T, k *kafka, windows Windows, grace time.Duration, emits *[]emitted) (*Consumer, *session) {
+	count := func(aggregate []byte) int {
+		if aggregate == nil {
+			return 0
+		}
+		n, err := strconv.Atoi(string(aggregate))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	c, err := NewWindowConsumer(newStores(t, k), "clicks", Aggregation{
+		Name:    "counts",
+		Windows: windows,
+		Grace:   grace,
+		Key: func(msg *sarama.ConsumerMessage) (string, error) {
+			return string(msg.Key), nil
+		},
+		Aggregate: func(aggregate []byte, msg *sarama.ConsumerMessage) ([]byte, error) {
+			return []byte(strconv.Itoa(count(aggregate) + 1)), nil
+		},
+		Merge: func(a, b []byte) ([]byte, error) {
+			return []byte(strconv.Itoa(count(a) + count(b))), nil
+		},
+		Emit: func(key string, w Window, aggregate []byte) error {
+			*emits = append(*emits, emitted{key: key, start: int(w.Start.Unix()), end: int(w.End.Unix()), count: count(aggregate)})
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := newSession(&committer{offsets: make(map[topicPartition]int64)}, map[string][]int32{"clicks": {0}})
+	err = c.Setup(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, sess
+}
+
+func click(t *testing.T, c *Consumer, offset int64, key string, seconds int) {
+	t.Helper()
+	err := c.view(&sarama.ConsumerMessage{Topic: "clicks", Offset: offset, Key: []byte(key), Timestamp: at(seconds)})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectEmitted(t *testing.T, got, expected []emitted) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Fatalf("expected windows %v, got %v", expected, got)
+	}
+}
+
+func TestLateEventsBeyondGrace(t *testing.T) {
+	k := newKafka(map[string]int32{"clicks": 1, "group-counts-changelog": 1})
+	emits := []emitted{}
+	c, sess := newCounts(t, k, TumblingWindows(10*time.Second), 5*time.Second, &emits)
+	defer c.Cleanup(sess)
+
+	click(t, c, 0, "a", 1)
+	click(t, c, 1, "a", 12)
+	// late but within the grace period of [0, 10)
+	click(t, c, 2, "a", 3)
+	expectEmitted(t, emits, []emitted{})
+
+	// stream time passes the grace period and closes [0, 10)
+	click(t, c, 3, "a", 16)
+	expectEmitted(t, emits, []emitted{{"a", 0, 10, 2}})
+
+	// beyond the grace period, dropped
+	click(t, c, 4, "a", 4)
+	click(t, c, 5, "a", 40)
+	expectEmitted(t, emits, []emitted{{"a", 0, 10, 2}, {"a", 10, 20, 2}})
+}
+
+func TestSessionMergeAndBridge(t *testing.T) {
+	k := newKafka(map[string]int32{"clicks": 1, "group-counts-changelog": 1})
+	emits := []emitted{}
+	c, sess := newCounts(t, k, SessionWindows(5*time.Second), 10*time.Second, &emits)
+	defer c.Cleanup(sess)
+
+	// within the gap, the session grows
+	click(t, c, 0, "a", 0)
+	click(t, c, 1, "a", 3)
+	// beyond the gap, a new session starts
+	click(t, c, 2, "a", 20)
+	click(t, c, 3, "a", 28)
+	expectEmitted(t, emits, []emitted{{"a", 0, 3, 2}})
+
+	// within the gap of both sessions, it bridges them
+	click(t, c, 4, "a", 24)
+	click(t, c, 5, "b", 100)
+	expectEmitted(t, emits, []emitted{{"a", 0, 3, 2}, {"a", 20, 28, 3}})
+}
+
+func TestWindowsRestoreFromChangelog(t *testing.T) {
+	k := newKafka(map[string]int32{"clicks": 1, "group-counts-changelog": 1})
+	emits := []emitted{}
+	c, sess := newCounts(t, k, TumblingWindows(10*time.Second), 0, &emits)
+	click(t, c, 0, "a", 1)
+	click(t, c, 1, "a", 2)
+	click(t, c, 2, "b", 3)
+	err := c.Cleanup(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.compact("group-counts-changelog", 0)
+
+	// the partition moved to a member without local state, offset 2 was not committed and is replayed
+	c, sess = newCounts(t, k, TumblingWindows(10*time.Second), 0, &emits)
+	defer c.Cleanup(sess)
+	click(t, c, 2, "b", 3)
+	click(t, c, 3, "a", 4)
+	click(t, c, 4, "a", 10)
+	expectEmitted(t, emits, []emitted{{"a", 0, 10, 3}, {"b", 0, 10, 1}})
+}
+
+func TestReplayAfterRestartKeepsClosedWindows(t *testing.T) {
+	k := newKafka(map[string]int32{"clicks": 1, "group-counts-changelog": 1})
+	emits := []emitted{}
+	c, sess := newCounts(t, k, TumblingWindows(10*time.Second), 0, &emits)
+	click(t, c, 0, "a", 1)
+	click(t, c, 1, "a", 2)
+	click(t, c, 2, "a", 12)
+	expectEmitted(t, emits, []emitted{{"a", 0, 10, 2}})
+	err := c.Cleanup(sess)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the member restarts before it committed any offset and replays the partition
+	c, sess = newCounts(t, k, TumblingWindows(10*time.Second), 0, &emits)
+	defer c.Cleanup(sess)
+	click(t, c, 0, "a", 1)
+	click(t, c, 1, "a", 2)
+	click(t, c, 2, "a", 12)
+	// late for the restored stream time, [0, 10) stays closed
+	click(t, c, 3, "a", 5)
+	click(t, c, 4, "a", 25)
+	expectEmitted(t, emits, []emitted{{"a", 0, 10, 2}, {"a", 10, 20, 1}})
+}
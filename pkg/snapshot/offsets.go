@@ -0,0 +1,146 @@
+package snapshot
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+)
+
+// CommittedOffsets returns the next offsets of a consumer group.
+// Partitions without committed offset start at the oldest message.
+func CommittedOffsets(client sarama.Client, group string, topics []string) ([]*pb.PartitionOffset, error) {
+	om, err := sarama.NewOffsetManagerFromClient(group, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup offset manager: %s", err)
+	}
+	defer om.Close()
+
+	offsets := []*pb.PartitionOffset{}
+	for _, topic := range topics {
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list partitions of topic %s: %s", topic, err)
+		}
+		for _, partition := range partitions {
+			pom, err := om.ManagePartition(topic, partition)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch offset of %s/%d: %s", topic, partition, err)
+			}
+			offset, _ := pom.NextOffset()
+			pom.AsyncClose()
+			if offset < 0 {
+				offset, err = client.GetOffset(topic, partition, sarama.OffsetOldest)
+				if err != nil {
+					return nil, fmt.Errorf("failed to get oldest offset of %s/%d: %s", topic, partition, err)
+				}
+			}
+			offsets = append(offsets, &pb.PartitionOffset{Topic: topic, Partition: partition, Offset: offset})
+		}
+	}
+	return offsets, nil
+}
+
+// HasCommittedOffsets tells if a consumer group consumed any of the topics already
+func HasCommittedOffsets(client sarama.Client, group string, topics []string) (bool, error) {
+	om, err := sarama.NewOffsetManagerFromClient(group, client)
+	if err != nil {
+		return false, fmt.Errorf("failed to setup offset manager: %s", err)
+	}
+	defer om.Close()
+
+	for _, topic := range topics {
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			return false, fmt.Errorf("failed to list partitions of topic %s: %s", topic, err)
+		}
+		for _, partition := range partitions {
+			pom, err := om.ManagePartition(topic, partition)
+			if err != nil {
+				return false, fmt.Errorf("failed to fetch offset of %s/%d: %s", topic, partition, err)
+			}
+			offset, _ := pom.NextOffset()
+			pom.AsyncClose()
+			if offset >= 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// CommitOffsets sets the offsets of a consumer group, the group must not be active
+func CommitOffsets(client sarama.Client, group string, offsets []*pb.PartitionOffset) error {
+	om, err := sarama.NewOffsetManagerFromClient(group, client)
+	if err != nil {
+		return fmt.Errorf("failed to setup offset manager: %s", err)
+	}
+
+	for _, o := range offsets {
+		pom, err := om.ManagePartition(o.Topic, o.Partition)
+		if err != nil {
+			om.Close()
+			return fmt.Errorf("failed to manage offset of %s/%d: %s", o.Topic, o.Partition, err)
+		}
+		current, _ := pom.NextOffset()
+		if o.Offset > current {
+			pom.MarkOffset(o.Offset, "")
+		} else {
+			pom.ResetOffset(o.Offset, "")
+		}
+		pom.AsyncClose()
+	}
+
+	err = om.Close()
+	if err != nil {
+		return fmt.Errorf("failed to commit offsets: %s", err)
+	}
+
+	// the offset manager only logs failed commits
+	topics := []string{}
+	seen := map[string]bool{}
+	for _, o := range offsets {
+		if !seen[o.Topic] {
+			seen[o.Topic] = true
+			topics = append(topics, o.Topic)
+		}
+	}
+	committed, err := CommittedOffsets(client, group, topics)
+	if err != nil {
+		return err
+	}
+	expected := map[string]int64{}
+	for _, o := range offsets {
+		expected[fmt.Sprintf("%s/%d", o.Topic, o.Partition)] = o.Offset
+	}
+	for _, o := range committed {
+		want, ok := expected[fmt.Sprintf("%s/%d", o.Topic, o.Partition)]
+		if ok && want != o.Offset {
+			return fmt.Errorf("failed to commit offset %d of %s/%d, found %d", want, o.Topic, o.Partition, o.Offset)
+		}
+	}
+	return nil
+}
+
+// OwnedOffsets returns the offsets of the partitions the topics have, offsets of other topics
+// or of partitions that do not exist anymore are dropped
+func OwnedOffsets(client sarama.Client, offsets []*pb.PartitionOffset, topics []string) ([]*pb.PartitionOffset, error) {
+	owned := map[string]bool{}
+	for _, topic := range topics {
+		partitions, err := client.Partitions(topic)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list partitions of topic %s: %s", topic, err)
+		}
+		for _, partition := range partitions {
+			owned[fmt.Sprintf("%s/%d", topic, partition)] = true
+		}
+	}
+
+	result := []*pb.PartitionOffset{}
+	for _, o := range offsets {
+		if owned[fmt.Sprintf("%s/%d", o.Topic, o.Partition)] {
+			result = append(result, o)
+		}
+	}
+	return result, nil
+}
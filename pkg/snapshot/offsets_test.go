@@ -0,0 +1,144 @@
+package snapshot
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+)
+
+const group = "inventory-products-v1"
+
+// fetched answers an offset fetch with the offsets of the products topic, -1 for partitions without offset
+func fetched(t *testing.T, offsets ...int64) *sarama.MockOffsetFetchResponse {
+	res := sarama.NewMockOffsetFetchResponse(t)
+	for partition, offset := range offsets {
+		res.SetOffset(group, "products", int32(partition), offset, "", sarama.ErrNoError)
+	}
+	return res
+}
+
+// newMockClient starts a broker that leads both partitions of the products topic and coordinates the group,
+// the offset fetches are answered in sequence
+func newMockClient(t *testing.T, fetches ...interface{}) (sarama.Client, *sarama.MockBroker) {
+	broker := sarama.NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("products", 0, broker.BrokerID()).
+			SetLeader("products", 1, broker.BrokerID()),
+		"FindCoordinatorRequest": sarama.NewMockFindCoordinatorResponse(t).
+			SetCoordinator(sarama.CoordinatorGroup, group, broker),
+		"OffsetRequest": sarama.NewMockOffsetResponse(t).SetVersion(1).
+			SetOffset("products", 0, sarama.OffsetOldest, 0).
+			SetOffset("products", 1, sarama.OffsetOldest, 7),
+		"OffsetFetchRequest":  sarama.NewMockSequence(fetches...),
+		"OffsetCommitRequest": sarama.NewMockOffsetCommitResponse(t),
+	})
+
+	config := sarama.NewConfig()
+	config.Version = sarama.V1_1_0_0
+	client, err := sarama.NewClient([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, broker
+}
+
+func formatOffsets(offsets []*pb.PartitionOffset) string {
+	s := ""
+	for _, o := range offsets {
+		s += fmt.Sprintf("%s/%d:%d ", o.Topic, o.Partition, o.Offset)
+	}
+	return s
+}
+
+func TestCommittedOffsets(t *testing.T) {
+	client, broker := newMockClient(t, fetched(t, 42, -1))
+	defer broker.Close()
+	defer client.Close()
+
+	committed, err := HasCommittedOffsets(client, group, []string{"products"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !committed {
+		t.Fatal("expected the group to have committed offsets")
+	}
+
+	// a partition without committed offset starts at its oldest message
+	offsets, err := CommittedOffsets(client, group, []string{"products"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := formatOffsets(offsets); s != "products/0:42 products/1:7 " {
+		t.Fatalf("unexpected offsets %s", s)
+	}
+}
+
+func TestCommitOffsetsRoundTrip(t *testing.T) {
+	// the offsets before the commit and the offsets the verification reads back
+	client, broker := newMockClient(t, fetched(t, 42, -1), fetched(t, 42, -1), fetched(t, 10, 3))
+	defer broker.Close()
+	defer client.Close()
+
+	offsets := []*pb.PartitionOffset{
+		{Topic: "products", Partition: 0, Offset: 10},
+		{Topic: "products", Partition: 1, Offset: 3},
+	}
+	err := CommitOffsets(client, group, offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits := 0
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*sarama.OffsetCommitRequest); ok && req.ConsumerGroup == group {
+			commits++
+		}
+	}
+	if commits == 0 {
+		t.Fatal("expected the offsets to be committed")
+	}
+
+	committed, err := CommittedOffsets(client, group, []string{"products"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if formatOffsets(committed) != formatOffsets(offsets) {
+		t.Fatalf("expected %s, read %s", formatOffsets(offsets), formatOffsets(committed))
+	}
+}
+
+func TestCommitOffsetsVerifies(t *testing.T) {
+	// the commit got lost, the group is still at the old offsets
+	client, broker := newMockClient(t, fetched(t, 42, -1))
+	defer broker.Close()
+	defer client.Close()
+
+	err := CommitOffsets(client, group, []*pb.PartitionOffset{{Topic: "products", Partition: 0, Offset: 10}})
+	if err == nil {
+		t.Fatal("expected the lost commit to fail")
+	}
+}
+
+func TestOwnedOffsets(t *testing.T) {
+	client, broker := newMockClient(t, fetched(t))
+	defer broker.Close()
+	defer client.Close()
+
+	// the snapshot holds a partition the topic does not have anymore and a topic of another projection
+	offsets := []*pb.PartitionOffset{
+		{Topic: "products", Partition: 0, Offset: 10},
+		{Topic: "products", Partition: 1, Offset: 3},
+		{Topic: "products", Partition: 2, Offset: 5},
+		{Topic: "stock", Partition: 0, Offset: 8},
+	}
+	owned, err := OwnedOffsets(client, offsets, []string{"products"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := formatOffsets(owned); s != "products/0:10 products/1:3 " {
+		t.Fatalf("expected only the partitions of the products topic, got %s", s)
+	}
+}
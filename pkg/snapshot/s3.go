@@ -0,0 +1,186 @@
+package snapshot
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// s3Storage keeps snapshots as objects of a bucket, requests are signed with AWS signature version 4
+type s3Storage struct {
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	opts     S3Options
+	client   *http.Client
+}
+
+func newS3Storage(bucket, prefix string, opts S3Options) (*s3Storage, error) {
+	if opts.Endpoint == "" {
+		return nil, fmt.Errorf("s3 endpoint is required")
+	}
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse s3 endpoint %s: %s", opts.Endpoint, err)
+	}
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	return &s3Storage{
+		endpoint: endpoint,
+		bucket:   bucket,
+		prefix:   prefix,
+		opts:     opts,
+		client:   &http.Client{},
+	}, nil
+}
+
+// upload buffers the snapshot in a temporary file to know its size
+type upload struct {
+	*os.File
+	storage *s3Storage
+	name    string
+}
+
+func (s *s3Storage) Create(name string) (io.WriteCloser, error) {
+	f, err := ioutil.TempFile("", name+".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer snapshot: %s", err)
+	}
+	return &upload{File: f, storage: s, name: name}, nil
+}
+
+func (u *upload) Close() error {
+	defer os.Remove(u.File.Name())
+	defer u.File.Close()
+
+	size, err := u.File.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to size snapshot: %s", err)
+	}
+	_, err = u.File.Seek(0, io.SeekStart)
+	if err != nil {
+		return fmt.Errorf("failed to rewind snapshot: %s", err)
+	}
+
+	req, err := u.storage.request(http.MethodPut, u.name, u.File)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+
+	res, err := u.storage.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload snapshot: %s", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("failed to upload snapshot: %s: %s", res.Status, body)
+	}
+	return nil
+}
+
+func (s *s3Storage) Open(name string) (io.ReadCloser, error) {
+	req, err := s.request(http.MethodGet, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download snapshot: %s", err)
+	}
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		return nil, fmt.Errorf("failed to download snapshot: %s: %s", res.Status, body)
+	}
+	return res.Body, nil
+}
+
+// request builds a signed path style request for an object
+func (s *s3Storage) request(method, name string, body io.Reader) (*http.Request, error) {
+	key := name
+	if s.prefix != "" {
+		key = s.prefix + "/" + name
+	}
+	path := "/" + s.bucket + "/" + key
+
+	u := *s.endpoint
+	u.Path = path
+	u.RawPath = escapePath(path)
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %s", err)
+	}
+	s.sign(req, time.Now().UTC())
+	return req, nil
+}
+
+func (s *s3Storage) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + s.opts.Region + "/s3/aws4_request"
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", unsignedPayload)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.Path),
+		"",
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + unsignedPayload + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.opts.SecretKey), date)
+	key = hmacSHA256(key, s.opts.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.opts.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// escapePath encodes everything but unreserved characters and slashes as required by signature version 4
+func escapePath(path string) string {
+	var b strings.Builder
+	for _, c := range []byte(path) {
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
@@ -0,0 +1,258 @@
+package snapshot
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
+)
+
+const scanCount = 1000
+
+// Owner tells if a redis key of the given type belongs to a projection
+type Owner func(key, typ string) bool
+
+// Dump writes the header and all owned keys of the projection to w
+func Dump(r redis.UniversalClient, owns Owner, header *pb.ProjectionSnapshot, w io.Writer) (int, error) {
+	sw, err := newWriter(w, header)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := 0
+	err = scan(r, owns, func(owned []string) error {
+		pipe := r.Pipeline()
+		dumps := make([]*redis.StringCmd, len(owned))
+		for i, key := range owned {
+			dumps[i] = pipe.Dump(key)
+		}
+		_, err := pipe.Exec()
+		if err != nil && err != redis.Nil {
+			return fmt.Errorf("failed to dump keys: %s", err)
+		}
+		for i, key := range owned {
+			value, err := dumps[i].Result()
+			if err == redis.Nil {
+				// removed since the scan
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("failed to dump %s: %s", key, err)
+			}
+			err = sw.entry(&pb.SnapshotEntry{Key: key, Value: []byte(value)})
+			if err != nil {
+				return err
+			}
+			keys++
+		}
+		return nil
+	})
+	if err != nil {
+		return keys, err
+	}
+
+	return keys, sw.close()
+}
+
+// Restore replaces all owned keys of the projection by the keys of the snapshot and returns its header
+func Restore(r redis.UniversalClient, owns Owner, rd io.Reader) (*pb.ProjectionSnapshot, int, error) {
+	sr, header, err := newReader(rd)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = scan(r, owns, func(owned []string) error {
+		// one DEL per key, the keys of a cluster spread across slots
+		pipe := r.Pipeline()
+		for _, key := range owned {
+			pipe.Del(key)
+		}
+		_, err := pipe.Exec()
+		return err
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to clear projection: %s", err)
+	}
+
+	keys := 0
+	pipe := r.Pipeline()
+	for {
+		entry, err := sr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, keys, err
+		}
+		pipe.RestoreReplace(entry.Key, 0, string(entry.Value))
+		keys++
+		if keys%scanCount == 0 {
+			_, err := pipe.Exec()
+			if err != nil {
+				return nil, keys, fmt.Errorf("failed to restore keys: %s", err)
+			}
+		}
+	}
+	_, err = pipe.Exec()
+	if err != nil {
+		return nil, keys, fmt.Errorf("failed to restore keys: %s", err)
+	}
+
+	return header, keys, nil
+}
+
+// writer encodes a snapshot as gzip compressed sequence of length prefixed messages, the header first
+type writer struct {
+	gz *gzip.Writer
+	bw *bufio.Writer
+}
+
+func newWriter(w io.Writer, header *pb.ProjectionSnapshot) (*writer, error) {
+	gz := gzip.NewWriter(w)
+	sw := &writer{gz: gz, bw: bufio.NewWriter(gz)}
+	err := writeMessage(sw.bw, header)
+	if err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (w *writer) entry(e *pb.SnapshotEntry) error {
+	return writeMessage(w.bw, e)
+}
+
+func (w *writer) close() error {
+	err := w.bw.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %s", err)
+	}
+	err = w.gz.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %s", err)
+	}
+	return nil
+}
+
+// reader decodes the header and the entries of a snapshot
+type reader struct {
+	br *bufio.Reader
+}
+
+func newReader(rd io.Reader) (*reader, *pb.ProjectionSnapshot, error) {
+	gz, err := gzip.NewReader(rd)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open snapshot: %s", err)
+	}
+	sr := &reader{br: bufio.NewReader(gz)}
+
+	header := &pb.ProjectionSnapshot{}
+	err = readMessage(sr.br, header)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read snapshot header: %s", err)
+	}
+	return sr, header, nil
+}
+
+// next returns the next entry, io.EOF after the last one
+func (r *reader) next() (*pb.SnapshotEntry, error) {
+	entry := &pb.SnapshotEntry{}
+	err := readMessage(r.br, entry)
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot entry: %s", err)
+	}
+	return entry, nil
+}
+
+// scan calls fn with batches of owned keys of all nodes, one batch at a time
+func scan(r redis.UniversalClient, owns Owner, fn func(owned []string) error) error {
+	mux := sync.Mutex{}
+	return redisclient.ForEachMaster(r, func(node *redis.Client) error {
+		return scanNode(node, owns, func(owned []string) error {
+			mux.Lock()
+			defer mux.Unlock()
+			return fn(owned)
+		})
+	})
+}
+
+func scanNode(r *redis.Client, owns Owner, fn func(owned []string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := r.Scan(cursor, "", scanCount).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan keys: %s", err)
+		}
+
+		if len(keys) > 0 {
+			pipe := r.Pipeline()
+			types := make([]*redis.StatusCmd, len(keys))
+			for i, key := range keys {
+				types[i] = pipe.Type(key)
+			}
+			_, err = pipe.Exec()
+			if err != nil {
+				return fmt.Errorf("failed to get key types: %s", err)
+			}
+
+			owned := []string{}
+			for i, key := range keys {
+				if owns(key, types[i].Val()) {
+					owned = append(owned, key)
+				}
+			}
+			if len(owned) > 0 {
+				err := fn(owned)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func writeMessage(w io.Writer, msg proto.Message) error {
+	bytes, err := proto.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %s", proto.MessageName(msg), err)
+	}
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(bytes)))
+	_, err = w.Write(size)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %s", err)
+	}
+	_, err = w.Write(bytes)
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot: %s", err)
+	}
+	return nil
+}
+
+func readMessage(r io.Reader, msg proto.Message) error {
+	size := make([]byte, 4)
+	_, err := io.ReadFull(r, size)
+	if err != nil {
+		return err
+	}
+	bytes := make([]byte, binary.BigEndian.Uint32(size))
+	_, err = io.ReadFull(r, bytes)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(bytes, msg)
+}
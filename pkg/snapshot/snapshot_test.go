@@ -0,0 +1,124 @@
+package snapshot
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/golang/protobuf/proto"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	header := &pb.ProjectionSnapshot{
+		Group:     "inventory-products-v2",
+		CreatedAt: 1571234567,
+		Offsets: []*pb.PartitionOffset{
+			{Topic: "products", Partition: 0, Offset: 10},
+			{Topic: "products", Partition: 1, Offset: 3},
+		},
+	}
+	entries := []*pb.SnapshotEntry{
+		{Key: "product:1", Value: []byte("lamp")},
+		{Key: "product:2", Value: []byte("table")},
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := newWriter(buf, header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		err = w.entry(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = w.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.Bytes()
+
+	r, read, err := newReader(bytes.NewReader(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(read, header) {
+		t.Fatalf("expected header %v, read %v", header, read)
+	}
+	for _, e := range entries {
+		entry, err := r.next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(entry, e) {
+			t.Fatalf("expected entry %v, read %v", e, entry)
+		}
+	}
+	_, err = r.next()
+	if err != io.EOF {
+		t.Fatalf("expected the end of the snapshot, got %v", err)
+	}
+
+	// a cut snapshot fails instead of restoring a part of the keys
+	r, _, err = newReader(bytes.NewReader(snapshot[:len(snapshot)-10]))
+	for err == nil {
+		_, err = r.next()
+	}
+	if err == io.EOF {
+		t.Fatal("expected the truncated snapshot to fail")
+	}
+}
+
+func TestRestoreOwnedKeys(t *testing.T) {
+	address := os.Getenv("REDIS_ADDRESS")
+	if address == "" {
+		t.Skip("REDIS_ADDRESS is not set")
+	}
+	r, err := redisclient.New(redisclient.Options{Addresses: []string{address}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	owns := func(key, typ string) bool { return strings.HasPrefix(key, "snapshot-test:product:") }
+	keys := []string{"snapshot-test:product:1", "snapshot-test:product:2", "snapshot-test:product:3", "snapshot-test:other"}
+	defer r.Del(keys...)
+	r.Set(keys[0], "lamp", 0)
+	r.Set(keys[1], "table", 0)
+	r.Set(keys[3], "other", 0)
+
+	header := &pb.ProjectionSnapshot{Group: "inventory-products-v2"}
+	buf := &bytes.Buffer{}
+	dumped, err := Dump(r, owns, header, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dumped != 2 {
+		t.Fatalf("expected 2 owned keys to be dumped, got %d", dumped)
+	}
+
+	// the keys of the projection change after the snapshot, other keys must stay untouched
+	r.Set(keys[0], "renamed lamp", 0)
+	r.Set(keys[2], "chair", 0)
+	r.Set(keys[3], "changed other", 0)
+
+	read, restored, err := Restore(r, owns, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != 2 || read.Group != header.Group {
+		t.Fatalf("expected 2 restored keys of group %s, got %d of %s", header.Group, restored, read.Group)
+	}
+	values, err := r.MGet(keys...).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0] != "lamp" || values[1] != "table" || values[2] != nil || values[3] != "changed other" {
+		t.Fatalf("expected the owned keys of the snapshot and the other key unchanged, got %v", values)
+	}
+}
@@ -0,0 +1,92 @@
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Storage keeps snapshot files
+type Storage interface {
+	// Create returns a writer whose content replaces the snapshot on Close
+	Create(name string) (io.WriteCloser, error)
+	// Open reads a snapshot
+	Open(name string) (io.ReadCloser, error)
+}
+
+// S3Options configure the access to an S3 compatible object store like MinIO
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+// NewStorage selects the storage by url, file:///path for local disk or s3://bucket/prefix for an object store
+func NewStorage(location string, s3 S3Options) (Storage, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot location %s: %s", location, err)
+	}
+	switch u.Scheme {
+	case "", "file":
+		return &dirStorage{dir: u.Path}, nil
+	case "s3":
+		return newS3Storage(u.Host, strings.Trim(u.Path, "/"), s3)
+	}
+	return nil, fmt.Errorf("unsupported snapshot location %s", location)
+}
+
+// dirStorage keeps snapshots as files of a local directory
+type dirStorage struct {
+	dir string
+}
+
+// atomicFile becomes visible under its name once it is closed
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+func (d *dirStorage) Create(name string) (io.WriteCloser, error) {
+	err := os.MkdirAll(d.dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory %s: %s", d.dir, err)
+	}
+	f, err := ioutil.TempFile(d.dir, name+".tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot file: %s", err)
+	}
+	return &atomicFile{File: f, path: filepath.Join(d.dir, name)}, nil
+}
+
+func (f *atomicFile) Close() error {
+	err := f.File.Sync()
+	if err != nil {
+		f.File.Close()
+		os.Remove(f.File.Name())
+		return fmt.Errorf("failed to sync snapshot: %s", err)
+	}
+	err = f.File.Close()
+	if err != nil {
+		os.Remove(f.File.Name())
+		return fmt.Errorf("failed to close snapshot: %s", err)
+	}
+	err = os.Rename(f.File.Name(), f.path)
+	if err != nil {
+		return fmt.Errorf("failed to rename snapshot: %s", err)
+	}
+	return nil
+}
+
+func (d *dirStorage) Open(name string) (io.ReadCloser, error) {
+	f, err := os.Open(filepath.Join(d.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %s", err)
+	}
+	return f, nil
+}
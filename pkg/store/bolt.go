@@ -0,0 +1,157 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/tenant"
+	"github.com/golang/protobuf/proto"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	productsBucket   = []byte("products")
+	categoriesBucket = []byte("categories")
+	offsetsBucket    = []byte("offsets")
+)
+
+// Bolt keeps the views in an embedded bbolt file, a bucket per tenant holds a bucket per category
+// with its products, the offsets of the consumer group are written in the same transaction
+type Bolt struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens or creates the database file, only one process can open it at a time
+func OpenBolt(path string) (*Bolt, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database %s: %s", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{productsBucket, categoriesBucket, offsetsBucket} {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %s", err)
+	}
+	return &Bolt{db: db}, nil
+}
+
+// WriteProducts puts and deletes the products and stores the offsets in one transaction
+func (b *Bolt) WriteProducts(group string, writes []ProductWrite, offsets []*pb.PartitionOffset) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		products := tx.Bucket(productsBucket)
+		for _, w := range writes {
+			key := []byte(tenant.Key(w.Tenant, w.UUID))
+			if w.Product == nil {
+				err := products.Delete(key)
+				if err != nil {
+					return fmt.Errorf("failed to delete product %s: %s", key, err)
+				}
+				continue
+			}
+			bytes, err := proto.Marshal(w.Product)
+			if err != nil {
+				return fmt.Errorf("failed to marshal the prduct %s: %s", key, err)
+			}
+			err = products.Put(key, bytes)
+			if err != nil {
+				return fmt.Errorf("failed to write product %s: %s", key, err)
+			}
+		}
+		return putOffsets(tx, group, offsets)
+	})
+}
+
+// WriteCategories moves the products between the category buckets and stores the offsets in one transaction
+func (b *Bolt) WriteCategories(group string, changes []CategoryChange, offsets []*pb.PartitionOffset) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, c := range changes {
+			categories, err := tx.Bucket(categoriesBucket).CreateBucketIfNotExists(tenantBucket(c.Tenant))
+			if err != nil {
+				return fmt.Errorf("failed to create categories of tenant %s: %s", tenant.Name(c.Tenant), err)
+			}
+			if c.Old != "" {
+				old := categories.Bucket([]byte(c.Old))
+				if old != nil {
+					err := old.Delete([]byte(c.UUID))
+					if err != nil {
+						return fmt.Errorf("failed to remove %s from category %s: %s", c.UUID, c.Old, err)
+					}
+				}
+			}
+			if c.New != "" {
+				bucket, err := categories.CreateBucketIfNotExists([]byte(c.New))
+				if err != nil {
+					return fmt.Errorf("failed to create category %s: %s", c.New, err)
+				}
+				err = bucket.Put([]byte(c.UUID), []byte{})
+				if err != nil {
+					return fmt.Errorf("failed to add %s to category %s: %s", c.UUID, c.New, err)
+				}
+			}
+		}
+		return putOffsets(tx, group, offsets)
+	})
+}
+
+// tenantBucket names the bucket of a tenant, the prefix keeps the name of the default tenant from being empty
+func tenantBucket(t string) []byte {
+	return []byte("tenant:" + t)
+}
+
+func putOffsets(tx *bolt.Tx, group string, offsets []*pb.PartitionOffset) error {
+	if len(offsets) == 0 {
+		return nil
+	}
+	bucket, err := tx.Bucket(offsetsBucket).CreateBucketIfNotExists([]byte(group))
+	if err != nil {
+		return fmt.Errorf("failed to create offsets of group %s: %s", group, err)
+	}
+	for _, o := range offsets {
+		bytes, err := proto.Marshal(o)
+		if err != nil {
+			return fmt.Errorf("failed to marshal offset: %s", err)
+		}
+		err = bucket.Put([]byte(fmt.Sprintf("%s/%d", o.Topic, o.Partition)), bytes)
+		if err != nil {
+			return fmt.Errorf("failed to write offset of %s/%d: %s", o.Topic, o.Partition, err)
+		}
+	}
+	return nil
+}
+
+// Offsets returns the offsets committed with the views of the group
+func (b *Bolt) Offsets(group string) ([]*pb.PartitionOffset, error) {
+	offsets := []*pb.PartitionOffset{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(offsetsBucket).Bucket([]byte(group))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			o := &pb.PartitionOffset{}
+			err := proto.Unmarshal(v, o)
+			if err != nil {
+				return fmt.Errorf("failed to unmarshal offset %s: %s", k, err)
+			}
+			offsets = append(offsets, o)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return offsets, nil
+}
+
+// Close closes the database file
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
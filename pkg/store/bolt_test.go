@@ -0,0 +1,159 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+	bolt "go.etcd.io/bbolt"
+)
+
+func openBolt(t *testing.T) *Bolt {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	b, err := OpenBolt(filepath.Join(dir, "views.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+// members returns the sorted products of a category of a tenant, joined by spaces
+func members(t *testing.T, b *Bolt, tenant, category string) string {
+	t.Helper()
+	uuids := []string{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		categories := tx.Bucket(categoriesBucket).Bucket(tenantBucket(tenant))
+		if categories == nil || categories.Bucket([]byte(category)) == nil {
+			return nil
+		}
+		return categories.Bucket([]byte(category)).ForEach(func(k, v []byte) error {
+			uuids = append(uuids, string(k))
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(uuids)
+	return strings.Join(uuids, " ")
+}
+
+func hasProduct(t *testing.T, b *Bolt, key string) bool {
+	t.Helper()
+	found := false
+	err := b.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(productsBucket).Get([]byte(key)) != nil
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return found
+}
+
+func offsetOf(t *testing.T, b *Bolt, group string) int64 {
+	t.Helper()
+	offsets, err := b.Offsets(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offsets) == 0 {
+		return 0
+	}
+	if len(offsets) != 1 {
+		t.Fatalf("expected the offset of one partition, got %v", offsets)
+	}
+	return offsets[0].Offset
+}
+
+func TestBoltCategoriesOfTenants(t *testing.T) {
+	b := openBolt(t)
+
+	// the category of the tenant joined with its name equals the category of the default tenant
+	changes := []CategoryChange{
+		{Tenant: "shop-a", UUID: "1", New: "home:light"},
+		{Tenant: "", UUID: "2", New: "shop-a:home:light"},
+	}
+	err := b.WriteCategories("categories", changes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := members(t, b, "shop-a", "home:light"); m != "1" {
+		t.Fatalf("expected product 1 in the category of shop-a, got %q", m)
+	}
+	if m := members(t, b, "", "shop-a:home:light"); m != "2" {
+		t.Fatalf("expected product 2 in the category of the default tenant, got %q", m)
+	}
+}
+
+func TestBoltWritesViewsWithOffsets(t *testing.T) {
+	b := openBolt(t)
+
+	offsets := []*pb.PartitionOffset{{Topic: "products", Partition: 0, Offset: 3}}
+	changes := []CategoryChange{
+		{UUID: "1", New: "home"},
+		{UUID: "2", New: "home"},
+		{UUID: "1", Old: "home", New: "garden"},
+	}
+	err := b.WriteCategories("categories", changes, offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a replay of the batch after a crash leaves the same view and offsets
+	err = b.WriteCategories("categories", changes, offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if members(t, b, "", "home") != "2" || members(t, b, "", "garden") != "1" || offsetOf(t, b, "categories") != 3 {
+		t.Fatalf("expected 2 in home and 1 in garden at offset 3, got %q and %q at %d",
+			members(t, b, "", "home"), members(t, b, "", "garden"), offsetOf(t, b, "categories"))
+	}
+
+	// a failing change rolls back the changes before it and the offsets
+	failing := []CategoryChange{
+		{UUID: "3", New: "home"},
+		{UUID: "", New: "home"},
+	}
+	err = b.WriteCategories("categories", failing, []*pb.PartitionOffset{{Topic: "products", Partition: 0, Offset: 5}})
+	if err == nil {
+		t.Fatal("expected the change without product to fail")
+	}
+	if members(t, b, "", "home") != "2" || offsetOf(t, b, "categories") != 3 {
+		t.Fatalf("expected the failed batch to leave no changes, got %q at %d", members(t, b, "", "home"), offsetOf(t, b, "categories"))
+	}
+
+	// products and offsets of another group
+	writes := []ProductWrite{
+		{UUID: "1", Product: &pb.Product{Uuid: "1", Title: "Lamp"}},
+		{UUID: "", Product: &pb.Product{Title: "Broken"}},
+	}
+	err = b.WriteProducts("products", writes, offsets)
+	if err == nil {
+		t.Fatal("expected the product without uuid to fail")
+	}
+	if offsetOf(t, b, "products") != 0 || hasProduct(t, b, "1") {
+		t.Fatal("expected no products and offsets of the failed batch")
+	}
+	err = b.WriteProducts("products", writes[:1], offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.WriteProducts("products", writes[:1], offsets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offsetOf(t, b, "products") != 3 || !hasProduct(t, b, "1") {
+		t.Fatalf("expected the lamp at offset 3, got offset %d", offsetOf(t, b, "products"))
+	}
+}
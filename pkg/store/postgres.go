@@ -0,0 +1,198 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/golang/protobuf/proto"
+	// postgres driver for database/sql
+	_ "github.com/lib/pq"
+)
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS products (
+	tenant      TEXT NOT NULL,
+	uuid        TEXT NOT NULL,
+	title       TEXT NOT NULL,
+	category    TEXT NOT NULL,
+	price_units BIGINT,
+	currency    TEXT,
+	product     BYTEA NOT NULL,
+	PRIMARY KEY (tenant, uuid)
+);
+CREATE TABLE IF NOT EXISTS category_products (
+	tenant   TEXT NOT NULL,
+	category TEXT NOT NULL,
+	uuid     TEXT NOT NULL,
+	PRIMARY KEY (tenant, category, uuid)
+);
+CREATE TABLE IF NOT EXISTS projection_offsets (
+	consumer_group TEXT NOT NULL,
+	topic          TEXT NOT NULL,
+	partition      INTEGER NOT NULL,
+	next_offset    BIGINT NOT NULL,
+	PRIMARY KEY (consumer_group, topic, partition)
+);
+`
+
+const (
+	upsertProduct = `INSERT INTO products (tenant, uuid, title, category, price_units, currency, product)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (tenant, uuid) DO UPDATE SET
+title = excluded.title, category = excluded.category, price_units = excluded.price_units,
+currency = excluded.currency, product = excluded.product`
+	deleteProduct  = `DELETE FROM products WHERE tenant = $1 AND uuid = $2`
+	insertCategory = `INSERT INTO category_products (tenant, category, uuid) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	deleteCategory = `DELETE FROM category_products WHERE tenant = $1 AND category = $2 AND uuid = $3`
+	upsertOffset   = `INSERT INTO projection_offsets (consumer_group, topic, partition, next_offset)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (consumer_group, topic, partition) DO UPDATE SET next_offset = excluded.next_offset`
+	selectOffsets = `SELECT topic, partition, next_offset FROM projection_offsets WHERE consumer_group = $1 ORDER BY topic, partition`
+)
+
+// Postgres keeps products and categories in tables,
+// the offsets of the consumer group are written in the same transaction
+type Postgres struct {
+	db *sql.DB
+}
+
+// OpenPostgres connects to the database and creates the tables
+func OpenPostgres(dsn string) (*Postgres, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres: %s", err)
+	}
+	_, err = db.Exec(postgresSchema)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create postgres tables: %s", err)
+	}
+	return &Postgres{db: db}, nil
+}
+
+// WriteProducts upserts and deletes the products and stores the offsets in one transaction
+func (p *Postgres) WriteProducts(group string, writes []ProductWrite, offsets []*pb.PartitionOffset) error {
+	return p.transaction(group, offsets, func(tx *sql.Tx) error {
+		upsert, err := tx.Prepare(upsertProduct)
+		if err != nil {
+			return err
+		}
+		defer upsert.Close()
+		del, err := tx.Prepare(deleteProduct)
+		if err != nil {
+			return err
+		}
+		defer del.Close()
+
+		for _, w := range writes {
+			if w.Product == nil {
+				_, err = del.Exec(w.Tenant, w.UUID)
+				if err != nil {
+					return fmt.Errorf("failed to delete product %s: %s", w.UUID, err)
+				}
+				continue
+			}
+			bytes, err := proto.Marshal(w.Product)
+			if err != nil {
+				return fmt.Errorf("failed to marshal the prduct %s: %s", w.UUID, err)
+			}
+			var units sql.NullInt64
+			var currency sql.NullString
+			if w.Product.Price != nil {
+				units = sql.NullInt64{Int64: w.Product.Price.Units, Valid: true}
+				currency = sql.NullString{String: w.Product.Price.Currency, Valid: true}
+			}
+			_, err = upsert.Exec(w.Tenant, w.UUID, w.Product.Title, w.Product.Category, units, currency, bytes)
+			if err != nil {
+				return fmt.Errorf("failed to write product %s: %s", w.UUID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// WriteCategories moves the products between categories and stores the offsets in one transaction
+func (p *Postgres) WriteCategories(group string, changes []CategoryChange, offsets []*pb.PartitionOffset) error {
+	return p.transaction(group, offsets, func(tx *sql.Tx) error {
+		insert, err := tx.Prepare(insertCategory)
+		if err != nil {
+			return err
+		}
+		defer insert.Close()
+		del, err := tx.Prepare(deleteCategory)
+		if err != nil {
+			return err
+		}
+		defer del.Close()
+
+		for _, c := range changes {
+			if c.Old != "" {
+				_, err = del.Exec(c.Tenant, c.Old, c.UUID)
+				if err != nil {
+					return fmt.Errorf("failed to remove %s from category %s: %s", c.UUID, c.Old, err)
+				}
+			}
+			if c.New != "" {
+				_, err = insert.Exec(c.Tenant, c.New, c.UUID)
+				if err != nil {
+					return fmt.Errorf("failed to add %s to category %s: %s", c.UUID, c.New, err)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// transaction runs the writes and stores the offsets, all or nothing
+func (p *Postgres) transaction(group string, offsets []*pb.PartitionOffset, writes func(tx *sql.Tx) error) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %s", err)
+	}
+
+	err = writes(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, o := range offsets {
+		_, err = tx.Exec(upsertOffset, group, o.Topic, o.Partition, o.Offset)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to write offset of %s/%d: %s", o.Topic, o.Partition, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %s", err)
+	}
+	return nil
+}
+
+// Offsets returns the offsets committed with the views of the group
+func (p *Postgres) Offsets(group string) ([]*pb.PartitionOffset, error) {
+	rows, err := p.db.Query(selectOffsets, group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query offsets: %s", err)
+	}
+	defer rows.Close()
+
+	offsets := []*pb.PartitionOffset{}
+	for rows.Next() {
+		o := &pb.PartitionOffset{}
+		err = rows.Scan(&o.Topic, &o.Partition, &o.Offset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read offset: %s", err)
+		}
+		offsets = append(offsets, o)
+	}
+	return offsets, rows.Err()
+}
+
+// Close closes the database connections
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
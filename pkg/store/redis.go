@@ -0,0 +1,85 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/tenant"
+	"github.com/go-redis/redis"
+	"github.com/golang/protobuf/proto"
+)
+
+// Redis keeps products as strings and categories as sets, offsets stay with kafka
+type Redis struct {
+	client redis.UniversalClient
+}
+
+// NewRedis constructs a Redis store
+func NewRedis(client redis.UniversalClient) *Redis {
+	return &Redis{client: client}
+}
+
+// WriteProducts writes the products with one pipeline
+func (r *Redis) WriteProducts(group string, writes []ProductWrite, offsets []*pb.PartitionOffset) error {
+	if len(writes) == 0 {
+		return nil
+	}
+
+	pipe := r.client.Pipeline()
+	for _, w := range writes {
+		key := tenant.Key(w.Tenant, w.UUID)
+		if w.Product == nil {
+			pipe.Del(key)
+			continue
+		}
+		bytes, err := proto.Marshal(w.Product)
+		if err != nil {
+			return fmt.Errorf("failed to marshal the prduct %s: %s", key, err)
+		}
+		pipe.Set(key, bytes, 0)
+	}
+	_, err := pipe.Exec()
+	if err != nil {
+		return fmt.Errorf("failed to write %d products to redis: %s", len(writes), err)
+	}
+	return nil
+}
+
+// WriteCategories applies the changes in one transaction per cluster slot,
+// SREM and SADD are idempotent so a replay after a partial write converges
+func (r *Redis) WriteCategories(group string, changes []CategoryChange, offsets []*pb.PartitionOffset) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	pipe := r.client.TxPipeline()
+	for _, c := range changes {
+		// like SMOVE, but also adds products that were missing in the old category
+		if c.Old != "" {
+			pipe.SRem(CategoryKey(c.Tenant, c.Old), c.UUID)
+		}
+		if c.New != "" {
+			pipe.SAdd(CategoryKey(c.Tenant, c.New), c.UUID)
+		}
+	}
+	_, err := pipe.Exec()
+	if err != nil {
+		return fmt.Errorf("failed to apply %d category changes: %s", len(changes), err)
+	}
+	return nil
+}
+
+// Offsets returns nil, the consumer group offsets are committed to kafka
+func (r *Redis) Offsets(group string) ([]*pb.PartitionOffset, error) {
+	return nil, nil
+}
+
+// Close closes the redis client
+func (r *Redis) Close() error {
+	return r.client.Close()
+}
+
+// CategoryKey tags every category by itself, so the categories of a tenant spread across the cluster
+func CategoryKey(t, category string) string {
+	return tenant.TaggedKey(t, "categories:"+category, "members")
+}
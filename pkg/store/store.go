@@ -0,0 +1,83 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+)
+
+// ProductWrite is the latest state of a product, a nil Product deletes it
+type ProductWrite struct {
+	Tenant  string
+	UUID    string
+	Product *pb.Product
+}
+
+// CategoryChange moves a product between categories,
+// an empty Old adds it and an empty New removes it
+type CategoryChange struct {
+	Tenant string
+	UUID   string
+	Old    string
+	New    string
+}
+
+// Store keeps the read models of the products and categories projections.
+// Offsets are the next offsets of the consumer group after the writes,
+// stores that persist them do so atomically with the view.
+type Store interface {
+	WriteProducts(group string, writes []ProductWrite, offsets []*pb.PartitionOffset) error
+	WriteCategories(group string, changes []CategoryChange, offsets []*pb.PartitionOffset) error
+	// Offsets returns the offsets written with the views, nil if the store leaves them to kafka
+	Offsets(group string) ([]*pb.PartitionOffset, error)
+	Close() error
+}
+
+// Options select and configure a Store
+type Options struct {
+	Kind     string
+	Redis    redisclient.Options
+	Postgres string
+	Bolt     string
+}
+
+// Kinds lists the available stores
+var Kinds = []string{"redis", "postgres", "bolt"}
+
+// New opens the store of the given kind
+func New(o Options) (Store, error) {
+	switch o.Kind {
+	case "redis":
+		r, err := redisclient.New(o.Redis)
+		if err != nil {
+			return nil, err
+		}
+		return NewRedis(r), nil
+	case "postgres":
+		return OpenPostgres(o.Postgres)
+	case "bolt":
+		return OpenBolt(o.Bolt)
+	}
+	return nil, fmt.Errorf("unknown store %s", o.Kind)
+}
+
+// NextOffsets returns the offset after the last message of every partition of the batch
+func NextOffsets(msgs []*sarama.ConsumerMessage) []*pb.PartitionOffset {
+	offsets := []*pb.PartitionOffset{}
+	index := map[string]*pb.PartitionOffset{}
+	for _, msg := range msgs {
+		key := fmt.Sprintf("%s/%d", msg.Topic, msg.Partition)
+		o, ok := index[key]
+		if !ok {
+			o = &pb.PartitionOffset{Topic: msg.Topic, Partition: msg.Partition}
+			index[key] = o
+			offsets = append(offsets, o)
+		}
+		if msg.Offset+1 > o.Offset {
+			o.Offset = msg.Offset + 1
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,172 @@
+package store
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/fake"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/redisclient"
+	"github.com/golang/protobuf/proto"
+)
+
+// batch returns a batch of product updates, the first half inserts products
+// and the second half moves, reprices and deletes them
+func batch(b *testing.B, size int) []*sarama.ConsumerMessage {
+	g, err := fake.New(0, fake.Options{Categories: 500, Zipf: 1.2, Prices: "lognormal", MeanPrice: 2500, TextSize: "small"})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	products := []*pb.Product{}
+	msgs := make([]*sarama.ConsumerMessage, size)
+	for i := range msgs {
+		u := &pb.ProductUpdate{Tenant: "bench"}
+		switch {
+		case i < size/2:
+			u.New = g.Product("EUR")
+			products = append(products, u.New)
+		default:
+			j := g.Intn(len(products))
+			u.Old = products[j]
+			switch n := g.Intn(100); {
+			case n < 10:
+			case n < 30:
+				u.New = proto.Clone(u.Old).(*pb.Product)
+				u.New.Category = g.Category()
+			default:
+				u.New = proto.Clone(u.Old).(*pb.Product)
+				u.New.Price = &pb.Money{Units: g.Units(), Currency: u.Old.Price.Currency}
+			}
+			if u.New == nil {
+				products[j] = products[len(products)-1]
+				products = products[:len(products)-1]
+			} else {
+				products[j] = u.New
+			}
+		}
+
+		bytes, err := proto.Marshal(u)
+		if err != nil {
+			b.Fatal(err)
+		}
+		UUID := u.GetNew().GetUuid()
+		if UUID == "" {
+			UUID = u.GetOld().GetUuid()
+		}
+		msgs[i] = &sarama.ConsumerMessage{Topic: "products", Offset: int64(i), Key: []byte(UUID), Value: bytes}
+	}
+	return msgs
+}
+
+// stores open the bolt store in a temporary directory and the redis and postgres stores
+// of REDIS_ADDRESS and POSTGRES, the benchmarks of missing stores are skipped
+var stores = []struct {
+	name string
+	open func(b *testing.B) Store
+}{
+	{"bolt", func(b *testing.B) Store {
+		dir, err := ioutil.TempDir("", "store-bench")
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { os.RemoveAll(dir) })
+		s, err := OpenBolt(filepath.Join(dir, "views.db"))
+		if err != nil {
+			b.Fatal(err)
+		}
+		return s
+	}},
+	{"redis", func(b *testing.B) Store {
+		address := os.Getenv("REDIS_ADDRESS")
+		if address == "" {
+			b.Skip("REDIS_ADDRESS is not set")
+		}
+		r, err := redisclient.New(redisclient.Options{Addresses: []string{address}})
+		if err != nil {
+			b.Fatal(err)
+		}
+		return NewRedis(r)
+	}},
+	{"postgres", func(b *testing.B) Store {
+		dsn := os.Getenv("POSTGRES")
+		if dsn == "" {
+			b.Skip("POSTGRES is not set")
+		}
+		s, err := OpenPostgres(dsn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return s
+	}},
+}
+
+func BenchmarkWriteProducts(b *testing.B) {
+	msgs := batch(b, 1000)
+
+	b.Run(fmt.Sprintf("decode/%d", len(msgs)), func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := ProductWrites(msgs, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	for _, st := range stores {
+		open := st.open
+		b.Run(fmt.Sprintf("%s/%d", st.name, len(msgs)), func(b *testing.B) {
+			s := open(b)
+			defer s.Close()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				writes, err := ProductWrites(msgs, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				err = s.WriteProducts("bench-products", writes, NextOffsets(msgs))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWriteCategories(b *testing.B) {
+	msgs := batch(b, 1000)
+
+	b.Run(fmt.Sprintf("decode/%d", len(msgs)), func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := CategoryChanges(msgs, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	for _, st := range stores {
+		open := st.open
+		b.Run(fmt.Sprintf("%s/%d", st.name, len(msgs)), func(b *testing.B) {
+			s := open(b)
+			defer s.Close()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				changes, err := CategoryChanges(msgs, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+				err = s.WriteCategories("bench-categories", changes, NextOffsets(msgs))
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/Shopify/sarama"
+	"github.com/damoon/eventstore-example/pkg/pb"
+	"github.com/damoon/eventstore-example/pkg/tenant"
+	"github.com/golang/protobuf/proto"
+)
+
+// ProductWrites decodes a batch of product updates into the latest state of every product of the served tenants
+func ProductWrites(msgs []*sarama.ConsumerMessage, filter tenant.Filter) ([]ProductWrite, error) {
+
+	// the latest product per key, nil for deletes
+	latest := map[string]*ProductWrite{}
+	order := []string{}
+
+	for _, msg := range msgs {
+		p := pb.ProductUpdate{}
+		err := proto.Unmarshal(msg.Value, &p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal kafka massaga: %s", err)
+		}
+		err = pb.UpcastProductUpdate(&p)
+		if err != nil {
+			return nil, err
+		}
+
+		if !filter.Serves(p.Tenant) {
+			continue
+		}
+
+		key := tenant.Key(p.Tenant, string(msg.Key))
+		if _, ok := latest[key]; !ok {
+			order = append(order, key)
+		}
+		latest[key] = &ProductWrite{Tenant: p.Tenant, UUID: string(msg.Key), Product: p.New}
+
+		if p.New == nil {
+			tenant.Count(p.Tenant, "deletes", 1)
+		} else {
+			tenant.Count(p.Tenant, "updates", 1)
+		}
+	}
+
+	writes := make([]ProductWrite, len(order))
+	for i, key := range order {
+		writes[i] = *latest[key]
+	}
+	return writes, nil
+}
+
+// change is the net effect of the updates of one product within a batch
+type change struct {
+	tenant string
+	uuid   string
+	old    *pb.Product
+	new    *pb.Product
+}
+
+// CategoryChanges decodes a batch of product updates into the net category change of every product of the served tenants
+func CategoryChanges(msgs []*sarama.ConsumerMessage, filter tenant.Filter) ([]CategoryChange, error) {
+
+	changes := map[string]*change{}
+	order := []string{}
+
+	for _, msg := range msgs {
+		p := pb.ProductUpdate{}
+		err := proto.Unmarshal(msg.Value, &p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal kafka massaga: %s", err)
+		}
+
+		if !filter.Serves(p.Tenant) {
+			continue
+		}
+
+		UUID := string(msg.Key)
+		key := tenant.Key(p.Tenant, UUID)
+		c, ok := changes[key]
+		if !ok {
+			c = &change{tenant: p.Tenant, uuid: UUID, old: p.Old}
+			changes[key] = c
+			order = append(order, key)
+		}
+		c.new = p.New
+	}
+
+	moves := []CategoryChange{}
+	for _, key := range order {
+		c := changes[key]
+
+		switch {
+		case c.old == nil && c.new == nil:
+			continue
+
+		case c.old == nil:
+			moves = append(moves, CategoryChange{Tenant: c.tenant, UUID: c.uuid, New: c.new.Category})
+			tenant.Count(c.tenant, "additions", 1)
+
+		case c.new == nil:
+			moves = append(moves, CategoryChange{Tenant: c.tenant, UUID: c.uuid, Old: c.old.Category})
+			tenant.Count(c.tenant, "removals", 1)
+
+		case c.old.Category == c.new.Category:
+			continue
+
+		default:
+			moves = append(moves, CategoryChange{Tenant: c.tenant, UUID: c.uuid, Old: c.old.Category, New: c.new.Category})
+			tenant.Count(c.tenant, "moves", 1)
+		}
+	}
+
+	return moves, nil
+}
@@ -0,0 +1,38 @@
+package tenant
+
+import (
+	"expvar"
+	"log"
+	"net/http"
+	"sync"
+)
+
+var (
+	counters = expvar.NewMap("tenants")
+	mux      sync.Mutex
+)
+
+// Count adds delta to a counter of the tenant, published at /debug/vars
+func Count(tenant, name string, delta int64) {
+	stats, ok := counters.Get(Name(tenant)).(*expvar.Map)
+	if !ok {
+		mux.Lock()
+		stats, ok = counters.Get(Name(tenant)).(*expvar.Map)
+		if !ok {
+			stats = new(expvar.Map).Init()
+			counters.Set(Name(tenant), stats)
+		}
+		mux.Unlock()
+	}
+	stats.Add(name, delta)
+}
+
+// ServeMetrics publishes the counters via http, an empty address disables it
+func ServeMetrics(address string) {
+	if address == "" {
+		return
+	}
+	go func() {
+		log.Panic(http.ListenAndServe(address, nil))
+	}()
+}
@@ -0,0 +1,66 @@
+package tenant
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Header is the kafka record header carrying the tenant, it allows routing without decoding the envelope
+const Header = "tenant"
+
+var pattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Validate checks a tenant id, the empty id is the default catalogue
+func Validate(id string) error {
+	if id != "" && !pattern.MatchString(id) {
+		return fmt.Errorf("invalid tenant %q, expected lower case letters, digits, _ and -", id)
+	}
+	return nil
+}
+
+// Key prefixes a redis key with the tenant, keys of the default catalogue stay unchanged
+func Key(tenant, key string) string {
+	if tenant == "" {
+		return key
+	}
+	return tenant + ":" + key
+}
+
+// TaggedKey builds a key whose hash tag is the tenant and tag,
+// so all keys sharing a tag stay in one redis cluster slot and can be used by multi-key commands.
+// Tags should be narrow, every tag is served by a single cluster node
+func TaggedKey(tenant, tag, key string) string {
+	return "{" + Key(tenant, tag) + "}:" + key
+}
+
+// Name labels the tenant in logs and metrics
+func Name(tenant string) string {
+	if tenant == "" {
+		return "default"
+	}
+	return tenant
+}
+
+// Filter selects the tenants a consumer serves
+type Filter map[string]bool
+
+// NewFilter constructs a Filter, without tenants it serves all of them
+func NewFilter(tenants []string) (Filter, error) {
+	f := Filter{}
+	for _, t := range tenants {
+		if t == "default" {
+			t = ""
+		}
+		err := Validate(t)
+		if err != nil {
+			return nil, err
+		}
+		f[t] = true
+	}
+	return f, nil
+}
+
+// Serves tells if messages of the tenant are for this consumer
+func (f Filter) Serves(tenant string) bool {
+	return len(f) == 0 || f[tenant]
+}